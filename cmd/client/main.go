@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bubblenet/internal/client"
 	"bubblenet/internal/ui"
+	"bubblenet/pkg/config"
+	"bubblenet/pkg/protocol"
+	"cmp"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -17,6 +24,9 @@ type Config struct {
 	Host     string
 	Port     int
 	Username string
+	JoinCode string
+	Token    string
+	Password string
 }
 
 func validateAndCreateConfig(
@@ -26,14 +36,26 @@ func validateAndCreateConfig(
 	host string,
 	port int,
 	username string,
+	joinCode string,
+	inviteTTL time.Duration,
+	inviteUses int,
+	token string,
+	passwordFile string,
+	useTLS bool,
+	caFile string,
+	insecure bool,
 ) (ui.Config, error) {
 	config := ui.Config{
-		Room:     room,
-		Private:  private,
-		Invite:   invite,
-		Host:     host,
-		Port:     port,
-		Username: username,
+		Room:       room,
+		Private:    private,
+		Invite:     invite,
+		Host:       host,
+		Port:       port,
+		Username:   username,
+		JoinCode:   joinCode,
+		InviteTTL:  inviteTTL,
+		InviteUses: inviteUses,
+		Token:      token,
 	}
 
 	// Validaciones
@@ -45,8 +67,51 @@ func validateAndCreateConfig(
 		return config, fmt.Errorf("--private requires --room flag")
 	}
 
+	if joinCode != "" && room == "" {
+		return config, fmt.Errorf("--join-code requires --room flag")
+	}
+
+	if joinCode != "" && private {
+		return config, fmt.Errorf("--join-code can't be used with --private, it's for joining an existing room")
+	}
+
+	if inviteTTL < 0 || inviteUses < 0 {
+		return config, fmt.Errorf("--invite-ttl and --invite-uses can't be negative")
+	}
+
+	if inviteTTL > protocol.MaxExpiresIn*time.Second {
+		return config, fmt.Errorf("--invite-ttl can be at most a year")
+	}
+
+	if token != "" && passwordFile != "" {
+		return config, fmt.Errorf("use either --token or --password-file, not both")
+	}
+
+	if passwordFile != "" {
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return config, fmt.Errorf("reading --password-file: %w", err)
+		}
+		config.Password = strings.TrimRight(string(data), "\r\n")
+		if config.Password == "" {
+			return config, fmt.Errorf("--password-file %s is empty", passwordFile)
+		}
+	}
+
+	if (caFile != "" || insecure) && !useTLS {
+		return config, fmt.Errorf("--ca-file and --insecure-skip-verify require --tls")
+	}
+
+	if useTLS {
+		tlsConfig, err := client.NewTLSConfig(caFile, insecure)
+		if err != nil {
+			return config, fmt.Errorf("--ca-file: %w", err)
+		}
+		config.TLS = tlsConfig
+	}
+
 	if username == "" {
-		return config, fmt.Errorf("username is required, use --user flag")
+		return config, fmt.Errorf("username is required, use --user flag or set user in the config file")
 	}
 
 	if port < 1 || port > 65535 {
@@ -56,27 +121,158 @@ func validateAndCreateConfig(
 	return config, nil
 }
 
+// settings son los valores de los flags, que empiezan con lo que indica el
+// archivo de configuración
+type settings struct {
+	room       string
+	private    bool
+	invite     bool
+	host       string
+	port       int
+	username   string
+	joinCode   string
+	inviteTTL  time.Duration
+	inviteUses int
+
+	token        string
+	passwordFile string
+
+	useTLS   bool
+	caFile   string
+	insecure bool
+
+	configPath string
+	profile    string
+}
+
+// defaultSettings son los valores sin archivo de configuración
+func defaultSettings() settings {
+	return settings{
+		host:      "localhost",
+		port:      8080,
+		inviteTTL: 24 * time.Hour,
+	}
+}
+
+// bindFlags define los flags sobre s, con sus valores actuales por defecto
+func bindFlags(fs *flag.FlagSet, s *settings) {
+	fs.StringVar(&s.room, "room", s.room, "Name of the room you want to join")
+	fs.BoolVar(&s.private, "private", s.private, "Create a private room")
+	fs.BoolVar(&s.invite, "invite", s.invite, "Generate invitation code")
+	fs.StringVar(&s.host, "host", s.host, "Host of the server")
+	fs.IntVar(&s.port, "port", s.port, "Server port")
+	fs.StringVar(&s.username, "user", s.username, "Username")
+
+	fs.StringVar(&s.joinCode, "join-code", s.joinCode, "Invite code to join a private room")
+	fs.DurationVar(&s.inviteTTL, "invite-ttl", s.inviteTTL, "How long the generated invite code is valid (0 = no expiry)")
+	fs.IntVar(&s.inviteUses, "invite-uses", s.inviteUses, "How many times the generated invite code can be used (0 = unlimited)")
+
+	fs.StringVar(&s.token, "token", s.token, "Access token for servers that require authentication")
+	fs.StringVar(&s.passwordFile, "password-file", s.passwordFile, "File containing your password for servers that require authentication")
+
+	fs.BoolVar(&s.useTLS, "tls", s.useTLS, "Connect with TLS (wss://)")
+	fs.StringVar(&s.caFile, "ca-file", s.caFile, "Extra CA or self-signed certificate to trust, e.g. the server's development certificate")
+	fs.BoolVar(&s.insecure, "insecure-skip-verify", s.insecure, "Don't verify the server certificate (testing only)")
+
+	fs.StringVar(&s.configPath, "config", s.configPath, "Config file with your username, server profiles, theme and keybindings (default ~/.config/bubblenet/config.toml)")
+	fs.StringVar(&s.profile, "profile", s.profile, "Server profile from the config file to connect to")
+}
+
+// settingsFromFile arma los valores por defecto de los flags con la
+// configuración general y la del perfil elegido
+func settingsFromFile(file config.Client, profileName string) (settings, error) {
+	s := defaultSettings()
+	profile, err := file.Profile(profileName)
+	if err != nil {
+		return s, err
+	}
+
+	s.username = cmp.Or(profile.User, file.User)
+	s.room = cmp.Or(profile.Room, file.Room)
+	s.host = cmp.Or(profile.Host, s.host)
+	s.port = cmp.Or(profile.Port, s.port)
+	s.token = profile.Token
+	s.passwordFile = expandHome(profile.PasswordFile)
+	s.useTLS = profile.TLS
+	s.caFile = expandHome(profile.CAFile)
+	s.insecure = profile.InsecureSkipVerify
+	return s, nil
+}
+
+// expandHome reemplaza el ~/ del principio de una ruta del archivo de
+// configuración, que no pasa por la shell
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// preferFlags descarta lo del perfil que contradice un flag explícito: la
+// otra credencial si se pasó --token o --password-file, y las opciones de
+// TLS si se pasó --tls=false
+func (s *settings) preferFlags(fs *flag.FlagSet) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if set["token"] && !set["password-file"] {
+		s.passwordFile = ""
+	}
+	if set["password-file"] && !set["token"] {
+		s.token = ""
+	}
+	if set["tls"] && !s.useTLS {
+		if !set["ca-file"] {
+			s.caFile = ""
+		}
+		if !set["insecure-skip-verify"] {
+			s.insecure = false
+		}
+	}
+}
+
 func main() {
 	fmt.Println("Bubblenet websocket server")
 	log.Println("Project initialize successfully")
 
-	var (
-		room     = flag.String("room", "", "Name of the room you want to join")
-		private  = flag.Bool("private", false, "Create a private room")
-		invite   = flag.Bool("invite", false, "Generate invitation code")
-		host     = flag.String("host", "localhost", "Host of the server")
-		port     = flag.Int("port", 8080, "Server port")
-		username = flag.String("user", "", "Username")
-	)
+	// Primera pasada solo para saber qué archivo y qué perfil usar
+	pre := defaultSettings()
+	scratch := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	bindFlags(scratch, &pre)
+	scratch.Parse(os.Args[1:])
+
+	file, path, err := config.LoadClient(pre.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Err: %v\n", err)
+		os.Exit(1)
+	}
+	s, err := settingsFromFile(file, pre.profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Err: --profile: %v\n", err)
+		os.Exit(1)
+	}
+	if path != "" {
+		log.Printf("Config loaded from %s", path)
+	}
 
+	// Los flags pisan lo que vino del archivo
+	bindFlags(flag.CommandLine, &s)
 	flag.Parse()
+	s.preferFlags(flag.CommandLine)
 
-	config, err := validateAndCreateConfig(*room, *private, *invite, *host, *port, *username)
+	config, err := validateAndCreateConfig(s.room, s.private, s.invite, s.host, s.port, s.username, s.joinCode, s.inviteTTL, s.inviteUses, s.token, s.passwordFile, s.useTLS, s.caFile, s.insecure)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Err: %v\n", err)
 		flag.Usage()
 		os.Exit(1)
 	}
+	config.Theme = file.Theme
+	config.Keys = file.Keys
 
 	app := ui.NewApp(config)
 	program := tea.NewProgram(app, tea.WithAltScreen())
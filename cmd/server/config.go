@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bubblenet/internal/server"
+	"bubblenet/pkg/config"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Certificado que genera --tls-self-signed si no se indica otro
+const (
+	selfSignedCert = "data/tls/cert.pem"
+	selfSignedKey  = "data/tls/key.pem"
+)
+
+// options son los flags que no forman parte de la configuración
+type options struct {
+	configPath   string
+	hashPassword bool
+	newToken     bool
+}
+
+// newFlagSet define los flags del servidor sobre cfg, así un flag explícito
+// pisa lo que vino del archivo y del entorno
+func newFlagSet(cfg *config.Server, opts *options, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], errorHandling)
+
+	fs.StringVar(&opts.configPath, "config", "", "Config file (.toml, .yaml or .yml); BUBBLENET_* environment variables override it (default $BUBBLENET_CONFIG)")
+
+	fs.StringVar(&cfg.Listen, "listen", cfg.Listen, "Address to listen on")
+	fs.Func("port", "Port to listen on on all interfaces (shorthand for --listen :PORT)", func(port string) error {
+		cfg.Listen = ":" + port
+		return nil
+	})
+	fs.BoolVar(&cfg.Debug, "debug", cfg.Debug, "Enable debug mode")
+	fs.StringVar(&cfg.MOTD, "motd", cfg.MOTD, "Message of the day sent to clients when they connect")
+
+	fs.DurationVar(&cfg.ResumeWindow, "resume-window", cfg.ResumeWindow, "How long a disconnected client can resume its session (0 disables resuming)")
+
+	fs.Float64Var(&cfg.Limits.RateMessages, "rate-messages", cfg.Limits.RateMessages, "Messages per second accepted from each connection (0 disables the limit)")
+	fs.Float64Var(&cfg.Limits.RateBytes, "rate-bytes", cfg.Limits.RateBytes, "Bytes per second accepted from each connection (0 disables the limit)")
+	fs.Float64Var(&cfg.Limits.UserRateMessages, "user-rate-messages", cfg.Limits.UserRateMessages, "Messages per second accepted from all connections of a user (0 disables the limit)")
+	fs.Float64Var(&cfg.Limits.UserRateBytes, "user-rate-bytes", cfg.Limits.UserRateBytes, "Bytes per second accepted from all connections of a user (0 disables the limit)")
+	fs.DurationVar(&cfg.Limits.MuteDuration, "mute-duration", cfg.Limits.MuteDuration, "How long a client that keeps exceeding the rate limits is muted")
+
+	fs.StringVar(&cfg.Limits.SlowConsumer, "slow-consumer", cfg.Limits.SlowConsumer, "What to do when a client can't keep up: disconnect, drop-oldest or coalesce")
+
+	fs.StringVar(&cfg.Storage.Backend, "store", cfg.Storage.Backend, "Message history backend: memory or file")
+	fs.StringVar(&cfg.Storage.Path, "store-path", cfg.Storage.Path, "Directory for the file history backend")
+	fs.IntVar(&cfg.Storage.HistorySize, "history-size", cfg.Storage.HistorySize, "Messages kept per room by the memory backend")
+	fs.IntVar(&cfg.Storage.HistoryReplay, "history-replay", cfg.Storage.HistoryReplay, "Messages replayed to a client when it joins a room")
+
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "How long to wait for clients to be notified and disconnected on SIGINT/SIGTERM")
+
+	fs.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "TLS certificate file; with --tls-key, serves wss:// and https://")
+	fs.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "TLS private key file")
+	fs.BoolVar(&cfg.TLS.SelfSigned, "tls-self-signed", cfg.TLS.SelfSigned, "Generate a self-signed certificate for development if --tls-cert doesn't exist yet (default "+selfSignedCert+")")
+
+	fs.Func("allowed-origins", "Comma-separated origins allowed to open WebSockets and call the REST API (exact, https://*.example.com or *); empty allows only the server's own origin", func(value string) error {
+		cfg.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+			}
+		}
+		return nil
+	})
+
+	fs.StringVar(&cfg.UsersFile, "users", cfg.UsersFile, "User store file; when set, clients must authenticate")
+	fs.BoolVar(&opts.hashPassword, "hash-password", false, "Read a password from stdin, print its user store hash and exit")
+	fs.BoolVar(&opts.newToken, "new-token", false, "Generate an access token, print it with its user store hash and exit")
+
+	return fs
+}
+
+// loadConfig arma la configuración: valores por defecto, archivo, variables
+// BUBBLENET_* y por último los flags de args
+func loadConfig(args []string, errorHandling flag.ErrorHandling) (config.Server, options, error) {
+	// Primera pasada solo para saber qué archivo leer
+	var opts options
+	scratch := config.DefaultServer()
+	if err := newFlagSet(&scratch, &opts, errorHandling).Parse(args); err != nil {
+		return config.Server{}, opts, err
+	}
+	opts.configPath = cmp.Or(opts.configPath, os.Getenv(config.EnvPrefix+"_CONFIG"))
+
+	cfg := config.DefaultServer()
+	if opts.configPath != "" {
+		if err := config.Load(opts.configPath, &cfg); err != nil {
+			return config.Server{}, opts, err
+		}
+	}
+	if err := config.ApplyEnv(config.EnvPrefix, &cfg); err != nil {
+		return config.Server{}, opts, err
+	}
+	if err := newFlagSet(&cfg, &options{}, errorHandling).Parse(args); err != nil {
+		return config.Server{}, opts, err
+	}
+
+	if cfg.TLS.SelfSigned {
+		cfg.TLS.Cert = cmp.Or(cfg.TLS.Cert, selfSignedCert)
+		cfg.TLS.Key = cmp.Or(cfg.TLS.Key, selfSignedKey)
+	}
+	return cfg, opts, nil
+}
+
+// newHubConfig valida cfg y arma la configuración del hub, sin el
+// historial ni los usuarios que main abre aparte. Los errores vienen todos
+// juntos, uno por línea
+func newHubConfig(cfg config.Server) (server.HubConfig, error) {
+	errs := []error{cfg.Validate()}
+
+	slowPolicy, err := server.ParseSlowConsumerPolicy(cfg.Limits.SlowConsumer)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("limits.slow_consumer: %w", err))
+	}
+	origins, err := server.NewOriginPolicy(cfg.AllowedOrigins)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("allowed_origins: %w", err))
+	}
+	rooms := make([]server.RoomConfig, len(cfg.Rooms))
+	for i, room := range cfg.Rooms {
+		rooms[i] = server.RoomConfig{
+			Name:     room.Name,
+			MaxUsers: room.MaxUsers,
+			Owner:    room.Owner,
+			SlowMode: room.SlowMode,
+			ReadOnly: room.ReadOnly,
+		}
+	}
+	errs = append(errs, server.ValidateRooms(rooms))
+	if err := errors.Join(errs...); err != nil {
+		return server.HubConfig{}, err
+	}
+
+	return server.HubConfig{
+		Debug:          cfg.Debug,
+		HistoryReplay:  cfg.Storage.HistoryReplay,
+		MOTD:           cfg.MOTD,
+		ResumeWindow:   cfg.ResumeWindow,
+		SlowConsumer:   slowPolicy,
+		Origins:        origins,
+		RateLimit:      server.RateLimit{Messages: cfg.Limits.RateMessages, Bytes: cfg.Limits.RateBytes},
+		UserRateLimit:  server.RateLimit{Messages: cfg.Limits.UserRateMessages, Bytes: cfg.Limits.UserRateBytes},
+		MuteDuration:   cfg.Limits.MuteDuration,
+		WriteWait:      cfg.Timeouts.Write,
+		PongWait:       cfg.Timeouts.Pong,
+		HelloWait:      cfg.Timeouts.Hello,
+		MaxMessageSize: cfg.Limits.MaxMessageSize,
+		SendBuffer:     cfg.Limits.SendBuffer,
+		RoomBuffer:     cfg.Limits.RoomBuffer,
+		Rooms:          rooms,
+	}, nil
+}
+
+// logConfigErrors muestra cada error de configuración en su propia línea
+func logConfigErrors(title string, err error) {
+	log.Printf("❌ %s", title)
+	for _, line := range strings.Split(err.Error(), "\n") {
+		log.Printf("   - %s", line)
+	}
+}
+
+// restartOnly lista las opciones que cambiaron entre running y cfg pero que
+// el servidor solo lee al arrancar
+func restartOnly(running, cfg config.Server) []string {
+	var changed []string
+	check := func(name string, same bool) {
+		if !same {
+			changed = append(changed, name)
+		}
+	}
+	check("listen", running.Listen == cfg.Listen)
+	check("users_file", running.UsersFile == cfg.UsersFile)
+	check("timeouts", running.Timeouts == cfg.Timeouts)
+	check("limits.max_message_size", running.Limits.MaxMessageSize == cfg.Limits.MaxMessageSize)
+	check("limits.send_buffer", running.Limits.SendBuffer == cfg.Limits.SendBuffer)
+	check("limits.room_buffer", running.Limits.RoomBuffer == cfg.Limits.RoomBuffer)
+	check("storage.backend", running.Storage.Backend == cfg.Storage.Backend)
+	check("storage.path", running.Storage.Path == cfg.Storage.Path)
+	check("storage.history_size", running.Storage.HistorySize == cfg.Storage.HistorySize)
+	check("tls", running.TLS == cfg.TLS)
+	return changed
+}
+
+// reloadConfig vuelve a leer la configuración al recibir SIGHUP y aplica al
+// hub lo que se puede cambiar en caliente. Si la configuración nueva no es
+// válida se sigue con la anterior
+func reloadConfig(hub *server.Hub, running config.Server) (config.Server, bool) {
+	cfg, _, err := loadConfig(os.Args[1:], flag.ContinueOnError)
+	if err == nil {
+		var hubConfig server.HubConfig
+		if hubConfig, err = newHubConfig(cfg); err == nil {
+			for _, name := range restartOnly(running, cfg) {
+				log.Printf("⚠️ %s changed, restart the server to apply it", name)
+			}
+			hub.Reload(hubConfig)
+			return cfg, true
+		}
+	}
+	logConfigErrors("Config reload failed, keeping the current configuration:", err)
+	return running, false
+}
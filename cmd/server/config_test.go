@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bubblenet/internal/server"
+	"bubblenet/pkg/config"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.toml")
+	if err := os.WriteFile(path, []byte("motd = \"archivo\"\nlisten = \":9000\"\ndebug = true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BUBBLENET_LISTEN", ":9100")
+
+	// El archivo pisa los valores por defecto, el entorno al archivo y los
+	// flags a todo lo demás
+	cfg, opts, err := loadConfig([]string{"--config", path, "--motd", "flag"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if opts.configPath != path || cfg.MOTD != "flag" || cfg.Listen != ":9100" || !cfg.Debug {
+		t.Errorf("loadConfig = config %q, motd %q, listen %q, debug %t; want the file, flag, :9100 and true",
+			opts.configPath, cfg.MOTD, cfg.Listen, cfg.Debug)
+	}
+}
+
+func TestNewHubConfigErrors(t *testing.T) {
+	cfg := config.DefaultServer()
+	cfg.Limits.SlowConsumer = "drop"
+	cfg.AllowedOrigins = []string{"chat.example.com"}
+	cfg.Rooms = []config.Room{{Name: "not a room!"}}
+
+	if _, err := newHubConfig(cfg); err == nil {
+		t.Fatal("newHubConfig accepted an invalid configuration")
+	}
+	if _, err := newHubConfig(config.DefaultServer()); err != nil {
+		t.Errorf("newHubConfig with the defaults: %v", err)
+	}
+}
+
+func TestRestartOnly(t *testing.T) {
+	running := config.DefaultServer()
+
+	// Lo que se aplica en caliente no hace falta reiniciarlo
+	cfg := running
+	cfg.Debug = true
+	cfg.MOTD = "hola"
+	cfg.ResumeWindow = time.Minute
+	cfg.Limits.RateMessages = 1
+	cfg.Limits.SlowConsumer = "coalesce"
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.Rooms = []config.Room{{Name: "news"}}
+	if changed := restartOnly(running, cfg); len(changed) != 0 {
+		t.Errorf("restartOnly = %v, want nothing for reloadable options", changed)
+	}
+
+	cfg.Listen = ":9000"
+	cfg.Timeouts.Pong = time.Minute * 2
+	cfg.Limits.SendBuffer = 8
+	cfg.Storage.Backend = "file"
+	cfg.TLS.SelfSigned = true
+	want := []string{"listen", "timeouts", "limits.send_buffer", "storage.backend", "tls"}
+	if changed := restartOnly(running, cfg); !slices.Equal(changed, want) {
+		t.Errorf("restartOnly = %v, want %v", changed, want)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	hub := server.NewHub(server.HubConfig{Store: server.NewMemoryStore(10)})
+	go hub.Run()
+	defer hub.Shutdown(t.Context())
+
+	path := filepath.Join(t.TempDir(), "server.toml")
+	args := os.Args
+	t.Cleanup(func() { os.Args = args })
+	os.Args = []string{"server", "--config", path}
+	running := config.DefaultServer()
+
+	if err := os.WriteFile(path, []byte("motd = \"nuevo\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, ok := reloadConfig(hub, running); !ok || cfg.MOTD != "nuevo" {
+		t.Errorf("reloadConfig = motd %q, %t; want nuevo and true", cfg.MOTD, ok)
+	}
+
+	// Una configuración inválida deja la que estaba
+	if err := os.WriteFile(path, []byte("[limits]\nsend_buffer = -1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, ok := reloadConfig(hub, running); ok || cfg.Limits.SendBuffer != running.Limits.SendBuffer {
+		t.Errorf("reloadConfig with an invalid file = send buffer %d, %t; want the running config", cfg.Limits.SendBuffer, ok)
+	}
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"bubblenet/internal/server"
+	"bufio"
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,12 +20,55 @@ import (
 
 // entry point para el servidor
 func main() {
-	// flags que va a manejar el CLI
-	var (
-		port  = flag.String("port", "8080", "Port is listening on ...")
-		debug = flag.Bool("debug", false, "Enable debug mode")
-	)
-	flag.Parse()
+	// Configuración: valores por defecto, archivo, entorno y flags
+	cfg, opts, err := loadConfig(os.Args[1:], flag.ExitOnError)
+	if err != nil {
+		log.Fatal("❌ Error loading config: ", err)
+	}
+
+	// Utilidades para armar el archivo de usuarios
+	if opts.hashPassword || opts.newToken {
+		if err := printCredential(opts.hashPassword); err != nil {
+			log.Fatal("❌ Error generating credential:", err)
+		}
+		return
+	}
+
+	hubConfig, err := newHubConfig(cfg)
+	if err != nil {
+		logConfigErrors("Invalid configuration:", err)
+		os.Exit(1)
+	}
+	if opts.configPath != "" {
+		log.Printf("📄 Config loaded from %s", opts.configPath)
+	}
+
+	// TLS: certificado propio o autofirmado para desarrollo
+	if cfg.TLS.SelfSigned {
+		hostname, _ := os.Hostname()
+		created, err := server.EnsureSelfSignedCert(cfg.TLS.Cert, cfg.TLS.Key, []string{hostname})
+		if err != nil {
+			log.Fatal("❌ Error generating self-signed certificate:", err)
+		}
+		if created {
+			log.Printf("🔐 Generated self-signed certificate %s, clients can trust it with --ca-file %s", cfg.TLS.Cert, cfg.TLS.Cert)
+		}
+	}
+	useTLS := cfg.TLS.Cert != ""
+
+	// Usuarios registrados
+	if cfg.UsersFile != "" {
+		if hubConfig.Users, err = server.LoadUserStore(cfg.UsersFile); err != nil {
+			log.Fatal("❌ Error loading user store:", err)
+		}
+	}
+
+	// Almacenamiento del historial
+	store, err := server.OpenStore(cfg.Storage.Backend, cfg.Storage.Path, cfg.Storage.HistorySize)
+	if err != nil {
+		log.Fatal("❌ Error opening message store:", err)
+	}
+	hubConfig.Store = store
 
 	r := chi.NewRouter()
 	// middleware que usa chi
@@ -32,9 +83,22 @@ func main() {
 	})
 
 	// Crea el hub del websocket
-	hub := server.NewHub(*debug)
+	hub := server.NewHub(hubConfig)
 	go hub.Run()
 
+	// Métricas para Prometheus
+	r.Get("/metrics", hub.HandleMetrics)
+
+	// Directorio de salas e historial paginado, con CORS para los orígenes
+	// permitidos (los preflight los responde el middleware)
+	r.Group(func(r chi.Router) {
+		r.Use(hub.CORS)
+		r.Get("/rooms", hub.HandleRooms)
+		r.Options("/rooms", noContent)
+		r.Get("/rooms/{roomName}/messages", hub.HandleRoomMessages)
+		r.Options("/rooms/{roomName}/messages", noContent)
+	})
+
 	// websockets de ejemplo
 	r.Route("/ws", func(r chi.Router) {
 		// Echo endpoint para testing
@@ -43,19 +107,123 @@ func main() {
 		// Endpoint principal de chat
 		r.Get("/chat", hub.HandleChat)
 
-		// Endpoint por sala específica (futuro)
+		// Endpoint por sala específica
 		r.Get("/room/{roomName}", hub.HandleRoom)
 	})
 
 	// Info de startup
-	log.Printf("🚀 Bubblenet server starting on port %s", *port)
+	wsScheme, httpScheme := "ws", "http"
+	if useTLS {
+		wsScheme, httpScheme = "wss", "https"
+	}
+	host := localAddress(cfg.Listen)
+	log.Printf("🚀 Bubblenet server starting on %s", cfg.Listen)
 	log.Printf("📡 WebSocket endpoints:")
-	log.Printf("   - Echo: ws://localhost:%s/ws/echo", *port)
-	log.Printf("   - Chat: ws://localhost:%s/ws/chat", *port)
-	log.Printf("🔗 Health check: http://localhost:%s/health", *port)
+	log.Printf("   - Echo: %s://%s/ws/echo", wsScheme, host)
+	log.Printf("   - Chat: %s://%s/ws/chat", wsScheme, host)
+	log.Printf("   - Room: %s://%s/ws/room/{roomName}", wsScheme, host)
+	log.Printf("🏠 Rooms: %s://%s/rooms", httpScheme, host)
+	log.Printf("🔗 Health check: %s://%s/health", httpScheme, host)
+	log.Printf("📊 Metrics: %s://%s/metrics", httpScheme, host)
+	if hubConfig.Users != nil {
+		log.Printf("🔒 Authentication required (users from %s)", cfg.UsersFile)
+	}
+	log.Printf("🌐 Allowed origins: %s", hubConfig.Origins)
+	if useTLS {
+		log.Printf("🔐 TLS enabled (certificate %s)", cfg.TLS.Cert)
+	}
 
 	// Iniciar servidor
-	if err := http.ListenAndServe(":"+*port, r); err != nil {
-		log.Fatal("❌ Error starting server:", err)
+	srv := &http.Server{Addr: cfg.Listen, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		if useTLS {
+			serveErr <- srv.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key)
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+
+	// SIGHUP vuelve a leer la configuración; SIGINT/SIGTERM apagan en orden
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	running := cfg
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			log.Fatal("❌ Error starting server:", err)
+		case <-hup:
+			log.Printf("🔄 SIGHUP received, reloading configuration")
+			// La próxima recarga se compara con esta, así cada opción que pide
+			// reiniciar se avisa una sola vez
+			running, _ = reloadConfig(hub, running)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	stop() // una segunda señal corta el proceso sin esperar
+	signal.Stop(hup)
+
+	log.Printf("🛑 Shutting down, waiting up to %s for clients", running.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), running.ShutdownTimeout)
+	defer cancel()
+
+	// No más conexiones nuevas, aviso a los clientes y close frames
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ HTTP server did not stop cleanly: %v", err)
+	}
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Some clients were not disconnected in time: %v", err)
+	}
+
+	// El hub ya no guarda mensajes, se puede cerrar el historial
+	if err := store.Close(); err != nil {
+		log.Printf("❌ Error flushing message store: %v", err)
+	}
+	log.Printf("👋 Server stopped")
+}
+
+// localAddress arma la dirección para los logs de arranque, con localhost
+// si el servidor escucha en todas las interfaces
+func localAddress(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// noContent responde los OPTIONS que no son preflight CORS
+func noContent(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// printCredential imprime el hash de una contraseña leída de stdin o un
+// token nuevo, listos para agregar al archivo de usuarios
+func printCredential(password bool) error {
+	if !password {
+		token, hash, err := server.NewToken()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("token: %s\nuser store line: <username>:token:%s\n", token, hash)
+		return nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return err
+	}
+	hash, err := server.HashPassword(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return err
 	}
+	fmt.Printf("<username>:password:%s\n", hash)
+	return nil
 }
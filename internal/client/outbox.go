@@ -0,0 +1,139 @@
+package client
+
+// acá se manejan los mensajes de chat que esperan la confirmación del
+// servidor: se reenvían al reconectar hasta que llega el ack o un error
+
+import (
+	"bubblenet/pkg/protocol"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newClientID genera el ID con el que el cliente reconoce su mensaje
+func newClientID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// SendMessage envía un mensaje a la sala actual y retorna el ID con el que
+// llegará su confirmación (ack). Si no hay conexión el mensaje queda en el
+// outbox y sale al reconectar. Retorna "" si el servidor no confirma mensajes
+func (ws *WSClient) SendMessage(content string) string {
+	message := WSMessage{
+		Type:      protocol.TypeChat,
+		Username:  ws.username,
+		Content:   content,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+	}
+	if !ws.Can(protocol.CapAck) {
+		ws.queue(message)
+		return ""
+	}
+
+	message.ClientID = newClientID()
+	ws.enqueue(message)
+	return message.ClientID
+}
+
+// Resend vuelve a enviar un mensaje que no se confirmó a tiempo. Si el
+// primer envío sí llegó, el servidor lo reconoce y solo repite el ack
+func (ws *WSClient) Resend(clientID, content string) {
+	ws.enqueue(WSMessage{
+		Type:      protocol.TypeChat,
+		Username:  ws.username,
+		Content:   content,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		ClientID:  clientID,
+	})
+}
+
+// Discard saca un mensaje del outbox, no se vuelve a enviar al reconectar
+func (ws *WSClient) Discard(clientID string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.outbox = slices.DeleteFunc(ws.outbox, func(msg WSMessage) bool {
+		return msg.ClientID == clientID
+	})
+}
+
+// enqueue manda un mensaje por outgoing si hay conexión y si no lo guarda en
+// el outbox. Un mensaje está en una sola de las dos colas, así al reconectar
+// no sale dos veces
+func (ws *WSClient) enqueue(message WSMessage) {
+	ws.mu.Lock()
+	connected := ws.connected
+	ws.mu.Unlock()
+
+	if connected && ws.queue(message) {
+		return
+	}
+	ws.hold(message)
+	if !connected {
+		ws.log("📦 Offline, message %s kept in the outbox", message.ClientID)
+	}
+}
+
+// hold guarda un mensaje en el outbox hasta que llegue su confirmación. Si
+// ya estaba (un reenvío) lo reemplaza
+func (ws *WSClient) hold(message WSMessage) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	i := slices.IndexFunc(ws.outbox, func(msg WSMessage) bool {
+		return msg.ClientID == message.ClientID
+	})
+	if i >= 0 {
+		ws.outbox[i] = message
+	} else {
+		ws.outbox = append(ws.outbox, message)
+	}
+}
+
+// settle saca del outbox el mensaje que el servidor confirmó o rechazó
+func (ws *WSClient) settle(message WSMessage) {
+	if message.ClientID == "" {
+		return
+	}
+	if message.Type == protocol.TypeAck || message.Type == protocol.TypeError {
+		ws.Discard(message.ClientID)
+	}
+}
+
+// flushOutbox envía por una conexión nueva los mensajes sin confirmar y
+// marca al cliente como conectado, lo que se envíe después va por outgoing.
+// Lo que quedó en outgoing de la conexión anterior no está en el outbox y
+// sale después por writeLoop
+func (ws *WSClient) flushOutbox(conn *websocket.Conn) error {
+	ws.mu.Lock()
+	pending := slices.Clone(ws.outbox)
+	ws.connected = true
+	ws.mu.Unlock()
+
+	for _, message := range pending {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := conn.WriteJSON(message); err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		ws.log("📦 Flushed %d pending messages", len(pending))
+	}
+	return nil
+}
+
+// setOffline marca que se perdió la conexión, los mensajes nuevos quedan
+// en el outbox hasta reconectar
+func (ws *WSClient) setOffline() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.connected = false
+}
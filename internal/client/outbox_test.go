@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bubblenet/pkg/protocol"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// outboxIDs retorna los ClientID que esperan confirmación
+func outboxIDs(ws *WSClient) []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var ids []string
+	for _, msg := range ws.outbox {
+		ids = append(ids, msg.ClientID)
+	}
+	return ids
+}
+
+func TestOutboxUntilSettled(t *testing.T) {
+	ws := NewWSClient("localhost", 0, "alice", false)
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "acked"})
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "rejected"})
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "waiting"})
+	if len(ws.outgoing) != 0 {
+		t.Errorf("offline messages went to outgoing (%d)", len(ws.outgoing))
+	}
+
+	ws.settle(WSMessage{Type: protocol.TypeAck, ClientID: "acked"})
+	ws.settle(WSMessage{Type: protocol.TypeError, ClientID: "rejected"})
+	ws.settle(WSMessage{Type: protocol.TypeChat, ClientID: "waiting"})
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"waiting"}) {
+		t.Errorf("outbox = %v, want [waiting]", ids)
+	}
+}
+
+func TestFlushOutbox(t *testing.T) {
+	received := make(chan WSMessage, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg WSMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer srv.Close()
+
+	ws := NewWSClient("localhost", 0, "alice", false)
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "first"})
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "second"})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := ws.flushOutbox(conn); err != nil {
+		t.Fatalf("flushOutbox: %v", err)
+	}
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case msg := <-received:
+			got = append(got, msg.ClientID)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %v, want first and second", got)
+		}
+	}
+	if !slices.Equal(got, []string{"first", "second"}) {
+		t.Errorf("sent %v, want [first second]", got)
+	}
+
+	// Siguen esperando su ack, por si hay que reconectar otra vez
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"first", "second"}) {
+		t.Errorf("outbox = %v, want both messages", ids)
+	}
+}
+
+func TestEnqueueUsesOneQueue(t *testing.T) {
+	ws := NewWSClient("localhost", 0, "alice", false)
+
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "offline"})
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"offline"}) || len(ws.outgoing) != 0 {
+		t.Errorf("offline: outbox %v, outgoing %d, want only the outbox", ids, len(ws.outgoing))
+	}
+
+	ws.connected = true
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "online"})
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"offline"}) || len(ws.outgoing) != 1 {
+		t.Errorf("online: outbox %v, outgoing %d, want it only in outgoing", ids, len(ws.outgoing))
+	}
+
+	// Un reenvío reemplaza al mensaje que ya esperaba
+	ws.hold(WSMessage{Type: protocol.TypeChat, ClientID: "offline", Content: "again"})
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"offline"}) {
+		t.Errorf("after hold: outbox %v, want a single entry", ids)
+	}
+
+	ws.settle(WSMessage{Type: protocol.TypeAck, ClientID: "offline"})
+	if ids := outboxIDs(ws); len(ids) != 0 {
+		t.Errorf("after the ack: outbox %v, want it empty", ids)
+	}
+}
+
+func TestReconnectSendsEachMessageOnce(t *testing.T) {
+	received := make(chan WSMessage, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg WSMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer srv.Close()
+
+	// Uno quedó en outgoing al cortarse la conexión y otro se escribió sin conexión
+	ws := NewWSClient("localhost", 0, "alice", false)
+	ws.connected = true
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "queued"})
+	ws.setOffline()
+	ws.enqueue(WSMessage{Type: protocol.TypeChat, ClientID: "offline"})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go ws.writeLoop(conn, stop)
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case msg := <-received:
+			got = append(got, msg.ClientID)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %v, want offline and queued", got)
+		}
+	}
+	select {
+	case msg := <-received:
+		t.Errorf("%s was sent twice", msg.ClientID)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !slices.Equal(got, []string{"offline", "queued"}) {
+		t.Errorf("sent %v, want [offline queued]", got)
+	}
+
+	// Los dos esperan su ack en el outbox, por si hay que reconectar otra vez
+	if ids := outboxIDs(ws); !slices.Equal(ids, []string{"offline", "queued"}) {
+		t.Errorf("outbox = %v, want both messages", ids)
+	}
+}
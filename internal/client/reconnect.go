@@ -0,0 +1,118 @@
+package client
+
+// acá se maneja la reconexión automática con backoff exponencial
+
+import (
+	"bubblenet/pkg/protocol"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Espera antes del primer reintento y tope de la espera entre reintentos
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// ErrClosed indica que se cerró el cliente mientras se intentaba conectar
+var ErrClosed = errors.New("client closed")
+
+// permanent indica si un error de conexión no se arregla reintentando: el
+// servidor nos rechazó, su certificado no es válido o no habla TLS
+func permanent(err error) bool {
+	var rejected *HandshakeError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	return errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrClosed) || errors.As(err, &rejected) ||
+		errors.As(err, &certErr) || errors.As(err, &recordErr)
+}
+
+// kicked retorna un error si el servidor cerró la conexión con un código
+// que indica que no hay que reconectarse
+func kicked(err error) error {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code == protocol.CloseRateLimited {
+		return fmt.Errorf("disconnected by the server: %s", closeErr.Text)
+	}
+	return nil
+}
+
+// backoff retorna la espera antes del reintento attempt (desde 0): crece
+// exponencialmente hasta reconnectMaxDelay, con jitter para que los clientes
+// que perdieron la conexión a la vez no reconecten todos juntos
+func backoff(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+	if attempt < 16 {
+		delay = min(reconnectMinDelay<<attempt, reconnectMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// connectWithBackoff reintenta dial hasta conectar, hasta un error
+// permanente o hasta que se cierre el cliente
+func (ws *WSClient) connectWithBackoff() (*websocket.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := ws.dial()
+		if err == nil {
+			return conn, nil
+		}
+		if permanent(err) {
+			return nil, err
+		}
+
+		delay := backoff(attempt)
+		ws.log("⚠️ Connection attempt %d failed: %v, retrying in %s", attempt+1, err, delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-ws.done:
+			return nil, ErrClosed
+		}
+	}
+}
+
+// run mantiene la conexión: lee y escribe hasta que se corta, y entonces
+// reconecta y retoma la sesión sin que la UI tenga que hacer nada
+func (ws *WSClient) run(conn *websocket.Conn) {
+	for {
+		stop := make(chan struct{})
+		go ws.writeLoop(conn, stop)
+		err := ws.readLoop(conn)
+		close(stop)
+		conn.Close()
+		ws.setOffline()
+
+		if ws.isClosed() {
+			return
+		}
+		if err := kicked(err); err != nil {
+			ws.log("⛔ %v", err)
+			ws.status <- StatusError
+			ws.errors <- err
+			return
+		}
+		if websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			// El servidor se reinicia, ya avisó en la sala: se reconecta sin alarma
+			ws.log("🔄 Server is restarting, reconnecting")
+			ws.status <- StatusRestarting
+		} else {
+			ws.log("🔌 Connection lost, reconnecting")
+			ws.status <- StatusReconnecting
+		}
+
+		if conn, err = ws.connectWithBackoff(); err != nil {
+			ws.log("❌ Reconnection failed: %v", err)
+			if !errors.Is(err, ErrClosed) {
+				ws.status <- StatusError
+				ws.errors <- err
+			}
+			return
+		}
+		ws.log("✅ Reconnected, session resumed: %v", ws.Welcome().Resumed)
+		ws.status <- StatusConnected
+	}
+}
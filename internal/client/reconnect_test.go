@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bubblenet/pkg/protocol"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{reconnectMinDelay, 2 * reconnectMinDelay, 4 * reconnectMinDelay} {
+		for range 20 {
+			if got := backoff(attempt); got < want/2 || got > want {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, got, want/2, want)
+			}
+		}
+	}
+	// Sin overflow por muchos intentos que sea
+	for _, attempt := range []int{10, 16, 100} {
+		if got := backoff(attempt); got < reconnectMaxDelay/2 || got > reconnectMaxDelay {
+			t.Errorf("backoff(%d) = %s, want at most %s", attempt, got, reconnectMaxDelay)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrUnauthorized, true},
+		{ErrClosed, true},
+		{fmt.Errorf("dial: %w", &HandshakeError{Code: "nickname_taken"}), true},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := permanent(tt.err); got != tt.want {
+			t.Errorf("permanent(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReconnectResumesSession(t *testing.T) {
+	hellos := make(chan WSMessage, 2)
+	ws := testServer(t, func(conn *websocket.Conn) {
+		var hello WSMessage
+		if err := conn.ReadJSON(&hello); err != nil {
+			return
+		}
+		hellos <- hello
+		conn.WriteJSON(WSMessage{Type: protocol.TypeWelcome, Protocol: protocol.Version, SessionID: "s1", Resumed: hello.SessionID != ""})
+		if hello.SessionID == "" {
+			// La primera conexión entrega un mensaje y se corta
+			conn.WriteJSON(WSMessage{Type: protocol.TypeChat, ID: "m1", Seq: 1, Content: "hola"})
+			return
+		}
+		conn.ReadMessage()
+	})
+
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if first := <-hellos; first.SessionID != "" {
+		t.Errorf("first hello asks for session %q, want a new one", first.SessionID)
+	}
+
+	select {
+	case again := <-hellos:
+		if again.SessionID != "s1" || again.LastID != "m1" {
+			t.Errorf("hello after the drop = session %q last %q, want s1 from m1", again.SessionID, again.LastID)
+		}
+	case <-time.After(2*reconnectMinDelay + time.Second):
+		t.Fatal("the client did not reconnect")
+	}
+}
+
+func TestReconnectAfterRestart(t *testing.T) {
+	ws := testServer(t, func(conn *websocket.Conn) {
+		var hello WSMessage
+		if err := conn.ReadJSON(&hello); err != nil {
+			return
+		}
+		conn.WriteJSON(WSMessage{Type: protocol.TypeWelcome, Protocol: protocol.Version, SessionID: "s1"})
+		if hello.SessionID == "" {
+			// El servidor se apaga como en un reinicio
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server restarting"))
+			return
+		}
+		conn.ReadMessage()
+	})
+
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	for {
+		select {
+		case status := <-ws.status:
+			if status == StatusReconnecting {
+				t.Fatal("a restart was reported as a lost connection")
+			}
+			if status == StatusRestarting {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the client did not report the restart")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+// acá se configura la conexión cifrada (wss://) con el servidor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// NewTLSConfig arma la configuración TLS del cliente. Con caFile se confía
+// en ese certificado (o CA) además de los del sistema, por ejemplo el
+// autofirmado del servidor de desarrollo. insecure desactiva la verificación
+// del certificado y solo sirve para pruebas
+func NewTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// SetTLS hace que el cliente se conecte con wss:// usando config. Hay que
+// llamarla antes de Connect
+func (ws *WSClient) SetTLS(config *tls.Config) {
+	u, err := url.Parse(ws.url)
+	if err != nil {
+		return
+	}
+	u.Scheme = "wss"
+	ws.url = u.String()
+	ws.dialer.TLSClientConfig = config
+}
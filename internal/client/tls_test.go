@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	os.WriteFile(notPEM, []byte("hello"), 0o600)
+
+	if config, err := NewTLSConfig("", true); err != nil || !config.InsecureSkipVerify || config.RootCAs != nil {
+		t.Errorf("NewTLSConfig without CA = %+v, %v, want system roots and no verification", config, err)
+	}
+	if _, err := NewTLSConfig(filepath.Join(dir, "missing.pem"), false); err == nil {
+		t.Error("NewTLSConfig accepted a missing CA file")
+	}
+	if _, err := NewTLSConfig(notPEM, false); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("NewTLSConfig with a non-PEM file = %v, want an error", err)
+	}
+}
+
+func TestConnectTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	url := "wss" + strings.TrimPrefix(srv.URL, "https")
+
+	// Sin el certificado del servidor el error no se arregla reintentando
+	ws := NewWSClient("localhost", 0, "alice", false)
+	config, err := NewTLSConfig("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.SetTLS(config)
+	if !strings.HasPrefix(ws.url, "wss://") {
+		t.Errorf("url = %s, want wss://", ws.url)
+	}
+	ws.url = url
+	if _, err := ws.dial(); err == nil || !permanent(err) {
+		t.Errorf("dial with an untrusted certificate = %v, want a permanent error", err)
+	}
+
+	// Confiando en él ya se llega al servidor (que acá no es de chat)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600)
+	if config, err = NewTLSConfig(caFile, false); err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	ws.SetTLS(config)
+	ws.url = url
+	if _, err := ws.dial(); err == nil || permanent(err) {
+		t.Errorf("dial with the certificate trusted = %v, want a retryable handshake error", err)
+	}
+}
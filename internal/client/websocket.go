@@ -1,22 +1,67 @@
 package client
 
 import (
+	"bubblenet/pkg/protocol"
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// WSClient maneja la conexión WebSocket
+// Version es la versión del cliente que se informa en el hello
+const Version = "0.2.0"
+
+// Tiempo máximo para recibir el welcome después de enviar el hello
+const handshakeWait = 10 * time.Second
+
+// WSClient maneja la conexión WebSocket. Si la conexión se corta reconecta
+// solo y retoma la sesión, así que sobrevive a cortes breves de red
 type WSClient struct {
-	conn     *websocket.Conn
 	url      string
 	username string
+	room     string
 	debug    bool
 
+	// Dialer con la configuración TLS para wss://
+	dialer *websocket.Dialer
+
+	// mu protege lo que comparten la UI y la goroutine de la conexión:
+	// la conexión actual, la sala, la sesión y el último mensaje recibido
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+	done   chan struct{} // se cierra en Close para cortar los reintentos
+
+	// Código de invitación para la sala pedida en el hello
+	inviteCode string
+
+	// Respuesta del servidor al hello, con la sesión asignada
+	welcome WSMessage
+
+	// Último mensaje guardado de la sala que recibimos, para pedir al
+	// retomar la sesión solo lo que nos perdimos
+	lastID  string
+	lastSeq int64
+
+	// Mensajes de chat que salieron de outgoing (o no pudieron entrar) y
+	// que el servidor todavía no confirmó, y si hay una conexión por la que
+	// mandarlos
+	outbox    []WSMessage
+	connected bool
+
+	// Credenciales para el handshake (token o contraseña)
+	token    string
+	password string
+
 	// Canales para comunicación con la UI
 	incoming chan WSMessage
 	outgoing chan WSMessage
@@ -24,6 +69,21 @@ type WSClient struct {
 	status   chan ConnectionStatus
 }
 
+// ErrUnauthorized indica que el servidor rechazó las credenciales
+var ErrUnauthorized = errors.New("authentication failed: check --token or --password-file")
+
+// HandshakeError indica que el servidor respondió el hello con un error o
+// cerrando la conexión, o que habla una versión incompatible del protocolo
+type HandshakeError struct {
+	Code      string // Código del mensaje de error
+	CloseCode int    // Código del close frame
+	Message   string
+}
+
+func (e *HandshakeError) Error() string {
+	return "handshake rejected: " + e.Message
+}
+
 // ConnectionStatus representa el estado de la conexión
 type ConnectionStatus int
 
@@ -31,7 +91,9 @@ const (
 	StatusDisconnected ConnectionStatus = iota
 	StatusConnecting
 	StatusConnected
+	StatusReconnecting
 	StatusError
+	StatusRestarting // el servidor se reinicia, se reconecta sin alarma
 )
 
 func (s ConnectionStatus) String() string {
@@ -42,22 +104,22 @@ func (s ConnectionStatus) String() string {
 		return "Connecting"
 	case StatusConnected:
 		return "Connected"
+	case StatusReconnecting:
+		return "Reconnecting"
 	case StatusError:
 		return "Error"
+	case StatusRestarting:
+		return "Server restarting"
 	default:
 		return "Unknown"
 	}
 }
 
-// WSMessage representa un mensaje WebSocket
-type WSMessage struct {
-	Type      string    `json:"type"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	Room      string    `json:"room,omitempty"`
-	Users     []string  `json:"users,omitempty"` // Para mensajes de tipo user_list
-}
+// WSMessage representa un mensaje WebSocket, definido en el protocolo compartido
+type WSMessage = protocol.Message
+
+// RoomInfo describe una sala del directorio del servidor
+type RoomInfo = protocol.RoomInfo
 
 // NewWSClient crea un nuevo cliente WebSocket
 func NewWSClient(host string, port int, username string, debug bool) *WSClient {
@@ -67,57 +129,383 @@ func NewWSClient(host string, port int, username string, debug bool) *WSClient {
 		Path:   "/ws/chat",
 	}
 
+	dialer := *websocket.DefaultDialer
 	return &WSClient{
 		url:      wsURL.String(),
+		dialer:   &dialer,
 		username: username,
 		debug:    debug,
 		incoming: make(chan WSMessage, 100),
 		outgoing: make(chan WSMessage, 100),
 		errors:   make(chan error, 10),
 		status:   make(chan ConnectionStatus, 10),
+		done:     make(chan struct{}),
 	}
 }
 
-// Connect establece la conexión WebSocket
+// SetCredentials configura cómo autenticarse en el handshake: con un token
+// (Authorization: Bearer) o con usuario y contraseña (Basic)
+func (ws *WSClient) SetCredentials(token, password string) {
+	ws.token = token
+	ws.password = password
+}
+
+// Connect establece la conexión WebSocket. Los errores de red se reintentan
+// con backoff, solo retorna error si el servidor rechaza al cliente o si se
+// cerró. Una vez conectado, la conexión se mantiene sola
 func (ws *WSClient) Connect() error {
 	ws.log("🔗 Connecting to %s", ws.url)
 	ws.status <- StatusConnecting
 
-	conn, _, err := websocket.DefaultDialer.Dial(ws.url, nil)
+	conn, err := ws.connectWithBackoff()
 	if err != nil {
 		ws.log("❌ Connection failed: %v", err)
-		ws.status <- StatusError
-		ws.errors <- err
+		if !errors.Is(err, ErrClosed) {
+			ws.status <- StatusError
+			ws.errors <- err
+		}
 		return err
 	}
 
-	ws.conn = conn
 	ws.log("✅ Connected successfully")
-	ws.log("🔄 Sending StatusConnected to status channel")
 	ws.status <- StatusConnected
-	ws.log("✅ StatusConnected sent to status channel")
 
-	// Iniciar goroutines de lectura y escritura
-	go ws.readLoop()
-	go ws.writeLoop()
+	// Mantener la conexión (lectura, escritura y reconexión)
+	go ws.run(conn)
 
 	return nil
 }
 
-// SendMessage envía un mensaje
-func (ws *WSClient) SendMessage(content string) {
-	message := WSMessage{
-		Type:      "chat",
+// dial abre una conexión y completa el handshake, un solo intento
+func (ws *WSClient) dial() (*websocket.Conn, error) {
+	header := http.Header{}
+	if ws.token != "" {
+		header.Set("Authorization", "Bearer "+ws.token)
+	} else if ws.password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(ws.username + ":" + ws.password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+
+	conn, resp, err := ws.dialer.Dial(ws.url, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			err = ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if err := ws.handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		conn.Close()
+		return nil, ErrClosed
+	}
+	ws.conn = conn
+	return conn, nil
+}
+
+// handshake envía el hello y espera el welcome del servidor. Los mensajes
+// que llegan después del welcome se entregan a la UI normalmente
+func (ws *WSClient) handshake(conn *websocket.Conn) error {
+	// Si ya teníamos una sesión se pide retomarla
+	ws.mu.Lock()
+	hello := WSMessage{
+		Type:         protocol.TypeHello,
+		Username:     ws.username,
+		Timestamp:    time.Now(),
+		Room:         ws.room,
+		InviteCode:   ws.inviteCode,
+		SessionID:    ws.welcome.SessionID,
+		LastID:       ws.lastID,
+		Protocol:     protocol.Version,
+		Version:      Version,
+		Capabilities: protocol.Capabilities(),
+	}
+	ws.mu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(hello); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(handshakeWait))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for welcomed := false; !welcomed; {
+		_, messageBytes, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				return &HandshakeError{CloseCode: closeErr.Code, Message: closeErr.Text}
+			}
+			return fmt.Errorf("waiting for welcome: %w", err)
+		}
+		for _, frame := range bytes.Split(messageBytes, []byte{'\n'}) {
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
+			}
+			if welcomed {
+				ws.dispatch(frame)
+				continue
+			}
+
+			var message WSMessage
+			if err := json.Unmarshal(frame, &message); err != nil {
+				continue
+			}
+			switch message.Type {
+			case protocol.TypeWelcome:
+				if _, ok := protocol.NegotiateVersion(message.Protocol); !ok {
+					return &HandshakeError{Message: fmt.Sprintf(
+						"server speaks protocol version %d, this client requires %d to %d",
+						message.Protocol, protocol.MinVersion, protocol.Version)}
+				}
+				welcomed = true
+				ws.mu.Lock()
+				ws.welcome = message
+				ws.inviteCode = ""
+				ws.mu.Unlock()
+				ws.log("👋 Welcome from server %s, session %s (resumed: %v)", message.Version, message.SessionID, message.Resumed)
+			case protocol.TypeError:
+				return &HandshakeError{Code: message.Code, Message: message.Content}
+			}
+		}
+	}
+	return nil
+}
+
+// Welcome retorna la respuesta del servidor al último hello
+func (ws *WSClient) Welcome() WSMessage {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.welcome
+}
+
+// Can indica si el servidor acordó una capacidad del protocolo en el welcome
+func (ws *WSClient) Can(capability string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return slices.Contains(ws.welcome.Capabilities, capability)
+}
+
+// SetRoom fija la sala (y su invitación, si es privada) que se pide en el hello
+func (ws *WSClient) SetRoom(room, inviteCode string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.room = room
+	ws.inviteCode = inviteCode
+}
+
+// setRoom cambia la sala actual, lo recibido de la anterior ya no sirve
+// para retomar la sesión
+func (ws *WSClient) setRoom(room string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.room = room
+	ws.lastID = ""
+	ws.lastSeq = 0
+}
+
+// SendTyping avisa a la sala actual si el usuario está escribiendo o dejó de hacerlo
+func (ws *WSClient) SendTyping(typing bool) {
+	// Los servidores que no acordaron typing no los esperan
+	if !ws.Can(protocol.CapTyping) {
+		return
+	}
+	status := protocol.StatusStoppedTyping
+	if typing {
+		status = protocol.StatusTyping
+	}
+	ws.queue(WSMessage{
+		Type:      protocol.TypeTyping,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		Status:    status,
+	})
+}
+
+// SendDirectMessage envía un mensaje privado a otro usuario
+func (ws *WSClient) SendDirectMessage(to, content string) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeDM,
 		Username:  ws.username,
 		Content:   content,
 		Timestamp: time.Now(),
+		To:        to,
+	})
+}
+
+// EditMessage reemplaza el contenido de un mensaje propio
+func (ws *WSClient) EditMessage(id, content string) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeEdit,
+		Username:  ws.username,
+		Content:   content,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		RefID:     id,
+	})
+}
+
+// DeleteMessage borra un mensaje propio
+func (ws *WSClient) DeleteMessage(id string) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeDelete,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		RefID:     id,
+	})
+}
+
+// ChangeNick pide al servidor un nuevo nickname, el cambio se confirma
+// con un mensaje de tipo nick
+func (ws *WSClient) ChangeNick(name string) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeNick,
+		Username:  ws.username,
+		Content:   name,
+		Timestamp: time.Now(),
+	})
+}
+
+// Moderate pide una acción de moderación (kick, ban, unban, op, deop, mute
+// o unmute) sobre target en la sala actual. reason y duration son
+// opcionales; duration aplica a ban y mute, y 0 es sin vencimiento
+func (ws *WSClient) Moderate(action, target, reason string, duration time.Duration) {
+	ws.queue(WSMessage{
+		Type:      action,
+		Username:  ws.username,
+		Content:   reason,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		Target:    target,
+		ExpiresIn: int(duration / time.Second),
+	})
+}
+
+// SetSlowMode fija el tiempo mínimo entre mensajes de cada usuario en la
+// sala actual, 0 lo apaga
+func (ws *WSClient) SetSlowMode(interval time.Duration) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeSlowMode,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		SlowMode:  int(interval / time.Second),
+	})
+}
+
+// SetReadOnly pone o saca la sala actual en modo solo lectura
+func (ws *WSClient) SetReadOnly(readOnly bool) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeReadOnly,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		ReadOnly:  readOnly,
+	})
+}
+
+// SetUsername actualiza el nombre con el que el cliente firma sus mensajes
+func (ws *WSClient) SetUsername(name string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.username = name
+}
+
+// JoinRoom une al cliente a una sala, los mensajes siguientes van a ella.
+// Las salas privadas requieren un código de invitación
+func (ws *WSClient) JoinRoom(room, inviteCode string) {
+	if room != ws.room {
+		ws.setRoom(room)
+	}
+	ws.queue(WSMessage{
+		Type:       protocol.TypeJoin,
+		Username:   ws.username,
+		Timestamp:  time.Now(),
+		Room:       room,
+		InviteCode: inviteCode,
+	})
+}
+
+// CreateRoom pide al servidor crear una sala, si se crea el servidor
+// responde con room_created y une al cliente a ella
+func (ws *WSClient) CreateRoom(room string, maxUsers int, private bool) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeCreateRoom,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      room,
+		MaxUsers:  maxUsers,
+		Private:   private,
+	})
+}
+
+// RequestInvite pide al servidor un código de invitación para la sala actual,
+// ttl y maxUses en cero significan sin vencimiento y usos ilimitados
+func (ws *WSClient) RequestInvite(ttl time.Duration, maxUses int) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeInvite,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+		ExpiresIn: int(ttl / time.Second),
+		MaxUses:   maxUses,
+	})
+}
+
+// LeaveRoom saca al cliente de la sala actual
+func (ws *WSClient) LeaveRoom() {
+	if ws.room == "" {
+		return
 	}
+	ws.queue(WSMessage{
+		Type:      protocol.TypeLeave,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      ws.room,
+	})
+	ws.setRoom("")
+}
+
+// RequestRoomList pide al servidor el directorio de salas
+func (ws *WSClient) RequestRoomList() {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeRoomList,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+	})
+}
 
+// RequestHistory pide una página de mensajes de la sala anteriores a before
+func (ws *WSClient) RequestHistory(room string, before int64, limit int) {
+	ws.queue(WSMessage{
+		Type:      protocol.TypeHistoryRequest,
+		Username:  ws.username,
+		Timestamp: time.Now(),
+		Room:      room,
+		Before:    before,
+		Limit:     limit,
+	})
+}
+
+// queue encola un mensaje para enviarlo al servidor, retorna false si la
+// cola está llena y el mensaje se descartó
+func (ws *WSClient) queue(message WSMessage) bool {
 	select {
 	case ws.outgoing <- message:
-		ws.log("📤 Queued message: %s", content)
+		ws.log("📤 Queued %s message: %s", message.Type, message.Content)
+		return true
 	default:
 		ws.log("⚠️ Outgoing queue full, dropping message")
+		return false
 	}
 }
 
@@ -136,77 +524,138 @@ func (ws *WSClient) GetStatusChannel() <-chan ConnectionStatus {
 	return ws.status
 }
 
-// Close cierra la conexión
+// Close cierra la conexión y detiene la reconexión
 func (ws *WSClient) Close() {
-	if ws.conn != nil {
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return
+	}
+	ws.closed = true
+	close(ws.done)
+	conn := ws.conn
+	ws.mu.Unlock()
+
+	if conn != nil {
 		ws.log("🔌 Closing connection")
-		ws.conn.Close()
+		conn.Close()
 		ws.status <- StatusDisconnected
 	}
 }
 
-// readLoop lee mensajes del servidor
-func (ws *WSClient) readLoop() {
-	defer ws.conn.Close()
+// isClosed indica si se llamó a Close
+func (ws *WSClient) isClosed() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.closed
+}
 
+// readLoop lee mensajes del servidor hasta que se corta la conexión y
+// retorna el motivo
+func (ws *WSClient) readLoop(conn *websocket.Conn) error {
 	for {
-		_, messageBytes, err := ws.conn.ReadMessage()
+		_, messageBytes, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				ws.log("❌ Read error: %v", err)
-				ws.errors <- err
 			}
-			ws.status <- StatusDisconnected
-			return
+			return err
 		}
 
-		// Intentar parsear como JSON
-		var message WSMessage
-		if err := json.Unmarshal(messageBytes, &message); err != nil {
-			// Si no es JSON válido, tratarlo como mensaje de texto simple
-			message = WSMessage{
-				Type:      "chat",
-				Username:  "Unknown",
-				Content:   string(messageBytes),
-				Timestamp: time.Now(),
+		// El servidor puede agrupar varios mensajes separados por salto de línea
+		for _, frame := range bytes.Split(messageBytes, []byte{'\n'}) {
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
 			}
+			ws.dispatch(frame)
 		}
+	}
+}
 
-		ws.log("📥 Received: %s", message.Content)
+// dispatch parsea un mensaje recibido y lo entrega a la UI
+func (ws *WSClient) dispatch(messageBytes []byte) {
+	// Intentar parsear como JSON
+	var message WSMessage
+	if err := json.Unmarshal(messageBytes, &message); err != nil {
+		// Si no es JSON válido, tratarlo como mensaje de texto simple
+		message = WSMessage{
+			Type:      protocol.TypeChat,
+			Username:  "Unknown",
+			Content:   string(messageBytes),
+			Timestamp: time.Now(),
+		}
+	}
 
-		select {
-		case ws.incoming <- message:
-		default:
-			ws.log("⚠️ Incoming queue full, dropping message")
+	ws.log("📥 Received: %s", message.Content)
+	ws.track(message)
+	ws.settle(message)
+
+	select {
+	case ws.incoming <- message:
+	default:
+		ws.log("⚠️ Incoming queue full, dropping message")
+	}
+}
+
+// track recuerda el último mensaje guardado de la sala actual que recibimos
+func (ws *WSClient) track(message WSMessage) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	// El ack trae el Seq del mensaje confirmado, que llega aparte
+	if message.Room != ws.room || message.Type == protocol.TypeAck {
+		return
+	}
+	msgs := append([]WSMessage{message}, message.Messages...)
+	for _, msg := range msgs {
+		if msg.ID != "" && msg.Seq > ws.lastSeq {
+			ws.lastID = msg.ID
+			ws.lastSeq = msg.Seq
 		}
 	}
 }
 
-// writeLoop escribe mensajes al servidor
-func (ws *WSClient) writeLoop() {
+// writeLoop escribe mensajes al servidor hasta que se cierra stop o falla
+// una escritura
+func (ws *WSClient) writeLoop(conn *websocket.Conn, stop <-chan struct{}) {
 	ticker := time.NewTicker(54 * time.Second) // Ping cada 54 segundos
 	defer ticker.Stop()
 
+	// Primero lo que quedó sin confirmar de la conexión anterior
+	if err := ws.flushOutbox(conn); err != nil {
+		ws.log("❌ Write error: %v", err)
+		conn.Close()
+		return
+	}
+
 	for {
 		select {
 		case message := <-ws.outgoing:
-			ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if message.ClientID != "" {
+				// Pasa al outbox antes de escribirlo, así el ack no llega
+				// antes de que esté y si la escritura falla sale al reconectar
+				ws.hold(message)
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 
 			// Enviar como JSON
-			if err := ws.conn.WriteJSON(message); err != nil {
+			if err := conn.WriteJSON(message); err != nil {
 				ws.log("❌ Write error: %v", err)
-				ws.errors <- err
+				conn.Close() // readLoop lo nota y se reconecta
 				return
 			}
 
 			ws.log("📤 Sent: %s", message.Content)
 
 		case <-ticker.C:
-			ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := ws.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				ws.log("❌ Ping error: %v", err)
+				conn.Close()
 				return
 			}
+
+		case <-stop:
+			return
 		}
 	}
 }
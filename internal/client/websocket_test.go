@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bubblenet/pkg/protocol"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer arranca un servidor WebSocket que atiende cada conexión con
+// serve y retorna un cliente que apunta a él
+func testServer(t *testing.T, serve func(conn *websocket.Conn)) *WSClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	ws := NewWSClient("localhost", 0, "alice", false)
+	ws.url = "ws" + strings.TrimPrefix(srv.URL, "http")
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+func TestHandshakeWelcome(t *testing.T) {
+	hellos := make(chan WSMessage, 1)
+	ws := testServer(t, func(conn *websocket.Conn) {
+		var hello WSMessage
+		if err := conn.ReadJSON(&hello); err != nil {
+			return
+		}
+		hellos <- hello
+		// El welcome y el primer mensaje de la sala pueden venir en el mismo frame
+		conn.WriteMessage(websocket.TextMessage, []byte(
+			`{"type":"welcome","protocol":2,"capabilities":["dm"],"session_id":"s1"}`+"\n"+
+				`{"type":"system","content":"alice joined #general"}`))
+		conn.ReadMessage()
+	})
+
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	hello := <-hellos
+	if hello.Type != protocol.TypeHello || hello.Username != "alice" || hello.Protocol != protocol.Version {
+		t.Errorf("hello = %+v, want alice on protocol %d", hello, protocol.Version)
+	}
+	if !slices.Equal(hello.Capabilities, protocol.Capabilities()) {
+		t.Errorf("hello capabilities = %v, want %v", hello.Capabilities, protocol.Capabilities())
+	}
+
+	if ws.Welcome().SessionID != "s1" || !ws.Can(protocol.CapDM) || ws.Can(protocol.CapEdit) {
+		t.Errorf("welcome = %+v, want session s1 with only dm", ws.Welcome())
+	}
+	select {
+	case msg := <-ws.GetIncomingChannel():
+		if msg.Type != protocol.TypeSystem {
+			t.Errorf("first message = %+v, want the join announcement", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the message after the welcome was lost")
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(conn *websocket.Conn)
+		want  HandshakeError
+	}{
+		{
+			name: "error",
+			serve: func(conn *websocket.Conn) {
+				conn.ReadMessage()
+				conn.WriteJSON(WSMessage{Type: protocol.TypeError, Code: "nickname_taken", Content: "taken"})
+				conn.ReadMessage()
+			},
+			want: HandshakeError{Code: "nickname_taken", Message: "taken"},
+		},
+		{
+			name: "close",
+			serve: func(conn *websocket.Conn) {
+				conn.ReadMessage()
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(protocol.CloseUnsupportedProtocol, "upgrade"))
+				conn.ReadMessage()
+			},
+			want: HandshakeError{CloseCode: protocol.CloseUnsupportedProtocol, Message: "upgrade"},
+		},
+		{
+			name: "old server",
+			serve: func(conn *websocket.Conn) {
+				conn.ReadMessage()
+				conn.WriteJSON(WSMessage{Type: protocol.TypeWelcome, Protocol: protocol.MinVersion - 1})
+				conn.ReadMessage()
+			},
+			want: HandshakeError{Message: "server speaks protocol version"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := testServer(t, tt.serve)
+			err := ws.Connect()
+			var handshakeErr *HandshakeError
+			if !errors.As(err, &handshakeErr) {
+				t.Fatalf("Connect = %v, want a HandshakeError", err)
+			}
+			if handshakeErr.Code != tt.want.Code || handshakeErr.CloseCode != tt.want.CloseCode ||
+				!strings.HasPrefix(handshakeErr.Message, tt.want.Message) {
+				t.Errorf("Connect = %+v, want %+v", *handshakeErr, tt.want)
+			}
+		})
+	}
+}
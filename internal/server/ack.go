@@ -0,0 +1,71 @@
+package server
+
+// acá se manejan las confirmaciones de entrega de los mensajes de chat
+
+import (
+	"bubblenet/pkg/protocol"
+	"time"
+)
+
+// Cantidad de confirmaciones que se recuerdan por sesión para reconocer
+// los mensajes que el cliente reenvía al reconectar
+const ackLogSize = 256
+
+// ackLog guarda las últimas confirmaciones de una sesión por el ID que el
+// cliente le puso a cada mensaje (solo lo usa el hub)
+type ackLog struct {
+	order []string
+	acks  map[string]WSMessage
+}
+
+// add guarda una confirmación, olvidando la más vieja si ya hay ackLogSize
+func (l *ackLog) add(clientID string, ack WSMessage) {
+	if l.acks == nil {
+		l.acks = make(map[string]WSMessage)
+	}
+	if len(l.order) >= ackLogSize {
+		delete(l.acks, l.order[0])
+		l.order = l.order[1:]
+	}
+	l.order = append(l.order, clientID)
+	l.acks[clientID] = ack
+}
+
+// get retorna la confirmación de un mensaje ya recibido
+func (l *ackLog) get(clientID string) (WSMessage, bool) {
+	if clientID == "" {
+		return WSMessage{}, false
+	}
+	ack, ok := l.acks[clientID]
+	return ack, ok
+}
+
+// ackChat confirma al remitente que su mensaje se guardó, con el ID y la
+// posición que le asignó el servidor. Sale antes que el mensaje de la sala
+func (h *Hub) ackChat(client *Client, clientID string, msg WSMessage) {
+	ack := WSMessage{
+		Type:      protocol.TypeAck,
+		Username:  "System",
+		Timestamp: msg.Timestamp,
+		Room:      msg.Room,
+		Seq:       msg.Seq,
+		RefID:     msg.ID,
+		ClientID:  clientID,
+	}
+	client.acks.add(clientID, ack)
+	h.sendTo(client, ack)
+}
+
+// rejectChat rechaza un mensaje de chat indicando el ID que le puso el
+// cliente, para que lo marque como no entregado
+func (h *Hub) rejectChat(client *Client, clientID, code, content string) {
+	h.log("⚠️ Rejected message from %s (%s): %s", client.username, code, content)
+	h.sendTo(client, WSMessage{
+		Type:      protocol.TypeError,
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+		Code:      code,
+		ClientID:  clientID,
+	})
+}
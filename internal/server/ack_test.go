@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChatAck(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "hola", ClientID: "c1"})
+	ack := alice.expect(protocol.TypeAck)
+	msg := alice.expect(protocol.TypeChat)
+	if ack.ClientID != "c1" || ack.RefID != msg.ID || ack.Seq != msg.Seq {
+		t.Errorf("ack = %+v, want c1 for %s (seq %d)", ack, msg.ID, msg.Seq)
+	}
+	if msg.ClientID != "" {
+		t.Errorf("the room sees client ID %q, want it hidden", msg.ClientID)
+	}
+}
+
+func TestChatResentOnce(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "hola", ClientID: "c1"})
+	first := alice.expect(protocol.TypeAck)
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "hola", ClientID: "c1"})
+	if again := alice.expect(protocol.TypeAck); again.RefID != first.RefID {
+		t.Errorf("second ack for %s, want the first message %s", again.RefID, first.RefID)
+	}
+
+	// bob ve el mensaje una sola vez
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "marker"})
+	if msg := bob.expect(protocol.TypeChat); msg.Content != "hola" {
+		t.Errorf("bob got %q, want hola", msg.Content)
+	}
+	if msg := bob.expect(protocol.TypeChat); msg.Content != "marker" {
+		t.Errorf("bob got %q twice, want it once", msg.Content)
+	}
+}
+
+func TestChatRejectedWithClientID(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Room: "nowhere", Content: "hola", ClientID: "c1"})
+	if msg := alice.expectError(errRoomNotFound); msg.ClientID != "c1" {
+		t.Errorf("error for %q, want c1", msg.ClientID)
+	}
+}
+
+func TestAckSurvivesResume(t *testing.T) {
+	_, url := testHub(t, HubConfig{ResumeWindow: time.Minute})
+	alice, welcome := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "hola", ClientID: "c1"})
+	first := alice.expect(protocol.TypeAck)
+	alice.drop()
+
+	// El cliente no sabe si llegó y lo reenvía en la conexión nueva
+	again := dial(t, url)
+	again.hello(WSMessage{Username: "alice", SessionID: welcome.SessionID, LastID: first.RefID})
+	again.send(WSMessage{Type: protocol.TypeChat, Content: "hola", ClientID: "c1"})
+	if ack := again.expect(protocol.TypeAck); ack.RefID != first.RefID {
+		t.Errorf("ack after resume for %s, want %s", ack.RefID, first.RefID)
+	}
+}
+
+func TestAckLogForgetsOldest(t *testing.T) {
+	var l ackLog
+	for i := range ackLogSize + 1 {
+		l.add(fmt.Sprint(i), WSMessage{Seq: int64(i)})
+	}
+	if _, ok := l.get("0"); ok {
+		t.Error("the oldest ack is still remembered")
+	}
+	if ack, ok := l.get(fmt.Sprint(ackLogSize)); !ok || ack.Seq != ackLogSize {
+		t.Errorf("newest ack = %+v, %t", ack, ok)
+	}
+	if _, ok := l.get(""); ok {
+		t.Error("a message without client ID matched an ack")
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+// acá se manejan los usuarios registrados y la autenticación del handshake
+
+import (
+	"bufio"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Iteraciones de PBKDF2 para los hashes de contraseñas nuevos
+	passwordIterations = 600000
+
+	// Bytes de sal y de clave derivada de cada contraseña
+	passwordSaltBytes = 16
+	passwordKeyBytes  = 32
+
+	// Bytes aleatorios de los tokens generados con NewToken
+	tokenBytes = 32
+)
+
+// ErrUnauthorized indica credenciales faltantes o inválidas
+var ErrUnauthorized = errors.New("invalid or missing credentials")
+
+// dummyHash se compara cuando el usuario no existe, así la respuesta tarda lo
+// mismo que con una contraseña equivocada y no delata qué cuentas hay
+var dummyHash = fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+	passwordIterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, passwordSaltBytes)),
+	base64.RawStdEncoding.EncodeToString(make([]byte, passwordKeyBytes)))
+
+// UserStore guarda los usuarios que pueden conectarse. Se carga de un
+// archivo con una línea por credencial:
+//
+//	alice:password:pbkdf2-sha256$600000$<sal base64>$<hash base64>
+//	deploybot:token:sha256$<hash hex>
+//
+// Las líneas vacías y las que empiezan con # se ignoran
+type UserStore struct {
+	passwords map[string]string // usuario -> hash pbkdf2
+	tokens    map[string]string // sha256 del token (hex) -> usuario
+}
+
+// LoadUserStore lee el archivo de usuarios
+func LoadUserStore(path string) (*UserStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &UserStore{
+		passwords: make(map[string]string),
+		tokens:    make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("%s:%d: expected username:kind:hash", path, lineNum)
+		}
+		username, kind, hash := parts[0], parts[1], parts[2]
+
+		switch kind {
+		case "password":
+			if _, _, _, err := parsePasswordHash(hash); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+			}
+			s.passwords[username] = hash
+		case "token":
+			digest, ok := strings.CutPrefix(hash, "sha256$")
+			if !ok || len(digest) != sha256.Size*2 {
+				return nil, fmt.Errorf("%s:%d: token hash must be sha256$<hex>", path, lineNum)
+			}
+			s.tokens[strings.ToLower(digest)] = username
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown credential kind %q, use password or token", path, lineNum, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Authenticate valida las credenciales de la petición de handshake y retorna
+// el usuario autenticado. Acepta "Authorization: Bearer <token>" o Basic
+// con usuario y contraseña
+func (s *UserStore) Authenticate(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		digest := sha256.Sum256([]byte(token))
+		if username, ok := s.tokens[hex.EncodeToString(digest[:])]; ok && token != "" {
+			return username, nil
+		}
+		return "", ErrUnauthorized
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", ErrUnauthorized
+	}
+	hash, ok := s.passwords[username]
+	if !ok {
+		verifyPassword(dummyHash, password)
+		return "", ErrUnauthorized
+	}
+	if !verifyPassword(hash, password) {
+		return "", ErrUnauthorized
+	}
+	return username, nil
+}
+
+// HashPassword genera el hash pbkdf2 de una contraseña para el archivo de usuarios
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+		passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// NewToken genera un token aleatorio y el hash que va en el archivo de usuarios
+func NewToken() (token, hash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	digest := sha256.Sum256([]byte(token))
+	return token, "sha256$" + hex.EncodeToString(digest[:]), nil
+}
+
+// verifyPassword compara una contraseña con su hash en tiempo constante
+func verifyPassword(hash, password string) bool {
+	iterations, salt, expected, err := parsePasswordHash(hash)
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// parsePasswordHash separa las partes de un hash pbkdf2-sha256$iter$sal$clave
+func parsePasswordHash(hash string) (int, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return 0, nil, nil, errors.New("password hash must be pbkdf2-sha256$<iterations>$<salt>$<key>")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, errors.New("invalid pbkdf2 iterations")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, errors.New("invalid pbkdf2 salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, errors.New("invalid pbkdf2 key")
+	}
+	return iterations, salt, key, nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadUsers escribe un archivo de usuarios y lo carga
+func loadUsers(t *testing.T, content string) (*UserStore, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadUserStore(path)
+}
+
+func TestAuthenticate(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, tokenHash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := loadUsers(t, "# usuarios\n\nalice:password:"+hash+"\ndeploybot:token:"+tokenHash+"\n")
+	if err != nil {
+		t.Fatalf("LoadUserStore: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		user     string // "" no manda Basic
+		password string
+		bearer   string
+		want     string // "" espera ErrUnauthorized
+	}{
+		{name: "password", user: "alice", password: "s3cret", want: "alice"},
+		{name: "wrong password", user: "alice", password: "guess"},
+		{name: "unknown user", user: "mallory", password: "s3cret"},
+		{name: "token", bearer: token, want: "deploybot"},
+		{name: "unknown token", bearer: "not-" + token},
+		{name: "empty token", bearer: " "},
+		{name: "no credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws/chat", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			got, err := users.Authenticate(r)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("Authenticate = %q, %v, want ErrUnauthorized", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Authenticate = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDummyHash(t *testing.T) {
+	// Si no se pudiera leer, verifyPassword volvería sin correr pbkdf2 y el
+	// tiempo de respuesta delataría a los usuarios que no existen
+	iterations, _, _, err := parsePasswordHash(dummyHash)
+	if err != nil || iterations != passwordIterations {
+		t.Errorf("dummy hash = %d iterations, %v, want %d", iterations, err, passwordIterations)
+	}
+}
+
+func TestLoadUserStoreErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string // parte del mensaje de error
+	}{
+		{"missing kind", "alice\n", ":1: expected username:kind:hash"},
+		{"unknown kind", "alice:key:abc\n", `unknown credential kind "key"`},
+		{"bad password hash", "\nalice:password:md5$abc\n", ":2: password hash must be"},
+		{"bad token hash", "bot:token:sha256$abc\n", "token hash must be sha256$<hex>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadUsers(t, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadUserStore = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandshakeRequiresCredentials(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := loadUsers(t, "alice:password:"+hash+"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, url := testHub(t, HubConfig{Users: users}, "den")
+
+	_, resp, err := dialHeader(t, url, "/ws/chat", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without credentials = %v, %v, want 401", resp, err)
+	}
+	if got := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(got, "Basic") {
+		t.Errorf("WWW-Authenticate = %q, want a Basic challenge", got)
+	}
+
+	// El username del mensaje no cuenta, vale el autenticado
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("alice", "s3cret")
+	alice, _, err := dialHeader(t, url, "/ws/chat", r.Header)
+	if err != nil {
+		t.Fatalf("dial with credentials: %v", err)
+	}
+	if welcome := alice.hello(WSMessage{Username: "mallory", Room: "den"}); welcome.Username != "alice" {
+		t.Errorf("welcome for %q, want alice", welcome.Username)
+	}
+	alice.send(WSMessage{Type: protocol.TypeChat, Username: "mallory", Content: "hi"})
+	if msg := alice.expect(protocol.TypeChat); msg.Username != "alice" {
+		t.Errorf("chat from %q, want alice", msg.Username)
+	}
+}
+
+func TestUsernameFixedByHello(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Username: "bob", Content: "hi"})
+	if msg := alice.expect(protocol.TypeChat); msg.Username != "alice" {
+		t.Errorf("chat from %q, want alice", msg.Username)
+	}
+}
@@ -3,62 +3,118 @@ package server
 // esto manejara los clientes individuales
 
 import (
+	"bubblenet/pkg/protocol"
 	"encoding/json"
 	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Valores por defecto de HubConfig
 const (
 	// Tiempo máximo para escribir mensaje
-	writeWait = 10 * time.Second
+	defaultWriteWait = 10 * time.Second
 
 	// Tiempo máximo para leer mensaje
-	pongWait = 60 * time.Second
-
-	// Intervalo de ping
-	pingPeriod = (pongWait * 9) / 10
+	defaultPongWait = 60 * time.Second
 
 	// Tamaño máximo de mensaje
-	maxMessageSize = 512
+	defaultMaxMessageSize = 512
+
+	// Tiempo que tiene el cliente para enviar su hello al conectarse
+	defaultHelloWait = 10 * time.Second
+
+	// Mensajes pendientes por cliente y por sala
+	defaultSendBuffer = 256
+	defaultRoomBuffer = 256
 )
 
-// WSMessage representa un mensaje WebSocket
-type WSMessage struct {
-	Type      string    `json:"type"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	Room      string    `json:"room,omitempty"`
-	Status    string    `json:"status,omitempty"` // online, offline, typing
-}
+// WSMessage representa un mensaje WebSocket, definido en el protocolo compartido
+type WSMessage = protocol.Message
 
 // Client representa una conexión WebSocket individual
 type Client struct {
 	hub      *Hub
 	conn     *websocket.Conn
-	send     chan []byte
+	send     chan outbound
 	username string
 	status   string // online, offline, typing
+
+	// Sesión asignada en el handshake (vacía hasta recibir el hello), lo que
+	// el cliente informó de sí mismo y lo que se acordó con él
+	sessionID    string
+	version      string
+	protocol     int
+	capabilities []string
+
+	// La conexión se perdió pero la sesión se puede retomar hasta que
+	// venza expireTimer (solo lo usa el hub)
+	detached    bool
+	expireTimer *time.Timer
+
+	// Código y motivo del close frame cuando el servidor corta la conexión
+	// (solo los escribe el hub antes de cerrar send)
+	closeCode   int
+	closeReason string
+
+	// Límite de velocidad de la conexión, y sus excesos mientras no tiene
+	// nombre (solo los usa el hub)
+	limiter *limiter
+	flood   floodState
+
+	// Confirmaciones de los últimos mensajes de la sesión (solo las usa el hub)
+	acks ackLog
+
+	// sendMu ordena a quienes encolan en send (el hub y la goroutine de la
+	// sala) y slow indica que ya se avisó al hub que el cliente es lento
+	sendMu sync.Mutex
+	slow   atomic.Bool
+
+	// Sala actual del cliente (solo la modifica el hub)
+	room *Room
+	// Sala indicada en la URL de conexión, si la hay
+	roomHint string
+}
+
+// can indica si el cliente acordó una capacidad en el handshake, "" es el
+// protocolo base y siempre está disponible
+func (c *Client) can(capability string) bool {
+	return capability == "" || slices.Contains(c.capabilities, capability)
+}
+
+// closeSend cierra el canal de envío para que writePump cierre la conexión.
+// Antes hay que sacar al cliente de su sala, así la goroutine de la sala ya
+// no le encola nada
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	close(c.send)
 }
 
 // readPump lee mensajes del WebSocket
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
 	// Configurar límites de lectura
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(c.hub.maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.helloWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
 		return nil
 	})
 
 	// Loop de lectura
+	first := true
 	for {
 		_, messageBytes, err := c.conn.ReadMessage()
 		if err != nil {
@@ -68,6 +124,12 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// El primer mensaje (el hello) llegó a tiempo, desde acá mandan los pongs
+		if first {
+			c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+			first = false
+		}
+
 		// Log del mensaje recibido
 		c.hub.log("📨 Received from client: %s", string(messageBytes))
 
@@ -76,46 +138,26 @@ func (c *Client) readPump() {
 		if err := json.Unmarshal(messageBytes, &wsMsg); err != nil {
 			// Si no es JSON válido, crear mensaje simple
 			wsMsg = WSMessage{
-				Type:      "chat",
+				Type:      protocol.TypeChat,
 				Username:  "Unknown",
 				Content:   string(messageBytes),
 				Timestamp: time.Now(),
 			}
 		}
 
-		// Actualizar información del cliente
-		if wsMsg.Username != "" && c.username == "" {
-			c.username = wsMsg.Username
-			c.status = "online"
-			
-			// Enviar mensaje de sistema notificando que el usuario se conectó
-			systemMsg := WSMessage{
-				Type:      "system",
-				Username:  "System",
-				Content:   c.username + " joined the chat",
-				Timestamp: time.Now(),
-			}
-			if systemBytes, err := json.Marshal(systemMsg); err == nil {
-				c.hub.broadcast <- systemBytes
-			}
-			
-			// Enviar lista actualizada de usuarios
-			c.hub.BroadcastUserList()
-		}
-
-		// Reenviar el mensaje procesado como JSON
-		if processedBytes, err := json.Marshal(wsMsg); err == nil {
-			c.hub.broadcast <- processedBytes
-		} else {
-			// Fallback: enviar mensaje original
-			c.hub.broadcast <- messageBytes
+		// Enviar al hub para que lo enrute a la sala correspondiente
+		select {
+		case c.hub.inbound <- inboundMessage{client: c, message: wsMsg, size: len(messageBytes)}:
+		case <-c.hub.done:
+			return
 		}
 	}
 }
 
 // writePump escribe mensajes al WebSocket
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	// Ping un poco antes de que venza el plazo del pong
+	ticker := time.NewTicker(c.hub.pongWait * 9 / 10)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -124,10 +166,14 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
 			if !ok {
-				// Hub cerró el canal
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				// Hub cerró el canal, con un motivo si nos desconectó él
+				closeMsg := []byte{}
+				if c.closeCode != 0 {
+					closeMsg = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMsg)
 				return
 			}
 
@@ -135,13 +181,22 @@ func (c *Client) writePump() {
 			if err != nil {
 				return
 			}
-			w.Write(message)
-
-			// Agregar mensajes adicionales en cola
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+			w.Write(message.data)
+
+			// Agregar mensajes adicionales en cola, sin esperar: con las
+			// políticas de clientes lentos otros pueden vaciar el buffer
+		batch:
+			for n := len(c.send); n > 0; n-- {
+				select {
+				case queued, ok := <-c.send:
+					if !ok {
+						break batch
+					}
+					w.Write([]byte{'\n'})
+					w.Write(queued.data)
+				default:
+					break batch
+				}
 			}
 
 			if err := w.Close(); err != nil {
@@ -149,7 +204,7 @@ func (c *Client) writePump() {
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"testing"
+)
+
+func TestDirectMessage(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den", "lab")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "lab")
+	carol, _ := join(t, url, "carol", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeDM, To: "bob", Content: "psst"})
+	got := bob.expect(protocol.TypeDM)
+	if got.Username != "alice" || got.To != "bob" || got.Content != "psst" || got.ID == "" {
+		t.Errorf("bob got %+v, want psst from alice", got)
+	}
+	// El remitente recibe el mismo mensaje, con el mismo ID
+	if echo := alice.expect(protocol.TypeDM); echo.ID != got.ID {
+		t.Errorf("alice's copy has ID %s, want %s", echo.ID, got.ID)
+	}
+
+	// A carol, que comparte sala con alice, no le llega
+	carol.send(WSMessage{Type: protocol.TypeChat, Content: "marker"})
+	for {
+		msg, err := carol.read()
+		if err != nil {
+			t.Fatalf("waiting for the marker: %v", err)
+		}
+		if msg.Type == protocol.TypeDM {
+			t.Fatalf("carol received a direct message: %+v", msg)
+		}
+		if msg.Type == protocol.TypeChat && msg.Content == "marker" {
+			break
+		}
+	}
+}
+
+func TestDirectMessageErrors(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeDM, To: "nobody", Content: "hello?"})
+	alice.expectError(errUserOffline)
+	alice.send(WSMessage{Type: protocol.TypeDM, Content: "to whom?"})
+	alice.expectError(errUserOffline)
+	alice.send(WSMessage{Type: protocol.TypeDM, To: "alice"})
+	alice.expectError(errInvalidMessage)
+}
+
+func TestDirectMessageNotNegotiated(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob := dial(t, url)
+	bob.hello(WSMessage{Username: "bob", Capabilities: []string{protocol.CapTyping}})
+
+	// bob no podría mostrarlo, alice se entera en vez de perderlo
+	alice.send(WSMessage{Type: protocol.TypeDM, To: "bob", Content: "psst"})
+	alice.expectError(errNotNegotiated)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"testing"
+)
+
+func TestEditAndDelete(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "helo"})
+	original := bob.expectContent(protocol.TypeChat, "helo")
+
+	alice.send(WSMessage{Type: protocol.TypeEdit, RefID: original.ID, Content: "hello"})
+	if edit := bob.expect(protocol.TypeEdit); edit.RefID != original.ID || edit.Content != "hello" || !edit.Edited {
+		t.Errorf("edit = %+v, want hello for %s", edit, original.ID)
+	}
+
+	alice.send(WSMessage{Type: protocol.TypeDelete, RefID: original.ID})
+	if del := bob.expect(protocol.TypeDelete); del.RefID != original.ID || del.Content != "" || !del.Deleted {
+		t.Errorf("delete = %+v, want the tombstone of %s", del, original.ID)
+	}
+
+	// El historial tiene el borrado en el lugar del mensaje
+	bob.send(WSMessage{Type: protocol.TypeHistoryRequest})
+	page := bob.expect(protocol.TypeHistoryPage)
+	if len(page.Messages) != 1 || page.Messages[0].ID != original.ID || !page.Messages[0].Deleted {
+		t.Errorf("history = %+v, want only the tombstone", page.Messages)
+	}
+
+	alice.send(WSMessage{Type: protocol.TypeEdit, RefID: original.ID, Content: "back"})
+	alice.expectError(errMessageNotFound)
+}
+
+func TestEditErrors(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den", "lab")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "mine"})
+	mine := bob.expectContent(protocol.TypeChat, "mine")
+
+	tests := []struct {
+		name string
+		from *testConn
+		msg  WSMessage
+		code string
+	}{
+		{"someone else's message", bob, WSMessage{Type: protocol.TypeEdit, RefID: mine.ID, Content: "theirs"}, errForbidden},
+		{"someone else's delete", bob, WSMessage{Type: protocol.TypeDelete, RefID: mine.ID}, errForbidden},
+		{"empty edit", alice, WSMessage{Type: protocol.TypeEdit, RefID: mine.ID}, errInvalidMessage},
+		{"unknown message", alice, WSMessage{Type: protocol.TypeDelete, RefID: "nope"}, errMessageNotFound},
+		{"other room", alice, WSMessage{Type: protocol.TypeDelete, RefID: mine.ID, Room: "lab"}, errNotInRoom},
+	}
+	for _, tt := range tests {
+		tt.from.send(tt.msg)
+		if msg := tt.from.expect(protocol.TypeError); msg.Code != tt.code {
+			t.Errorf("%s: error %q (%s), want %q", tt.name, msg.Code, msg.Content, tt.code)
+		}
+	}
+}
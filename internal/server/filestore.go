@@ -0,0 +1,417 @@
+package server
+
+// historial persistente en archivos JSONL, uno por sala
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileStore guarda el historial como un archivo append-only por sala
+// (<dir>/<sala>.jsonl). En memoria queda solo un índice con la posición de
+// cada mensaje en el archivo y una copia de los últimos fileTailSize, que son
+// los que se mandan al entrar a una sala; las páginas más viejas se leen
+// del disco.
+// Los roles y bans de cada sala van aparte, en <dir>/<sala>.moderation.json,
+// y las salas creadas por usuarios en <dir>/rooms.json.
+// Las ediciones y borrados se agregan como una nueva línea con el mismo ID,
+// y el índice apunta a la última versión de cada ID
+type FileStore struct {
+	mu    sync.RWMutex
+	dir   string
+	rooms map[string]*fileRoom
+}
+
+// fileTailSize es cuántos de los últimos mensajes de cada sala se guardan
+// también en memoria; alcanza para el historial que se manda al entrar y
+// para lo que se reenvía al reanudar una sesión
+const fileTailSize = 256
+
+// fileRoom es el historial de una sala: su archivo, dónde está cada mensaje
+// y los últimos mensajes
+type fileRoom struct {
+	file  *os.File
+	size  int64       // largo del archivo, donde va la próxima línea
+	index []fileEntry // un elemento por mensaje, ordenados por Seq
+	tail  []WSMessage // los últimos mensajes, los mismos que el final de index
+}
+
+// fileEntry ubica la última versión de un mensaje en el archivo
+type fileEntry struct {
+	id     string
+	seq    int64
+	offset int64
+	length int
+}
+
+// NewFileStore abre (o crea) el directorio de historial e indexa los mensajes guardados
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+
+	s := &FileStore{
+		dir:   dir,
+		rooms: make(map[string]*fileRoom),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		room, err := openFileRoom(path)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("loading history of #%s: %w", name, err)
+		}
+		s.rooms[name] = room
+	}
+
+	return s, nil
+}
+
+// openFileRoom abre el archivo de una sala y arma su índice, ignorando
+// líneas corruptas. Una línea con un ID ya leído reemplaza al mensaje
+// original en su lugar
+func openFileRoom(path string) (*fileRoom, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	room := &fileRoom{file: f}
+	if err := room.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return room, nil
+}
+
+// load lee el archivo entero una vez para armar el índice y la copia de
+// los últimos mensajes
+func (r *fileRoom) load() error {
+	byID := make(map[string]int)
+	reader := bufio.NewReaderSize(r.file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		offset := r.size
+		r.size += int64(len(line))
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var msg WSMessage
+			if json.Unmarshal(line, &msg) == nil {
+				entry := fileEntry{id: msg.ID, seq: msg.Seq, offset: offset, length: len(line)}
+				if i, ok := byID[msg.ID]; ok && msg.ID != "" {
+					entry.seq = r.index[i].seq
+					r.index[i] = entry
+				} else {
+					if msg.ID != "" {
+						byID[msg.ID] = len(r.index)
+					}
+					// Historiales guardados antes de existir Seq se numeran por posición
+					if entry.seq == 0 {
+						entry.seq = int64(len(r.index) + 1)
+					}
+					r.index = append(r.index, entry)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Una línea cortada a medias (un corte al escribir) se termina, así la
+	// próxima queda en su propia línea
+	if r.size > 0 {
+		last := make([]byte, 1)
+		if _, err := r.file.ReadAt(last, r.size-1); err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			if _, err := r.file.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+			r.size++
+		}
+	}
+
+	start := max(len(r.index)-fileTailSize, 0)
+	tail, err := r.read(r.index[start:])
+	r.tail = tail
+	return err
+}
+
+// read lee del archivo los mensajes de entries
+func (r *fileRoom) read(entries []fileEntry) ([]WSMessage, error) {
+	msgs := make([]WSMessage, 0, len(entries))
+	for _, entry := range entries {
+		line := make([]byte, entry.length)
+		if _, err := r.file.ReadAt(line, entry.offset); err != nil {
+			return nil, err
+		}
+		var msg WSMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("reading message %s: %w", entry.id, err)
+		}
+		msg.Seq = entry.seq
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// messages retorna los mensajes de index[start:end], de la copia en
+// memoria los que están y del archivo el resto
+func (r *fileRoom) messages(start, end int) ([]WSMessage, error) {
+	tailStart := len(r.index) - len(r.tail)
+	if start >= tailStart {
+		return slices.Clone(r.tail[start-tailStart : end-tailStart]), nil
+	}
+	older, err := r.read(r.index[start:min(end, tailStart)])
+	if err != nil || end <= tailStart {
+		return older, err
+	}
+	return append(older, r.tail[:end-tailStart]...), nil
+}
+
+// indexOf retorna la posición del mensaje con ese ID, -1 si no está
+func (r *fileRoom) indexOf(id string) int {
+	for i := len(r.index) - 1; i >= 0; i-- {
+		if r.index[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// write agrega un mensaje como línea JSON al archivo y retorna dónde quedó
+func (r *fileRoom) write(msg WSMessage) (fileEntry, error) {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	line = append(line, '\n')
+	if _, err := r.file.Write(line); err != nil {
+		return fileEntry{}, err
+	}
+	entry := fileEntry{id: msg.ID, seq: msg.Seq, offset: r.size, length: len(line)}
+	r.size += int64(len(line))
+	return entry, nil
+}
+
+// room retorna el historial de una sala, creando su archivo si hace falta
+// (con mu tomado)
+func (s *FileStore) room(name string) (*fileRoom, error) {
+	if room, ok := s.rooms[name]; ok {
+		return room, nil
+	}
+	room, err := openFileRoom(filepath.Join(s.dir, name+".jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	s.rooms[name] = room
+	return room, nil
+}
+
+// Append agrega un mensaje al archivo de su sala
+func (s *FileStore) Append(msg WSMessage) (WSMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, err := s.room(msg.Room)
+	if err != nil {
+		return msg, err
+	}
+
+	msg.Seq = 1
+	if n := len(room.index); n > 0 {
+		msg.Seq = room.index[n-1].seq + 1
+	}
+	entry, err := room.write(msg)
+	if err != nil {
+		return msg, err
+	}
+	room.index = append(room.index, entry)
+	if len(room.tail) == fileTailSize {
+		room.tail = slices.Delete(room.tail, 0, 1)
+	}
+	room.tail = append(room.tail, msg)
+	return msg, nil
+}
+
+// Find busca un mensaje de una sala por su ID
+func (s *FileStore) Find(room, id string) (WSMessage, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rooms[room]
+	if !ok {
+		return WSMessage{}, false, nil
+	}
+	i := r.indexOf(id)
+	if i < 0 {
+		return WSMessage{}, false, nil
+	}
+	msgs, err := r.messages(i, i+1)
+	if err != nil {
+		return WSMessage{}, false, err
+	}
+	return msgs[0], true, nil
+}
+
+// Replace agrega la nueva versión del mensaje al archivo y la apunta en el índice
+func (s *FileStore) Replace(msg WSMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[msg.Room]
+	if !ok {
+		return errMessageNotStored
+	}
+	i := room.indexOf(msg.ID)
+	if i < 0 {
+		return errMessageNotStored
+	}
+	msg.Seq = room.index[i].seq
+	entry, err := room.write(msg)
+	if err != nil {
+		return err
+	}
+	room.index[i] = entry
+	if j := i - (len(room.index) - len(room.tail)); j >= 0 {
+		room.tail[j] = msg
+	}
+	return nil
+}
+
+// Recent retorna los últimos n mensajes de una sala
+func (s *FileStore) Recent(room string, n int) ([]WSMessage, error) {
+	return s.Before(room, 0, n)
+}
+
+// Before retorna hasta limit mensajes de una sala anteriores a before
+func (s *FileStore) Before(room string, before int64, limit int) ([]WSMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rooms[room]
+	if !ok {
+		return []WSMessage{}, nil
+	}
+	end := len(r.index)
+	if before > 0 {
+		end = sort.Search(len(r.index), func(i int) bool {
+			return r.index[i].seq >= before
+		})
+	}
+	return r.messages(max(end-limit, 0), end)
+}
+
+// moderationPath retorna el archivo con los roles y bans de una sala
+func (s *FileStore) moderationPath(room string) string {
+	return filepath.Join(s.dir, room+".moderation.json")
+}
+
+// LoadModeration lee los roles y bans de una sala, si hay alguno guardado
+func (s *FileStore) LoadModeration(room string) (RoomModeration, error) {
+	var mod RoomModeration
+	data, err := os.ReadFile(s.moderationPath(room))
+	if errors.Is(err, fs.ErrNotExist) {
+		return mod, nil
+	}
+	if err != nil {
+		return mod, err
+	}
+	if err := json.Unmarshal(data, &mod); err != nil {
+		return mod, fmt.Errorf("reading moderation of #%s: %w", room, err)
+	}
+	return mod, nil
+}
+
+// SaveModeration escribe los roles y bans de una sala
+func (s *FileStore) SaveModeration(room string, mod RoomModeration) error {
+	return writeJSON(s.moderationPath(room), mod)
+}
+
+// roomsPath retorna el archivo con las salas creadas por usuarios. No choca
+// con el historial de una sala llamada "rooms", que es rooms.jsonl
+func (s *FileStore) roomsPath() string {
+	return filepath.Join(s.dir, "rooms.json")
+}
+
+// SaveRoom agrega una sala creada por un usuario al archivo de salas, o la
+// reemplaza si ya estaba
+func (s *FileStore) SaveRoom(room SavedRoom) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms, err := s.loadRooms()
+	if err != nil {
+		return err
+	}
+	rooms = append(slices.DeleteFunc(rooms, func(r SavedRoom) bool {
+		return r.Name == room.Name
+	}), room)
+	return writeJSON(s.roomsPath(), rooms)
+}
+
+// LoadRooms lee las salas creadas por usuarios, si hay alguna guardada
+func (s *FileStore) LoadRooms() ([]SavedRoom, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadRooms()
+}
+
+func (s *FileStore) loadRooms() ([]SavedRoom, error) {
+	var rooms []SavedRoom
+	data, err := os.ReadFile(s.roomsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &rooms); err != nil {
+		return nil, fmt.Errorf("reading saved rooms: %w", err)
+	}
+	return rooms, nil
+}
+
+// writeJSON escribe v en un archivo temporal y lo renombra, así un corte no
+// deja el archivo a medias
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Close sincroniza y cierra los archivos abiertos
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for name, room := range s.rooms {
+		if err := room.file.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := room.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.rooms, name)
+	}
+	return firstErr
+}
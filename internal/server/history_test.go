@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// postAll manda un chat por contenido y espera a que vuelva cada uno
+func postAll(c *testConn, contents ...string) {
+	c.t.Helper()
+	for _, content := range contents {
+		c.send(WSMessage{Type: protocol.TypeChat, Content: content})
+		c.expectContent(protocol.TypeChat, content)
+	}
+}
+
+func TestHistoryRequest(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	postAll(alice, "a", "b", "c", "d", "e")
+
+	alice.send(WSMessage{Type: protocol.TypeHistoryRequest, Limit: 2})
+	page := alice.expect(protocol.TypeHistoryPage)
+	if got := contents(page.Messages); page.Room != "den" || !slices.Equal(got, []string{"d", "e"}) || !page.HasMore {
+		t.Fatalf("first page of %q = %v (has_more %t), want [d e] with more", page.Room, got, page.HasMore)
+	}
+
+	// La siguiente página empieza antes del mensaje más viejo recibido
+	alice.send(WSMessage{Type: protocol.TypeHistoryRequest, Before: page.Messages[0].Seq, Limit: 3})
+	page = alice.expect(protocol.TypeHistoryPage)
+	if got := contents(page.Messages); !slices.Equal(got, []string{"a", "b", "c"}) || page.HasMore {
+		t.Errorf("last page = %v (has_more %t), want [a b c] and no more", got, page.HasMore)
+	}
+
+	alice.send(WSMessage{Type: protocol.TypeHistoryRequest, Room: "nowhere"})
+	alice.expectError(errRoomNotFound)
+}
+
+func TestHistoryRequestPrivateRoom(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "lab", Private: true})
+	alice.expectContent(protocol.TypeSystem, "alice joined #lab")
+	postAll(alice, "secret")
+
+	bob, _ := join(t, url, "bob", defaultRoom)
+	bob.send(WSMessage{Type: protocol.TypeHistoryRequest, Room: "lab"})
+	bob.expectError(errNotInRoom)
+}
+
+func TestRoomMessagesEndpoint(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	postAll(alice, "a", "b", "c")
+
+	var page struct {
+		Room     string      `json:"room"`
+		Messages []WSMessage `json:"messages"`
+		HasMore  bool        `json:"has_more"`
+	}
+	if code := getJSON(t, url+"/rooms/den/messages?before=3&limit=1", &page); code != http.StatusOK {
+		t.Fatalf("GET messages = %d", code)
+	}
+	if got := contents(page.Messages); page.Room != "den" || !slices.Equal(got, []string{"b"}) || !page.HasMore {
+		t.Errorf("page of %q = %v (has_more %t), want [b] with more", page.Room, got, page.HasMore)
+	}
+
+	// Una sala sin mensajes da una lista vacía, no null
+	page.Messages = nil
+	getJSON(t, url+"/rooms/general/messages", &page)
+	if page.Messages == nil || len(page.Messages) != 0 || page.HasMore {
+		t.Errorf("empty room page = %v (has_more %t), want []", page.Messages, page.HasMore)
+	}
+}
+
+func TestRoomMessagesEndpointErrors(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "lab", Private: true})
+	alice.expectContent(protocol.TypeSystem, "alice joined #lab")
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/rooms/nowhere/messages", http.StatusNotFound},
+		{"/rooms/lab/messages", http.StatusForbidden},
+		{"/rooms/general/messages?before=soon", http.StatusBadRequest},
+		{"/rooms/general/messages?limit=lots", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := getJSON(t, url+tt.path, nil); code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, code, tt.want)
+		}
+	}
+}
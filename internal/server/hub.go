@@ -1,102 +1,1012 @@
 package server
 
 import (
+	"bubblenet/pkg/protocol"
+	"cmp"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 )
 
+// Códigos de error enviados en los mensajes de tipo error
+const (
+	errInvalidRoomName = protocol.CodeInvalidRoomName
+	errInvalidCapacity = protocol.CodeInvalidCapacity
+	errRoomExists      = protocol.CodeRoomExists
+	errRoomNotFound    = protocol.CodeRoomNotFound
+	errRoomFull        = protocol.CodeRoomFull
+	errInviteRequired  = protocol.CodeInviteRequired
+	errInvalidInvite   = protocol.CodeInvalidInvite
+	errNotInRoom       = protocol.CodeNotInRoom
+	errRoomNotPrivate  = protocol.CodeRoomNotPrivate
+	errUserOffline     = protocol.CodeUserOffline
+	errMessageNotFound = protocol.CodeMessageNotFound
+	errInvalidMessage  = protocol.CodeInvalidMessage
+	errForbidden       = protocol.CodeForbidden
+	errInternal        = protocol.CodeInternal
+	errNotNegotiated   = protocol.CodeNotNegotiated
+)
+
+const (
+	// Tamaño de página por defecto y máximo al pedir historial
+	defaultHistoryPage = 50
+	maxHistoryPage     = 100
+)
+
+// HubConfig agrupa la configuración del hub
+type HubConfig struct {
+	Debug bool
+
+	// Historial de mensajes y cuántos se envían al entrar a una sala
+	Store         Store
+	HistoryReplay int
+
+	// Usuarios registrados, nil desactiva la autenticación y cada cliente
+	// se identifica con el username de su hello
+	Users *UserStore
+
+	// Mensaje del día que se envía en el welcome
+	MOTD string
+
+	// Tiempo durante el que se puede retomar una sesión después de perder
+	// la conexión, 0 desactiva la reanudación
+	ResumeWindow time.Duration
+
+	// Qué hacer cuando se llena el buffer de envío de un cliente
+	SlowConsumer SlowConsumerPolicy
+
+	// Orígenes desde los que se aceptan conexiones, nil acepta solo el mismo
+	// origen que el servidor
+	Origins *OriginPolicy
+
+	// Límites de velocidad por conexión y por usuario (todas sus conexiones
+	// juntas), y cuánto dura el silencio de quien los excede seguido
+	RateLimit     RateLimit
+	UserRateLimit RateLimit
+	MuteDuration  time.Duration
+
+	// Timeouts y tamaños de cada conexión; 0 usa el valor por defecto
+	WriteWait      time.Duration
+	PongWait       time.Duration
+	HelloWait      time.Duration
+	MaxMessageSize int64
+	SendBuffer     int // mensajes pendientes por cliente
+	RoomBuffer     int // mensajes pendientes por sala
+
+	// Salas que se crean al arrancar, además de la sala por defecto
+	Rooms []RoomConfig
+}
+
 // Hub maneja todas las conexiones WebSocket
 type Hub struct {
 	// Configuración
-	debug bool
+	debug         atomic.Bool
+	historyReplay int // mensajes del historial que se envían al entrar a una sala
+
+	// Timeouts y tamaños de las conexiones, fijos mientras corre el servidor
+	writeWait      time.Duration
+	pongWait       time.Duration
+	helloWait      time.Duration
+	maxMessageSize int64
+	sendBuffer     int
+	roomBuffer     int
+
+	// Historial de mensajes
+	store Store
+
+	// Usuarios registrados (nil si no hay autenticación)
+	users *UserStore
+
+	// Mensaje del día
+	motd string
 
-	// Registro de clientes
+	// Sesiones por ID, incluidas las que perdieron la conexión y todavía se
+	// pueden retomar durante resumeWindow (solo las usa Run)
+	sessions     map[string]*Client
+	resumeWindow time.Duration
+
+	// Límites de velocidad, baldes compartidos y excesos por usuario (solo
+	// los usa Run)
+	connRate     RateLimit
+	userRate     RateLimit
+	userLimits   map[string]*limiter
+	floods       map[string]*floodState
+	muteDuration time.Duration
+
+	// Política para los clientes lentos (se puede cambiar con Reload)
+	slowPolicy atomic.Pointer[SlowConsumerPolicy]
+
+	// Contadores que se exponen en /metrics
+	metrics *metrics
+
+	// Generador de IDs de mensajes
+	ids idGenerator
+
+	// Registro de clientes y salas activas por nombre,
+	// mu protege las lecturas desde los handlers HTTP
+	mu      sync.RWMutex
 	clients map[*Client]bool
+	rooms   map[string]*Room
+
+	// Invitaciones a salas privadas (solo las usa Run)
+	invites *inviteStore
 
 	// Canales para comunicación
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan []byte
+	register    chan *Client
+	unregister  chan *Client
+	expire      chan *Client // sesiones desconectadas cuyo plazo para retomarlas venció
+	slowClients chan *Client // clientes que no leen sus mensajes a tiempo
+	inbound     chan inboundMessage
+	reload      chan HubConfig
+
+	// Apagado: draining rechaza conexiones nuevas, quit le pide a Run que
+	// cierre todo y done se cierra cuando Run termina. pumps espera a que
+	// cada writePump mande su close frame
+	draining atomic.Bool
+	quit     chan struct{}
+	done     chan struct{}
+	pumps    sync.WaitGroup
 
-	// WebSocket upgrader
+	// WebSocket upgrader y orígenes que acepta (se pueden cambiar con Reload)
+	origins  atomic.Pointer[OriginPolicy]
 	upgrader websocket.Upgrader
 }
 
 // NewHub crea un nuevo hub
-func NewHub(debug bool) *Hub {
-	return &Hub{
-		debug:      debug,
-		clients:    make(map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// En desarrollo, aceptar cualquier origen
-				// En producción, validar origins específicos
+func NewHub(config HubConfig) *Hub {
+	h := &Hub{
+		historyReplay:  config.HistoryReplay,
+		writeWait:      cmp.Or(config.WriteWait, defaultWriteWait),
+		pongWait:       cmp.Or(config.PongWait, defaultPongWait),
+		helloWait:      cmp.Or(config.HelloWait, defaultHelloWait),
+		maxMessageSize: cmp.Or(config.MaxMessageSize, defaultMaxMessageSize),
+		sendBuffer:     cmp.Or(config.SendBuffer, defaultSendBuffer),
+		roomBuffer:     cmp.Or(config.RoomBuffer, defaultRoomBuffer),
+		store:          config.Store,
+		users:          config.Users,
+		motd:           config.MOTD,
+		sessions:       make(map[string]*Client),
+		resumeWindow:   config.ResumeWindow,
+		connRate:       config.RateLimit,
+		userRate:       config.UserRateLimit,
+		userLimits:     make(map[string]*limiter),
+		floods:         make(map[string]*floodState),
+		muteDuration:   config.MuteDuration,
+		metrics:        newMetrics(),
+		clients:        make(map[*Client]bool),
+		rooms:          make(map[string]*Room),
+		invites:        newInviteStore(),
+		register:       make(chan *Client),
+		unregister:     make(chan *Client),
+		expire:         make(chan *Client),
+		slowClients:    make(chan *Client),
+		quit:           make(chan struct{}),
+		done:           make(chan struct{}),
+		inbound:        make(chan inboundMessage),
+		reload:         make(chan HubConfig),
+	}
+	h.debug.Store(config.Debug)
+	h.slowPolicy.Store(&config.SlowConsumer)
+	h.origins.Store(config.Origins)
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			// El upgrader responde 403 a los orígenes rechazados
+			if h.origins.Load().Check(r) {
 				return true
-			},
+			}
+			log.Printf("🚫 Rejected WebSocket from origin %s (%s)", r.Header.Get("Origin"), r.RemoteAddr)
+			return false
 		},
 	}
+
+	// La sala por defecto siempre existe, las demás son las que crearon los
+	// usuarios y las de la configuración
+	h.createRoom(defaultRoom, defaultMaxUsers, false)
+	h.restoreRooms()
+	h.createConfiguredRooms(config.Rooms)
+
+	return h
+}
+
+// inboundMessage es un mensaje recibido de un cliente
+type inboundMessage struct {
+	client  *Client
+	message WSMessage
+	size    int // bytes del frame, para los límites de velocidad
 }
 
-// Run ejecuta el loop principal del hub
+// Run ejecuta el loop principal del hub hasta que se llama a Shutdown
 func (h *Hub) Run() {
+	defer close(h.done)
 	for {
 		select {
+		case <-h.quit:
+			// El servidor se apaga: avisar y cerrar todas las conexiones
+			h.closeAll()
+			return
+
 		case client := <-h.register:
 			// Nuevo cliente se conecta
+			h.mu.Lock()
 			h.clients[client] = true
+			h.mu.Unlock()
 			h.log("✅ Client connected. Total clients: %d", len(h.clients))
 
 		case client := <-h.unregister:
-			// Cliente se desconecta
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				h.log("❌ Client disconnected. Total clients: %d", len(h.clients))
-				
-				// Enviar mensaje de sistema si el cliente tenía un username
-				if client.username != "" {
-					systemMsg := map[string]interface{}{
-						"type":      "system",
-						"username":  "System",
-						"content":   client.username + " left the chat",
-						"timestamp": time.Now(),
-					}
-					if msgBytes, err := json.Marshal(systemMsg); err == nil {
-						h.broadcast <- msgBytes
+			// Cliente se desconecta, si tiene sesión se la guarda un rato
+			// por si vuelve a conectarse
+			if _, ok := h.clients[client]; !ok {
+				continue
+			}
+			if client.sessionID != "" && h.resumeWindow > 0 {
+				h.detach(client)
+				client.expireTimer = time.AfterFunc(h.resumeWindow, func() {
+					select {
+					case h.expire <- client:
+					case <-h.done:
 					}
-					
-					// Enviar lista actualizada de usuarios
-					h.BroadcastUserList()
-				}
+				})
+			} else {
+				h.removeClient(client)
 			}
 
-		case message := <-h.broadcast:
-			// Broadcast mensaje a todos los clientes
-			h.log("📢 Broadcasting message to %d clients", len(h.clients))
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-					// Mensaje enviado exitosamente
-				default:
-					// Cliente no puede recibir, desconectarlo
-					delete(h.clients, client)
-					close(client.send)
-				}
+		case client := <-h.expire:
+			// Venció el plazo para retomar la sesión, recién ahora sale de la sala
+			if h.sessions[client.sessionID] == client {
+				h.log("⌛ Session %s of %s expired", client.sessionID, client.username)
+				delete(h.sessions, client.sessionID)
+				h.leaveRoom(client)
+				h.forgetUserLimiter(client.username)
 			}
+
+		case client := <-h.slowClients:
+			// Cliente que no lee sus mensajes, se lo desconecta con aviso
+			h.dropSlow(client)
+
+		case config := <-h.reload:
+			// SIGHUP: aplicar la parte de la configuración que se puede
+			// cambiar en caliente
+			h.applyReload(config)
+
+		case in := <-h.inbound:
+			h.metrics.received.inc(typeLabel(in.message.Type))
+			// Mensaje de un cliente, se enruta a su sala si no excede los límites
+			if _, ok := h.clients[in.client]; ok && h.allow(in.client, in.message, in.size) {
+				h.handleMessage(in.client, in.message)
+			}
+		}
+	}
+}
+
+// removeClient saca al cliente de su sala y del registro, y cierra su canal
+// de envío para que writePump cierre la conexión
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	h.leaveRoom(client)
+	if h.sessions[client.sessionID] == client {
+		delete(h.sessions, client.sessionID)
+	}
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+	client.closeSend()
+	h.forgetUserLimiter(client.username)
+	h.log("❌ Client disconnected. Total clients: %d", len(h.clients))
+}
+
+// detach cierra la conexión de un cliente pero conserva su sesión y su
+// lugar en la sala, sin avisar a nadie, para que pueda retomarla
+func (h *Hub) detach(client *Client) {
+	// Fuera de la sala antes de cerrar send, así su goroutine no le escribe
+	if client.room != nil {
+		client.room.detach(client)
+	}
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+	client.detached = true
+	client.closeSend()
+	h.log("💤 %s lost connection, session %s kept for %s", client.username, client.sessionID, h.resumeWindow)
+}
+
+// disconnect cierra la conexión de un cliente con un código y un motivo
+// que recibe en el close frame
+func (h *Hub) disconnect(client *Client, code int, reason string) {
+	h.log("⛔ Disconnecting %s: %s", client.conn.RemoteAddr(), reason)
+	client.closeCode = code
+	client.closeReason = reason
+	h.removeClient(client)
+}
+
+// handleMessage procesa un mensaje entrante según su tipo
+func (h *Hub) handleMessage(client *Client, msg WSMessage) {
+	// Los tipos que solo manda el servidor no se aceptan de un cliente
+	if !protocol.FromClient(msg.Type) {
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("unknown message type %q", msg.Type))
+		return
+	}
+
+	// Hasta completar el handshake solo se acepta el hello
+	if msg.Type == protocol.TypeHello {
+		h.handleHello(client, msg)
+		return
+	}
+	if client.sessionID == "" {
+		h.sendError(client, errHelloRequired, "send a hello frame before anything else")
+		return
+	}
+
+	// Los pedidos de funciones que no se acordaron en el handshake se rechazan
+	if capability := protocol.CapabilityFor(msg.Type); !client.can(capability) {
+		h.sendError(client, errNotNegotiated, fmt.Sprintf("%s requires the %q capability, which was not negotiated", msg.Type, capability))
+		return
+	}
+
+	// La identidad la decide el servidor, no el mensaje
+	msg.Username = client.username
+
+	switch msg.Type {
+	case protocol.TypeNick:
+		h.handleNick(client, msg)
+
+	case protocol.TypeJoin:
+		h.joinRoom(client, h.resolveRoom(client, msg.Room), msg.InviteCode)
+
+	case protocol.TypeCreateRoom:
+		h.handleCreateRoom(client, msg)
+
+	case protocol.TypeInvite:
+		h.handleInvite(client, msg)
+
+	case protocol.TypeKick, protocol.TypeBan, protocol.TypeUnban, protocol.TypeOp, protocol.TypeDeop, protocol.TypeMute, protocol.TypeUnmute:
+		h.handleModeration(client, msg)
+
+	case protocol.TypeSlowMode, protocol.TypeReadOnly:
+		h.handleRoomRestriction(client, msg)
+
+	case protocol.TypeHistoryRequest:
+		h.handleHistoryRequest(client, msg)
+
+	case protocol.TypeEdit, protocol.TypeDelete:
+		h.handleModify(client, msg)
+
+	case protocol.TypeDM:
+		h.handleDirectMessage(client, msg)
+
+	case protocol.TypeTyping:
+		h.handleTyping(client, msg)
+
+	case protocol.TypeLeave:
+		h.leaveRoom(client)
+
+	case protocol.TypeRoomList:
+		h.sendTo(client, h.roomListMessage())
+
+	case protocol.TypeChat:
+		h.handleChat(client, msg)
+	}
+}
+
+// handleChat guarda un mensaje de chat y lo entrega solo a la sala
+// correspondiente. Si el cliente le puso un ID, le confirma la entrega con
+// un ack y descarta los reenvíos del mismo mensaje
+func (h *Hub) handleChat(client *Client, msg WSMessage) {
+	clientID := msg.ClientID
+	msg.ClientID = ""
+	if ack, ok := client.acks.get(clientID); ok {
+		h.log("🔁 Duplicate message %s from %s, acking again", clientID, client.username)
+		h.sendTo(client, ack)
+		return
+	}
+
+	roomName := h.resolveRoom(client, msg.Room)
+	if client.room == nil || client.room.name != roomName {
+		room, code, reason := h.admit(client, roomName, "")
+		if room == nil {
+			h.rejectChat(client, clientID, code, reason)
+			return
+		}
+		h.moveTo(client, room)
+	}
+	// Todo lo que se publica acá cuenta como chat para el slow mode
+	if code, reason := h.restrain(client, client.room, protocol.TypeChat); code != "" {
+		h.rejectChat(client, clientID, code, reason)
+		return
+	}
+	msg.Room = roomName
+	h.stamp(&msg)
+	client.room.notePost(client.username, msg.Timestamp)
+
+	if stored, err := h.store.Append(msg); err != nil {
+		log.Printf("❌ Error saving message to history: %v", err)
+	} else {
+		msg = stored
+	}
+
+	if clientID != "" {
+		h.ackChat(client, clientID, msg)
+	}
+	client.room.send(msg)
+}
+
+// resolveRoom decide a qué sala va un mensaje: la indicada en el mensaje,
+// la sala actual del cliente, la de la URL o la sala por defecto
+func (h *Hub) resolveRoom(client *Client, requested string) string {
+	switch {
+	case requested != "":
+		return requested
+	case client.room != nil:
+		return client.room.name
+	case client.roomHint != "":
+		return client.roomHint
+	default:
+		return defaultRoom
+	}
+}
+
+// joinRoom mueve al cliente a la sala indicada, si existe, tiene lugar y,
+// en salas privadas, presenta una invitación válida.
+// Si no puede entrar responde con un error y retorna false
+func (h *Hub) joinRoom(client *Client, roomName, inviteCode string) bool {
+	if client.room != nil && client.room.name == roomName {
+		return true
+	}
+
+	room, code, reason := h.admit(client, roomName, inviteCode)
+	if room == nil {
+		h.sendError(client, code, reason)
+		return false
+	}
+
+	h.moveTo(client, room)
+	return true
+}
+
+// admit verifica si el cliente puede entrar a una sala (existe, no está
+// baneado, tiene lugar y, si es privada, la invitación es válida). Si no,
+// retorna el código y el motivo
+func (h *Hub) admit(client *Client, roomName, inviteCode string) (*Room, string, string) {
+	room, ok := h.rooms[roomName]
+	if !ok {
+		return nil, errRoomNotFound, fmt.Sprintf("room #%s does not exist", roomName)
+	}
+	if ban, banned := room.activeBan(client.username); banned {
+		return nil, errBanned, sanctionMessage("you are banned from #"+roomName, ban)
+	}
+	if room.isFull() {
+		return nil, errRoomFull, fmt.Sprintf("room #%s is full (%d/%d users)", roomName, room.size(), room.maxUsers)
+	}
+	if room.private {
+		if inviteCode == "" {
+			return nil, errInviteRequired, fmt.Sprintf("room #%s is private, an invite code is required", roomName)
+		}
+		if !h.invites.redeem(roomName, inviteCode) {
+			return nil, errInvalidInvite, fmt.Sprintf("invite code for #%s is invalid or expired", roomName)
+		}
+	}
+	return room, "", ""
+}
+
+// moveTo saca al cliente de su sala actual y lo agrega a otra
+func (h *Hub) moveTo(client *Client, room *Room) {
+	h.leaveRoom(client)
+	client.room = room
+	h.replayHistory(client, room)
+	room.add(client)
+	h.sendTo(client, h.roomMode(room, client))
+	h.broadcastRoomList()
+}
+
+// handleCreateRoom valida y crea una sala nueva, y une al creador a ella
+func (h *Hub) handleCreateRoom(client *Client, msg WSMessage) {
+	roomName, err := normalizeRoomName(msg.Room)
+	if err != nil {
+		h.sendError(client, errInvalidRoomName, err.Error())
+		return
+	}
+	if _, exists := h.rooms[roomName]; exists {
+		h.sendError(client, errRoomExists, fmt.Sprintf("room #%s already exists", roomName))
+		return
+	}
+
+	maxUsers := msg.MaxUsers
+	if maxUsers == 0 {
+		maxUsers = defaultMaxUsers
+	}
+	if maxUsers < 1 || maxUsers > maxRoomCapacity {
+		h.sendError(client, errInvalidCapacity, fmt.Sprintf("room capacity must be between 1 and %d", maxRoomCapacity))
+		return
+	}
+
+	room := h.createRoom(roomName, maxUsers, msg.Private)
+	h.claimRoom(room, client.username)
+	if err := h.store.SaveRoom(SavedRoom{Name: roomName, MaxUsers: maxUsers, Private: room.private}); err != nil {
+		log.Printf("❌ Error saving room #%s: %v", roomName, err)
+	}
+
+	// Confirmar al creador antes de que lleguen los mensajes de la sala
+	h.sendTo(client, WSMessage{
+		Type:      protocol.TypeRoomCreated,
+		Username:  "System",
+		Content:   fmt.Sprintf("Room #%s created", roomName),
+		Timestamp: time.Now(),
+		Room:      roomName,
+		MaxUsers:  room.maxUsers,
+		Private:   room.private,
+	})
+
+	// El creador entra sin invitación
+	h.moveTo(client, room)
+}
+
+// handleInvite emite un código de invitación para la sala privada del cliente
+func (h *Hub) handleInvite(client *Client, msg WSMessage) {
+	roomName := h.resolveRoom(client, msg.Room)
+	room, ok := h.rooms[roomName]
+	if !ok || !room.isMember(client) {
+		h.sendError(client, errNotInRoom, fmt.Sprintf("you must be in #%s to invite people", roomName))
+		return
+	}
+	if !room.private {
+		h.sendError(client, errRoomNotPrivate, fmt.Sprintf("room #%s is public, no invite needed", roomName))
+		return
+	}
+	if msg.ExpiresIn < 0 || msg.MaxUses < 0 {
+		h.sendError(client, errInvalidInvite, "invite expiry and uses can't be negative")
+		return
+	}
+	// El rango se revisa antes de convertir, como el del slow mode
+	if msg.ExpiresIn > protocol.MaxExpiresIn {
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("invite expiry can be at most %d seconds", protocol.MaxExpiresIn))
+		return
+	}
+
+	ttl := time.Duration(msg.ExpiresIn) * time.Second
+	token, inv, err := h.invites.mint(roomName, client.username, ttl, msg.MaxUses)
+	if err != nil {
+		log.Printf("❌ Error generating invite code: %v", err)
+		h.sendError(client, errInternal, "could not generate invite code")
+		return
+	}
+	h.log("🎟️ Invite for #%s minted by %s", roomName, client.username)
+
+	reply := WSMessage{
+		Type:       protocol.TypeInvite,
+		Username:   "System",
+		Content:    fmt.Sprintf("Invite code for #%s", roomName),
+		Timestamp:  time.Now(),
+		Room:       roomName,
+		InviteCode: token,
+		MaxUses:    msg.MaxUses,
+	}
+	if !inv.expiresAt.IsZero() {
+		reply.ExpiresAt = &inv.expiresAt
+	}
+	h.sendTo(client, reply)
+}
+
+// leaveRoom saca al cliente de su sala actual
+func (h *Hub) leaveRoom(client *Client) {
+	if client.room == nil {
+		return
+	}
+	client.room.leave(client, "")
+	client.room = nil
+	h.broadcastRoomList()
+}
+
+// replayHistory envía al cliente los últimos mensajes de la sala
+func (h *Hub) replayHistory(client *Client, room *Room) {
+	if h.historyReplay <= 0 {
+		return
+	}
+	msgs, err := h.store.Recent(room.name, h.historyReplay)
+	if err != nil {
+		log.Printf("❌ Error loading history of #%s: %v", room.name, err)
+		return
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	h.sendTo(client, WSMessage{
+		Type:      protocol.TypeHistory,
+		Username:  "System",
+		Timestamp: time.Now(),
+		Room:      room.name,
+		Messages:  msgs,
+	})
+}
+
+// handleModify edita o borra un mensaje de la sala actual del cliente.
+// Solo el autor puede editar sus mensajes, y borrarlos también el dueño y
+// los moderadores de la sala; el cambio se guarda en el historial y se
+// envía a la sala para que cada cliente lo aplique
+func (h *Hub) handleModify(client *Client, msg WSMessage) {
+	room := client.room
+	if room == nil || (msg.Room != "" && msg.Room != room.name) {
+		h.sendError(client, errNotInRoom, "you can only modify messages in your current room")
+		return
+	}
+
+	original, ok, err := h.store.Find(room.name, msg.RefID)
+	if err != nil {
+		log.Printf("❌ Error loading message %s: %v", msg.RefID, err)
+		h.sendError(client, errInternal, "could not load the message")
+		return
+	}
+	if !ok || original.Type != protocol.TypeChat || original.Deleted {
+		h.sendError(client, errMessageNotFound, "message not found")
+		return
+	}
+	if original.Username != client.username {
+		if msg.Type == protocol.TypeEdit {
+			h.sendError(client, errForbidden, "you can only edit your own messages")
+			return
+		}
+		// Mismo criterio que la moderación: solo sobre alguien de rol inferior
+		actor := room.role(client.username)
+		if actor == "" {
+			h.sendError(client, errForbidden, fmt.Sprintf("only the owner and moderators of #%s can delete other people's messages", room.name))
+			return
+		}
+		if rank(room.role(original.Username)) >= rank(actor) {
+			h.sendError(client, errForbidden, fmt.Sprintf("you can't delete messages from %s, they are %s of #%s", original.Username, roleTitle(room.role(original.Username)), room.name))
+			return
+		}
+	}
+	if msg.Type == protocol.TypeEdit {
+		if code, reason := h.restrain(client, room, msg.Type); code != "" {
+			h.sendError(client, code, reason)
+			return
 		}
 	}
+
+	if msg.Type == protocol.TypeEdit {
+		if msg.Content == "" {
+			h.sendError(client, errInvalidMessage, "edited message can't be empty, use delete instead")
+			return
+		}
+		original.Content = msg.Content
+		original.Edited = true
+	} else {
+		original.Content = ""
+		original.Deleted = true
+	}
+
+	if err := h.store.Replace(original); err != nil {
+		log.Printf("❌ Error saving %s of message %s: %v", msg.Type, msg.RefID, err)
+		h.sendError(client, errInternal, "could not save the change")
+		return
+	}
+	h.log("✏️ %s %s message %s in #%s", client.username, msg.Type, msg.RefID, room.name)
+
+	room.send(WSMessage{
+		Type:     msg.Type,
+		Username: client.username,
+		Content:  original.Content,
+		Room:     room.name,
+		RefID:    original.ID,
+		Edited:   original.Edited,
+		Deleted:  original.Deleted,
+	})
+}
+
+// handleTyping reenvía el indicador de escritura a la sala del cliente,
+// no se guarda en el historial
+func (h *Hub) handleTyping(client *Client, msg WSMessage) {
+	if client.room == nil {
+		return
+	}
+	// Quien no puede escribir en la sala tampoco avisa que escribe
+	if code, _ := h.restrain(client, client.room, msg.Type); code != "" {
+		return
+	}
+
+	status := protocol.StatusTyping
+	client.status = "typing"
+	if msg.Status != protocol.StatusTyping {
+		status = protocol.StatusStoppedTyping
+		client.status = "online"
+	}
+
+	client.room.send(WSMessage{
+		Type:     protocol.TypeTyping,
+		Username: client.username,
+		Room:     client.room.name,
+		Status:   status,
+	})
+}
+
+// handleDirectMessage entrega un mensaje directo solo a las conexiones del
+// destinatario y del remitente, sin pasar por ninguna sala
+func (h *Hub) handleDirectMessage(client *Client, msg WSMessage) {
+	if msg.Content == "" {
+		h.sendError(client, errInvalidMessage, "direct message can't be empty")
+		return
+	}
+
+	recipients := h.clientsByUsername(msg.To)
+	if msg.To == "" || len(recipients) == 0 {
+		h.sendError(client, errUserOffline, fmt.Sprintf("%s is not online, message not delivered", msg.To))
+		return
+	}
+	recipients = slices.DeleteFunc(recipients, func(c *Client) bool { return !c.can(protocol.CapDM) })
+	if len(recipients) == 0 {
+		h.sendError(client, errNotNegotiated, fmt.Sprintf("%s's client doesn't support direct messages, message not delivered", msg.To))
+		return
+	}
+
+	dm := WSMessage{
+		Type:     protocol.TypeDM,
+		Username: client.username,
+		Content:  msg.Content,
+		To:       msg.To,
+	}
+	h.stamp(&dm)
+	h.log("✉️ DM from %s to %s", client.username, msg.To)
+
+	// El remitente también lo recibe (en todas sus conexiones) para verlo con su ID
+	if msg.To != client.username {
+		recipients = append(recipients, h.clientsByUsername(client.username)...)
+	}
+	for _, c := range recipients {
+		h.sendTo(c, dm)
+	}
+}
+
+// clientsByUsername retorna las conexiones activas de un usuario
+func (h *Hub) clientsByUsername(username string) []*Client {
+	var clients []*Client
+	for client := range h.clients {
+		if username != "" && client.username == username {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// handleHistoryRequest responde con una página del historial de una sala
+func (h *Hub) handleHistoryRequest(client *Client, msg WSMessage) {
+	roomName := h.resolveRoom(client, msg.Room)
+	room, ok := h.rooms[roomName]
+	if !ok {
+		h.sendError(client, errRoomNotFound, fmt.Sprintf("room #%s does not exist", roomName))
+		return
+	}
+	if room.private && !room.isMember(client) {
+		h.sendError(client, errNotInRoom, fmt.Sprintf("you must be in #%s to read its history", roomName))
+		return
+	}
+
+	page, hasMore, err := h.historyPage(roomName, msg.Before, msg.Limit)
+	if err != nil {
+		log.Printf("❌ Error loading history of #%s: %v", roomName, err)
+		h.sendError(client, errInternal, "could not load message history")
+		return
+	}
+	h.sendTo(client, WSMessage{
+		Type:      protocol.TypeHistoryPage,
+		Username:  "System",
+		Timestamp: time.Now(),
+		Room:      roomName,
+		Before:    msg.Before,
+		Messages:  page,
+		HasMore:   hasMore,
+	})
+}
+
+// historyPage busca los mensajes de una sala anteriores a before,
+// ajustando limit a los valores permitidos
+func (h *Hub) historyPage(roomName string, before int64, limit int) ([]WSMessage, bool, error) {
+	if limit <= 0 {
+		limit = defaultHistoryPage
+	}
+	if limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+	page, err := h.store.Before(roomName, before, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	if page == nil {
+		page = []WSMessage{}
+	}
+	hasMore := len(page) == limit && page[0].Seq > 1
+	return page, hasMore, nil
+}
+
+// createRoom registra una sala nueva con los roles y bans que tenga
+// guardados e inicia su loop, las salas viven mientras el servidor esté
+// corriendo
+func (h *Hub) createRoom(roomName string, maxUsers int, private bool) *Room {
+	room := newRoom(h, roomName, maxUsers, private)
+	if mod, err := h.store.LoadModeration(roomName); err != nil {
+		log.Printf("❌ Error loading moderation of #%s: %v", roomName, err)
+	} else {
+		room.restoreModeration(mod)
+	}
+	h.mu.Lock()
+	h.rooms[roomName] = room
+	h.mu.Unlock()
+	go room.run()
+	h.log("🏠 Room created: %s", roomName)
+	return room
+}
+
+// restoreRooms vuelve a crear las salas que crearon los usuarios, con los
+// roles y bans que tenían guardados
+func (h *Hub) restoreRooms() {
+	rooms, err := h.store.LoadRooms()
+	if err != nil {
+		log.Printf("❌ Error loading saved rooms: %v", err)
+		return
+	}
+	for _, saved := range rooms {
+		if _, exists := h.rooms[saved.Name]; !exists {
+			h.createRoom(saved.Name, saved.MaxUsers, saved.Private)
+		}
+	}
+}
+
+// Rooms retorna el directorio de salas ordenado por nombre
+func (h *Hub) Rooms() []RoomInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]RoomInfo, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room.info())
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
+
+// roomListMessage arma el mensaje room_list con el directorio actual
+func (h *Hub) roomListMessage() WSMessage {
+	return WSMessage{
+		Type:      protocol.TypeRoomList,
+		Username:  "System",
+		Timestamp: time.Now(),
+		Rooms:     h.Rooms(),
+	}
+}
+
+// broadcastRoomList envía el directorio a los clientes que están en el lobby
+func (h *Hub) broadcastRoomList() {
+	msg := h.roomListMessage()
+	for client := range h.clients {
+		if client.room == nil && client.username != "" {
+			h.sendTo(client, msg)
+		}
+	}
+}
+
+// stamp asigna al mensaje un ID único y la hora del servidor,
+// lo que haya enviado el cliente en esos campos se descarta
+func (h *Hub) stamp(msg *WSMessage) {
+	now := time.Now()
+	msg.ID = h.ids.next(now)
+	msg.Timestamp = now
+}
+
+// sendTo envía un mensaje solo a un cliente, sin bloquear el hub.
+// Si el mensaje no tiene ID se le asigna uno
+func (h *Hub) sendTo(client *Client, msg WSMessage) {
+	if !client.can(protocol.CapabilityFor(msg.Type)) {
+		return
+	}
+	if msg.ID == "" {
+		h.stamp(&msg)
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if !client.deliver(outbound{data: msgBytes, key: coalesceKey(msg), msgType: typeLabel(msg.Type)}) {
+		client.reportSlow()
+	}
+}
+
+// lookupRoom busca una sala por nombre, se puede usar fuera de Run
+func (h *Hub) lookupRoom(roomName string) (*Room, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	room, ok := h.rooms[roomName]
+	return room, ok
+}
+
+// sendError responde a un cliente con un mensaje de error
+func (h *Hub) sendError(client *Client, code, content string) {
+	h.log("⚠️ Error for %s (%s): %s", client.username, code, content)
+	h.sendTo(client, WSMessage{
+		Type:      protocol.TypeError,
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+		Code:      code,
+	})
+}
+
+// HandleRooms retorna el directorio de salas como JSON
+func (h *Hub) HandleRooms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Rooms()); err != nil {
+		h.log("❌ Error encoding room list: %v", err)
+	}
+}
+
+// HandleRoomMessages retorna una página del historial de una sala como JSON,
+// acepta los parámetros before (Seq) y limit
+func (h *Hub) HandleRoomMessages(w http.ResponseWriter, r *http.Request) {
+	roomName := chi.URLParam(r, "roomName")
+	room, ok := h.lookupRoom(roomName)
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if room.private {
+		http.Error(w, "Private room history is only available to members", http.StatusForbidden)
+		return
+	}
+
+	var (
+		before int64
+		limit  int
+		err    error
+	)
+	if v := r.URL.Query().Get("before"); v != "" {
+		if before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, hasMore, err := h.historyPage(roomName, before, limit)
+	if err != nil {
+		log.Printf("❌ Error loading history of #%s: %v", roomName, err)
+		http.Error(w, "Could not load message history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"room":     roomName,
+		"messages": page,
+		"has_more": hasMore,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log("❌ Error encoding room messages: %v", err)
+	}
 }
 
 // HandleEcho maneja conexiones de echo (para testing)
 func (h *Hub) HandleEcho(w http.ResponseWriter, r *http.Request) {
+	// Igual que el chat, no se aceptan conexiones nuevas al apagar
+	if h.draining.Load() {
+		h.metrics.upgradeFailures.inc("shutting_down")
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade error: %v", err)
@@ -128,24 +1038,72 @@ func (h *Hub) HandleEcho(w http.ResponseWriter, r *http.Request) {
 
 // HandleChat maneja conexiones de chat principal
 func (h *Hub) HandleChat(w http.ResponseWriter, r *http.Request) {
+	h.serveClient(w, r, "")
+}
+
+// serveClient actualiza la conexión y registra un nuevo cliente,
+// roomHint es la sala a usar cuando los mensajes no indican ninguna
+func (h *Hub) serveClient(w http.ResponseWriter, r *http.Request, roomHint string) {
+	// Mientras el servidor se apaga no se aceptan conexiones nuevas
+	if h.draining.Load() {
+		h.metrics.upgradeFailures.inc("shutting_down")
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Autenticar antes de aceptar la conexión
+	var username string
+	if h.users != nil {
+		var err error
+		if username, err = h.users.Authenticate(r); err != nil {
+			h.log("🔒 Rejected handshake from %s: %v", r.RemoteAddr, err)
+			h.metrics.upgradeFailures.inc("unauthorized")
+			w.Header().Set("WWW-Authenticate", `Basic realm="bubblenet"`)
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade error: %v", err)
+		if h.origins.Load().Check(r) {
+			h.metrics.upgradeFailures.inc("upgrade")
+		} else {
+			h.metrics.upgradeFailures.inc("origin")
+		}
 		return
 	}
 
 	// Crear nuevo cliente
 	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:      h,
+		conn:     conn,
+		send:     make(chan outbound, h.sendBuffer),
+		roomHint: roomHint,
+		username: username,
+		limiter:  newLimiter(h.connRate, h.maxMessageSize),
+	}
+	if username != "" {
+		client.status = "online"
+		h.log("🔑 %s authenticated", username)
 	}
 
-	// Registrar cliente
-	client.hub.register <- client
+	// Registrar cliente, salvo que el hub ya se haya detenido
+	select {
+	case client.hub.register <- client:
+	case <-h.done:
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, shutdownReason))
+		conn.Close()
+		return
+	}
 
 	// Iniciar goroutines para leer y escribir
-	go client.writePump()
+	h.pumps.Add(1)
+	go func() {
+		defer h.pumps.Done()
+		client.writePump()
+	}()
 	go client.readPump()
 }
 
@@ -159,40 +1117,29 @@ func (h *Hub) HandleRoom(w http.ResponseWriter, r *http.Request) {
 
 	h.log("🏠 Connection to room: %s", roomName)
 
-	// Por ahora, usar el mismo handler que chat general
-	// Más tarde implementaremos lógica de salas separadas
-	h.HandleChat(w, r)
+	// Los mensajes sin sala de esta conexión van a la sala de la URL
+	h.serveClient(w, r, roomName)
 }
 
 // GetOnlineUsers retorna la lista de usuarios conectados
 func (h *Hub) GetOnlineUsers() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	var users []string
+	seen := make(map[string]bool)
 	for client := range h.clients {
-		if client.username != "" {
+		// Un usuario autenticado puede tener varias conexiones
+		if client.username != "" && !seen[client.username] {
+			seen[client.username] = true
 			users = append(users, client.username)
 		}
 	}
 	return users
 }
 
-// BroadcastUserList envía la lista de usuarios a todos los clientes
-func (h *Hub) BroadcastUserList() {
-	users := h.GetOnlineUsers()
-	userListMsg := map[string]interface{}{
-		"type":      "user_list",
-		"username":  "System",
-		"content":   "",
-		"timestamp": time.Now(),
-		"users":     users,
-	}
-	if msgBytes, err := json.Marshal(userListMsg); err == nil {
-		h.broadcast <- msgBytes
-	}
-}
-
 // log helper para mensajes de debug
 func (h *Hub) log(format string, args ...interface{}) {
-	if h.debug {
+	if h.debug.Load() {
 		log.Printf("[HUB] "+format, args...)
 	}
 }
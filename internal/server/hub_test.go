@@ -0,0 +1,322 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Cuánto espera un test por un mensaje antes de fallar
+	testWait = 2 * time.Second
+
+	// Mensajes del historial que recibe un cliente al entrar a una sala
+	testReplay = 10
+)
+
+// testHub arranca un hub con las mismas rutas que el servidor, y las salas
+// indicadas además de la sala por defecto, y retorna su URL base. Sin Store
+// el historial queda en memoria. Al terminar el test se apaga el hub y se
+// cierra el servidor
+func testHub(t *testing.T, config HubConfig, rooms ...string) (*Hub, string) {
+	t.Helper()
+	if config.Store == nil {
+		config.Store = NewMemoryStore(100)
+	}
+	if config.HistoryReplay == 0 {
+		config.HistoryReplay = testReplay
+	}
+	h := NewHub(config)
+	for _, name := range rooms {
+		h.createRoom(name, defaultMaxUsers, false)
+	}
+	go h.Run()
+
+	r := chi.NewRouter()
+	r.Get("/metrics", h.HandleMetrics)
+	r.Get("/rooms", h.HandleRooms)
+	r.Get("/rooms/{roomName}/messages", h.HandleRoomMessages)
+	r.Get("/ws/chat", h.HandleChat)
+	r.Get("/ws/room/{roomName}", h.HandleRoom)
+	r.Get("/ws/echo", h.HandleEcho)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testWait)
+		defer cancel()
+		h.Shutdown(ctx)
+	})
+	return h, srv.URL
+}
+
+// testConn es una conexión de prueba al hub
+type testConn struct {
+	t       *testing.T
+	conn    *websocket.Conn
+	pending []WSMessage // resto del último frame, el hub junta varios mensajes por frame
+}
+
+// dial abre una conexión al chat sin mandar el hello
+func dial(t *testing.T, base string) *testConn {
+	t.Helper()
+	return dialPath(t, base, "/ws/chat")
+}
+
+// dialPath abre una conexión a otro endpoint WebSocket del servidor
+func dialPath(t *testing.T, base, path string) *testConn {
+	t.Helper()
+	c, resp, err := dialHeader(t, base, path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v (%v)", path, err, resp)
+	}
+	return c
+}
+
+// dialHeader abre una conexión con headers propios, sin fallar si el
+// servidor la rechaza
+func dialHeader(t *testing.T, base, path string, header http.Header) (*testConn, *http.Response, error) {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+path, header)
+	if err != nil {
+		return nil, resp, err
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}, resp, nil
+}
+
+// join se conecta como username, entra a la sala indicada ("" se queda en
+// el lobby) y retorna el welcome
+func join(t *testing.T, base, username, room string) (*testConn, WSMessage) {
+	t.Helper()
+	c := dial(t, base)
+	welcome := c.hello(WSMessage{Username: username, Room: room})
+	if room != "" {
+		c.expectContent(protocol.TypeSystem, username+" joined #"+room)
+	}
+	return c, welcome
+}
+
+// hello manda el hello (con la versión actual y todas las capacidades si
+// no indica otras) y retorna el welcome
+func (c *testConn) hello(msg WSMessage) WSMessage {
+	c.t.Helper()
+	msg.Type = protocol.TypeHello
+	if msg.Protocol == 0 {
+		msg.Protocol = protocol.Version
+	}
+	if msg.Capabilities == nil {
+		msg.Capabilities = protocol.Capabilities()
+	}
+	c.send(msg)
+	return c.expect(protocol.TypeWelcome)
+}
+
+// send manda un mensaje al hub
+func (c *testConn) send(msg WSMessage) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.t.Fatalf("send %s: %v", msg.Type, err)
+	}
+}
+
+// read lee el próximo mensaje, o retorna el error si la conexión se cerró
+// o no llegó nada a tiempo
+func (c *testConn) read() (WSMessage, error) {
+	for len(c.pending) == 0 {
+		c.conn.SetReadDeadline(time.Now().Add(testWait))
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return WSMessage{}, err
+		}
+		for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+			var msg WSMessage
+			if err := json.Unmarshal(line, &msg); err != nil {
+				return WSMessage{}, err
+			}
+			c.pending = append(c.pending, msg)
+		}
+	}
+	msg := c.pending[0]
+	c.pending = c.pending[1:]
+	return msg, nil
+}
+
+// expect lee hasta el próximo mensaje de tipo msgType, saltando los demás
+func (c *testConn) expect(msgType string) WSMessage {
+	c.t.Helper()
+	for {
+		msg, err := c.read()
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// expectError lee hasta el próximo error y revisa su código
+func (c *testConn) expectError(code string) WSMessage {
+	c.t.Helper()
+	msg := c.expect(protocol.TypeError)
+	if msg.Code != code {
+		c.t.Fatalf("error code = %q (%s), want %q", msg.Code, msg.Content, code)
+	}
+	return msg
+}
+
+// expectContent lee hasta el próximo mensaje de tipo msgType que contenga
+// text, saltando los demás
+func (c *testConn) expectContent(msgType, text string) WSMessage {
+	c.t.Helper()
+	for {
+		msg := c.expect(msgType)
+		if strings.Contains(msg.Content, text) {
+			return msg
+		}
+	}
+}
+
+// expectClose lee hasta que el hub cierra la conexión y revisa el código
+func (c *testConn) expectClose(code int) {
+	c.t.Helper()
+	for {
+		_, err := c.read()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			c.t.Fatalf("waiting for close %d: %v", code, err)
+		}
+		if closeErr.Code != code {
+			c.t.Fatalf("close code = %d (%s), want %d", closeErr.Code, closeErr.Text, code)
+		}
+		return
+	}
+}
+
+func TestChatStaysInItsRoom(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den", "lab")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "lab")
+	carol, _ := join(t, url, "carol", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "den only"})
+	if msg := carol.expect(protocol.TypeChat); msg.Content != "den only" || msg.Room != "den" {
+		t.Errorf("carol got %q in %q, want den only in den", msg.Content, msg.Room)
+	}
+
+	// Lo primero que le llega a bob es su propio mensaje, no el de #den
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "lab only"})
+	if msg := bob.expect(protocol.TypeChat); msg.Content != "lab only" {
+		t.Errorf("bob got %q, want his own message", msg.Content)
+	}
+}
+
+func TestChatJoinsItsRoom(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", defaultRoom)
+
+	// Un chat a otra sala mueve al cliente
+	alice.send(WSMessage{Type: protocol.TypeChat, Room: "den", Content: "hi"})
+	alice.expectContent(protocol.TypeSystem, "alice joined #den")
+	if msg := alice.expect(protocol.TypeChat); msg.Room != "den" {
+		t.Errorf("chat went to %q, want den", msg.Room)
+	}
+}
+
+func TestRoomFromURL(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "lab")
+	alice, _ := join(t, url, "alice", "lab")
+
+	// Los mensajes sin sala de esta conexión van a la sala de la URL
+	bob := dialPath(t, url, "/ws/room/lab")
+	if welcome := bob.hello(WSMessage{Username: "bob"}); welcome.Room != "lab" {
+		t.Errorf("welcome puts bob in %q, want lab", welcome.Room)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hola"})
+	if msg := alice.expect(protocol.TypeChat); msg.Username != "bob" || msg.Room != "lab" {
+		t.Errorf("alice got %q from %q in %q, want bob in lab", msg.Content, msg.Username, msg.Room)
+	}
+}
+
+func TestLeaveAnnounced(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den", "lab")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	alice.expectContent(protocol.TypeSystem, "bob joined #den")
+	if list := alice.expect(protocol.TypeUserList); len(list.Users) != 2 {
+		t.Errorf("user_list = %v, want alice and bob", list.Users)
+	}
+
+	bob.send(WSMessage{Type: protocol.TypeLeave})
+	alice.expectContent(protocol.TypeSystem, "bob left #den")
+	if list := alice.expect(protocol.TypeUserList); len(list.Users) != 1 || list.Users[0] != "alice" {
+		t.Errorf("user_list = %v, want only alice", list.Users)
+	}
+}
+
+// getJSON hace un GET a la API REST y decodifica la respuesta en v,
+// retorna el código de estado
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestRoomsEndpoint(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "lab")
+	join(t, url, "alice", "lab")
+	join(t, url, "bob", "lab")
+
+	var rooms []RoomInfo
+	if code := getJSON(t, url+"/rooms", &rooms); code != http.StatusOK {
+		t.Fatalf("GET /rooms = %d", code)
+	}
+	want := []RoomInfo{
+		{Name: defaultRoom, Users: 0, MaxUsers: defaultMaxUsers},
+		{Name: "lab", Users: 2, MaxUsers: defaultMaxUsers},
+	}
+	if !reflect.DeepEqual(rooms, want) {
+		t.Errorf("GET /rooms = %+v, want %+v", rooms, want)
+	}
+}
+
+func TestRoomListPushedToLobby(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "lab")
+
+	// Un hello sin sala deja al cliente en el lobby
+	lobby, _ := join(t, url, "alice", "")
+	lobby.send(WSMessage{Type: protocol.TypeRoomList})
+	if list := lobby.expect(protocol.TypeRoomList); len(list.Rooms) != 2 || list.Rooms[1].Users != 0 {
+		t.Errorf("room_list = %+v, want #%s and an empty #lab", list.Rooms, defaultRoom)
+	}
+
+	// Cuando alguien entra a una sala el lobby recibe el directorio nuevo
+	join(t, url, "bob", "lab")
+	list := lobby.expect(protocol.TypeRoomList)
+	if len(list.Rooms) != 2 || list.Rooms[1].Name != "lab" || list.Rooms[1].Users != 1 {
+		t.Errorf("room_list = %+v, want #lab with bob", list.Rooms)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+// acá se generan los IDs de los mensajes
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// crockford es el alfabeto base32 de Crockford que usan los ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idGenerator genera IDs con formato ULID: 48 bits de milisegundos y
+// 80 bits aleatorios, en 26 caracteres que se ordenan igual que el tiempo.
+// Dentro del mismo milisegundo la parte aleatoria se incrementa, así los
+// IDs de un mismo servidor son estrictamente crecientes
+type idGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// next retorna un nuevo ID mayor a todos los anteriores
+func (g *idGenerator) next(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms <= g.lastMs {
+		// Mismo milisegundo (o el reloj retrocedió): incrementar la entropía
+		ms = g.lastMs
+		g.increment()
+	} else {
+		g.lastMs = ms
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			// Sin entropía seguimos siendo únicos incrementando
+			g.increment()
+		}
+	}
+
+	return encodeULID(ms, g.entropy)
+}
+
+// increment suma uno a la entropía, si desborda pasa al siguiente milisegundo
+func (g *idGenerator) increment() {
+	for i := len(g.entropy) - 1; i >= 0; i-- {
+		g.entropy[i]++
+		if g.entropy[i] != 0 {
+			return
+		}
+	}
+	g.lastMs++
+}
+
+// encodeULID codifica el tiempo y la entropía en 26 caracteres base32
+func encodeULID(ms uint64, entropy [10]byte) string {
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (8 * (5 - i)))
+	}
+	copy(id[6:], entropy[:])
+
+	// 128 bits en grupos de 5, el primer carácter lleva solo 3 bits
+	var out [26]byte
+	var acc uint32
+	bits := 2 // 130 bits - 128: se rellena con dos ceros a la izquierda
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&0x1F]
+			pos++
+		}
+	}
+	return string(out[:])
+}
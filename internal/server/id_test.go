@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeULID(t *testing.T) {
+	var zero, max [10]byte
+	for i := range max {
+		max[i] = 0xFF
+	}
+	tests := []struct {
+		ms      uint64
+		entropy [10]byte
+		want    string
+	}{
+		{0, zero, "00000000000000000000000000"},
+		{1, zero, "00000000010000000000000000"},
+		{1<<48 - 1, max, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+	}
+	for _, tt := range tests {
+		if got := encodeULID(tt.ms, tt.entropy); got != tt.want {
+			t.Errorf("encodeULID(%d, %x) = %s, want %s", tt.ms, tt.entropy, got, tt.want)
+		}
+	}
+}
+
+func TestIDsIncrease(t *testing.T) {
+	var g idGenerator
+	now := time.Now()
+	prev := g.next(now)
+	for i := 0; i < 1000; i++ {
+		// Mismo milisegundo, y a veces el reloj para atrás
+		at := now
+		if i%10 == 0 {
+			at = now.Add(-time.Second)
+		}
+		id := g.next(at)
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("id %q is not a ULID", id)
+		}
+		if id <= prev {
+			t.Fatalf("id %s came after %s", id, prev)
+		}
+		prev = id
+	}
+	if later := g.next(now.Add(time.Millisecond)); later <= prev {
+		t.Errorf("id of a later millisecond %s sorts before %s", later, prev)
+	}
+}
+
+func TestIDEntropyOverflow(t *testing.T) {
+	g := idGenerator{lastMs: 5}
+	for i := range g.entropy {
+		g.entropy[i] = 0xFF
+	}
+	g.increment()
+	if g.lastMs != 6 || g.entropy != [10]byte{} {
+		t.Errorf("after overflowing: ms %d entropy %x, want the next millisecond from zero", g.lastMs, g.entropy)
+	}
+}
+
+func TestServerStampsMessages(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+
+	forged := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Now()
+	alice.send(WSMessage{Type: protocol.TypeChat, ID: "forged", Timestamp: forged, Content: "hi"})
+	msg := alice.expectContent(protocol.TypeChat, "hi")
+	if msg.ID == "forged" || len(msg.ID) != 26 {
+		t.Errorf("chat ID = %q, want one from the server", msg.ID)
+	}
+	if msg.Timestamp.Before(before.Add(-time.Second)) {
+		t.Errorf("chat timestamp = %s, want the server's time", msg.Timestamp)
+	}
+
+	// El historial guarda el mismo ID que recibió la sala
+	alice.send(WSMessage{Type: protocol.TypeHistoryRequest})
+	if page := alice.expect(protocol.TypeHistoryPage); len(page.Messages) != 1 || page.Messages[0].ID != msg.ID {
+		t.Errorf("history = %+v, want the message with ID %s", page.Messages, msg.ID)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+// acá se manejan los códigos de invitación a salas privadas
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+)
+
+// Bytes aleatorios de cada código de invitación
+const inviteTokenBytes = 16
+
+// inviteEncoding codifica los tokens sin padding para que sean fáciles de copiar
+var inviteEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// invite representa un código de invitación emitido por el servidor
+type invite struct {
+	room      string
+	createdBy string
+	expiresAt time.Time // cero si no expira
+	usesLeft  int       // 0 si es de usos ilimitados
+}
+
+// expired indica si la invitación ya no es válida por tiempo
+func (i *invite) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
+
+// inviteStore guarda las invitaciones vigentes (solo lo usa el hub)
+type inviteStore struct {
+	invites map[string]*invite
+}
+
+// newInviteStore crea un almacén de invitaciones vacío
+func newInviteStore() *inviteStore {
+	return &inviteStore{invites: make(map[string]*invite)}
+}
+
+// mint genera un código aleatorio para una sala, ttl y maxUses en cero
+// significan sin vencimiento y usos ilimitados
+func (s *inviteStore) mint(room, createdBy string, ttl time.Duration, maxUses int) (string, *invite, error) {
+	buf := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := strings.ToLower(inviteEncoding.EncodeToString(buf))
+
+	inv := &invite{
+		room:      room,
+		createdBy: createdBy,
+		usesLeft:  maxUses,
+	}
+	if ttl > 0 {
+		inv.expiresAt = time.Now().Add(ttl)
+	}
+
+	s.prune()
+	s.invites[token] = inv
+	return token, inv, nil
+}
+
+// redeem valida un código para una sala y descuenta un uso,
+// retorna false si el código no existe, venció o es de otra sala
+func (s *inviteStore) redeem(room, token string) bool {
+	token = strings.ToLower(strings.TrimSpace(token))
+	inv, ok := s.invites[token]
+	if !ok {
+		return false
+	}
+	if inv.expired(time.Now()) {
+		delete(s.invites, token)
+		return false
+	}
+	if inv.room != room {
+		return false
+	}
+
+	if inv.usesLeft > 0 {
+		inv.usesLeft--
+		if inv.usesLeft == 0 {
+			delete(s.invites, token)
+		}
+	}
+	return true
+}
+
+// prune elimina las invitaciones vencidas
+func (s *inviteStore) prune() {
+	now := time.Now()
+	for token, inv := range s.invites {
+		if inv.expired(now) {
+			delete(s.invites, token)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInviteRedeem(t *testing.T) {
+	s := newInviteStore()
+	once, _, err := s.mint("lab", "alice", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlimited, _, _ := s.mint("lab", "alice", 0, 0)
+
+	if s.redeem("den", once) {
+		t.Error("an invite for #lab let someone into #den")
+	}
+	// Los códigos se pueden pegar con espacios o en mayúsculas
+	if !s.redeem("lab", "  "+strings.ToUpper(once)+" ") {
+		t.Error("a single-use invite was rejected on its first use")
+	}
+	if s.redeem("lab", once) {
+		t.Error("a single-use invite was accepted twice")
+	}
+	for i := 0; i < 3; i++ {
+		if !s.redeem("lab", unlimited) {
+			t.Fatalf("an unlimited invite was rejected on use %d", i+1)
+		}
+	}
+	if s.redeem("lab", "not-a-code") {
+		t.Error("an unknown code was accepted")
+	}
+}
+
+func TestInviteExpiry(t *testing.T) {
+	s := newInviteStore()
+	token, inv, _ := s.mint("lab", "alice", time.Hour, 0)
+	if inv.expiresAt.IsZero() {
+		t.Fatal("an invite with a TTL has no expiry")
+	}
+
+	inv.expiresAt = time.Now().Add(-time.Second)
+	if s.redeem("lab", token) {
+		t.Error("an expired invite was accepted")
+	}
+	if _, ok := s.invites[token]; ok {
+		t.Error("an expired invite was kept after being rejected")
+	}
+}
+
+func TestPrivateRoomInvite(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "lab", Private: true})
+	if created := alice.expect(protocol.TypeRoomCreated); !created.Private {
+		t.Error("room_created doesn't say the room is private")
+	}
+	alice.expectContent(protocol.TypeSystem, "alice joined #lab")
+
+	bob, _ := join(t, url, "bob", defaultRoom)
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "lab"})
+	bob.expectError(errInviteRequired)
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "lab", InviteCode: "guess"})
+	bob.expectError(errInvalidInvite)
+
+	// Solo un miembro pide invitaciones, y solo para salas privadas
+	bob.send(WSMessage{Type: protocol.TypeInvite, Room: "lab"})
+	bob.expectError(errNotInRoom)
+	bob.send(WSMessage{Type: protocol.TypeInvite})
+	bob.expectError(errRoomNotPrivate)
+
+	// Una expiración enorme no puede dar la vuelta al convertirla en duración
+	for _, seconds := range []int{protocol.MaxExpiresIn + 1, math.MaxInt64/int(time.Second) + 1, math.MaxInt} {
+		alice.send(WSMessage{Type: protocol.TypeInvite, ExpiresIn: seconds})
+		alice.expectError(errInvalidMessage)
+	}
+
+	alice.send(WSMessage{Type: protocol.TypeInvite, MaxUses: 1, ExpiresIn: 60})
+	inv := alice.expect(protocol.TypeInvite)
+	if inv.Room != "lab" || inv.InviteCode == "" || inv.ExpiresAt == nil {
+		t.Fatalf("invite = %+v, want a code for #lab that expires", inv)
+	}
+
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "lab", InviteCode: inv.InviteCode})
+	bob.expectContent(protocol.TypeSystem, "bob joined #lab")
+
+	carol, _ := join(t, url, "carol", defaultRoom)
+	carol.send(WSMessage{Type: protocol.TypeJoin, Room: "lab", InviteCode: inv.InviteCode})
+	carol.expectError(errInvalidInvite)
+}
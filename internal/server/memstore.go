@@ -0,0 +1,180 @@
+package server
+
+// historial en memoria con un buffer circular por sala
+
+import (
+	"slices"
+	"sync"
+)
+
+// MemoryStore guarda los últimos mensajes de cada sala en memoria
+type MemoryStore struct {
+	mu         sync.RWMutex
+	size       int
+	rooms      map[string]*ring
+	moderation map[string]RoomModeration
+	saved      []SavedRoom
+}
+
+// NewMemoryStore crea un historial en memoria de size mensajes por sala
+func NewMemoryStore(size int) *MemoryStore {
+	if size < 1 {
+		size = 1
+	}
+	return &MemoryStore{
+		size:       size,
+		rooms:      make(map[string]*ring),
+		moderation: make(map[string]RoomModeration),
+	}
+}
+
+// Append guarda un mensaje, pisando el más viejo si el buffer está lleno
+func (s *MemoryStore) Append(msg WSMessage) (WSMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rooms[msg.Room]
+	if !ok {
+		r = newRing(s.size)
+		s.rooms[msg.Room] = r
+	}
+	r.seq++
+	msg.Seq = r.seq
+	r.push(msg)
+	return msg, nil
+}
+
+// Recent retorna los últimos n mensajes de una sala
+func (s *MemoryStore) Recent(room string, n int) ([]WSMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rooms[room]
+	if !ok {
+		return nil, nil
+	}
+	return r.last(n), nil
+}
+
+// Before retorna hasta limit mensajes de una sala anteriores a before
+func (s *MemoryStore) Before(room string, before int64, limit int) ([]WSMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rooms[room]
+	if !ok {
+		return nil, nil
+	}
+	return pageBefore(r.last(r.count), before, limit), nil
+}
+
+// Find busca un mensaje de una sala por su ID
+func (s *MemoryStore) Find(room, id string) (WSMessage, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rooms[room]
+	if !ok {
+		return WSMessage{}, false, nil
+	}
+	if i := r.index(id); i >= 0 {
+		return r.buf[i], true, nil
+	}
+	return WSMessage{}, false, nil
+}
+
+// Replace reemplaza un mensaje que sigue en el buffer
+func (s *MemoryStore) Replace(msg WSMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rooms[msg.Room]
+	if !ok {
+		return errMessageNotStored
+	}
+	i := r.index(msg.ID)
+	if i < 0 {
+		return errMessageNotStored
+	}
+	r.buf[i] = msg
+	return nil
+}
+
+// LoadModeration retorna una copia de los roles y bans de una sala
+func (s *MemoryStore) LoadModeration(room string) (RoomModeration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.moderation[room].clone(), nil
+}
+
+// SaveModeration guarda una copia de los roles y bans de una sala
+func (s *MemoryStore) SaveModeration(room string, mod RoomModeration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moderation[room] = mod.clone()
+	return nil
+}
+
+// SaveRoom guarda la configuración de una sala creada por un usuario
+func (s *MemoryStore) SaveRoom(room SavedRoom) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(slices.DeleteFunc(s.saved, func(r SavedRoom) bool {
+		return r.Name == room.Name
+	}), room)
+	return nil
+}
+
+// LoadRooms retorna una copia de las salas guardadas
+func (s *MemoryStore) LoadRooms() ([]SavedRoom, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.saved), nil
+}
+
+// Close no hace nada, el historial en memoria se pierde al cerrar
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// ring es un buffer circular de mensajes
+type ring struct {
+	buf   []WSMessage
+	start int // posición del mensaje más viejo
+	count int
+	seq   int64 // último Seq asignado en la sala
+}
+
+// newRing crea un buffer circular con la capacidad indicada
+func newRing(capacity int) *ring {
+	return &ring{buf: make([]WSMessage, capacity)}
+}
+
+// push agrega un mensaje al final del buffer
+func (r *ring) push(msg WSMessage) {
+	if r.count < len(r.buf) {
+		r.buf[(r.start+r.count)%len(r.buf)] = msg
+		r.count++
+		return
+	}
+	r.buf[r.start] = msg
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// last retorna los últimos n mensajes, del más viejo al más nuevo
+func (r *ring) last(n int) []WSMessage {
+	if n > r.count {
+		n = r.count
+	}
+	msgs := make([]WSMessage, 0, n)
+	for i := r.count - n; i < r.count; i++ {
+		msgs = append(msgs, r.buf[(r.start+i)%len(r.buf)])
+	}
+	return msgs
+}
+
+// index retorna la posición en buf del mensaje con ese ID, -1 si no está
+func (r *ring) index(id string) int {
+	for i := 0; i < r.count; i++ {
+		pos := (r.start + i) % len(r.buf)
+		if r.buf[pos].ID == id {
+			return pos
+		}
+	}
+	return -1
+}
@@ -0,0 +1,167 @@
+package server
+
+// acá se exponen las métricas del servidor en el formato de texto de
+// Prometheus, para /metrics
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Límites de los buckets del histograma del reparto de mensajes, en segundos
+var fanOutBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25}
+
+// counterVec es un contador con una etiqueta, se puede usar desde
+// cualquier goroutine
+type counterVec struct {
+	mu     sync.Mutex
+	values map[string]*atomic.Int64
+}
+
+// add suma n al contador de un valor de la etiqueta
+func (c *counterVec) add(label string, n int64) {
+	c.mu.Lock()
+	if c.values == nil {
+		c.values = make(map[string]*atomic.Int64)
+	}
+	v, ok := c.values[label]
+	if !ok {
+		v = new(atomic.Int64)
+		c.values[label] = v
+	}
+	c.mu.Unlock()
+	v.Add(n)
+}
+
+// inc suma uno al contador de un valor de la etiqueta
+func (c *counterVec) inc(label string) {
+	c.add(label, 1)
+}
+
+// snapshot retorna los valores actuales ordenados por etiqueta
+func (c *counterVec) snapshot() ([]string, []int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels := make([]string, 0, len(c.values))
+	for label := range c.values {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	values := make([]int64, len(labels))
+	for i, label := range labels {
+		values[i] = c.values[label].Load()
+	}
+	return labels, values
+}
+
+// histogram cuenta observaciones en buckets acumulativos
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []int64 // uno por bucket, más el de +Inf
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]int64, len(buckets)+1)}
+}
+
+// observe registra una observación
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.counts[i]++
+	h.sum += v
+}
+
+// typeLabel retorna la etiqueta con la que se cuenta un tipo de mensaje. Los
+// tipos del protocolo se cuentan por nombre y el resto (los que inventa un
+// cliente) juntos, para no crear series sin fin
+func typeLabel(msgType string) string {
+	if protocol.Known(msgType) {
+		return msgType
+	}
+	return "unknown"
+}
+
+// metrics agrupa los contadores del servidor, los gauges se calculan al
+// momento de exponerlos
+type metrics struct {
+	received        counterVec // mensajes recibidos por tipo
+	sent            counterVec // mensajes entregados por tipo
+	dropped         counterVec // mensajes descartados por motivo
+	upgradeFailures counterVec // conexiones rechazadas antes del WebSocket, por motivo
+	slowDisconnects atomic.Int64
+	fanOut          *histogram // duración del reparto de cada mensaje de sala
+}
+
+func newMetrics() *metrics {
+	return &metrics{fanOut: newHistogram(fanOutBuckets)}
+}
+
+// observeFanOut registra cuánto tardó el reparto de un mensaje de sala
+func (m *metrics) observeFanOut(start time.Time) {
+	m.fanOut.observe(time.Since(start).Seconds())
+}
+
+// HandleMetrics expone las métricas en el formato de texto de Prometheus
+func (h *Hub) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	clients, rooms := len(h.clients), len(h.rooms)
+	h.mu.RUnlock()
+
+	var b strings.Builder
+	writeGauge(&b, "bubblenet_clients", "Connected WebSocket clients.", clients)
+	writeGauge(&b, "bubblenet_rooms", "Open chat rooms.", rooms)
+	writeCounterVec(&b, "bubblenet_messages_received_total", "Messages received from clients.", "type", &h.metrics.received)
+	writeCounterVec(&b, "bubblenet_messages_sent_total", "Messages queued for delivery to clients.", "type", &h.metrics.sent)
+	writeCounterVec(&b, "bubblenet_messages_dropped_total", "Messages discarded instead of being delivered or processed.", "reason", &h.metrics.dropped)
+	writeCounterVec(&b, "bubblenet_upgrade_failures_total", "Connections rejected before becoming WebSocket clients.", "reason", &h.metrics.upgradeFailures)
+	fmt.Fprintf(&b, "# HELP bubblenet_slow_consumer_disconnects_total Clients disconnected for not keeping up with their messages.\n")
+	fmt.Fprintf(&b, "# TYPE bubblenet_slow_consumer_disconnects_total counter\n")
+	fmt.Fprintf(&b, "bubblenet_slow_consumer_disconnects_total %d\n", h.metrics.slowDisconnects.Load())
+	writeHistogram(&b, "bubblenet_broadcast_fanout_seconds", "Time to fan a room message out to its members.", h.metrics.fanOut)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func writeGauge(b *strings.Builder, name, help string, value int) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+func writeCounterVec(b *strings.Builder, name, help, label string, c *counterVec) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	labels, values := c.snapshot()
+	for i, value := range labels {
+		fmt.Fprintf(b, "%s{%s=%q} %d\n", name, label, value, values[i])
+	}
+}
+
+func writeHistogram(b *strings.Builder, name, help string, h *histogram) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative int64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(h.buckets) {
+			le = formatFloat(h.buckets[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{le=%q} %d\n", name, le, cumulative)
+	}
+	fmt.Fprintf(b, "%s_sum %s\n%s_count %d\n", name, formatFloat(h.sum), name, cumulative)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
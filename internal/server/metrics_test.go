@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// getMetrics retorna las líneas de /metrics
+func getMetrics(t *testing.T, url string) []string {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(string(body), "\n")
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "hola"})
+	alice.expect(protocol.TypeChat)
+	alice.send(WSMessage{Type: "video"})
+	alice.send(WSMessage{Type: protocol.TypeRoomList})
+	alice.expect(protocol.TypeRoomList)
+
+	lines := getMetrics(t, url)
+	for _, want := range []string{
+		"bubblenet_clients 1",
+		"bubblenet_rooms 1",
+		`bubblenet_messages_received_total{type="hello"} 1`,
+		`bubblenet_messages_received_total{type="chat"} 1`,
+		`bubblenet_messages_received_total{type="unknown"} 1`,
+		`bubblenet_messages_sent_total{type="chat"} 1`,
+		"bubblenet_slow_consumer_disconnects_total 0",
+		`bubblenet_broadcast_fanout_seconds_bucket{le="+Inf"} `,
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || strings.HasPrefix(line, want)
+		}
+		if !found {
+			t.Errorf("/metrics has no line %q", want)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.observe(v)
+	}
+	var b strings.Builder
+	writeHistogram(&b, "x", "help", h)
+	want := `x_bucket{le="0.1"} 2
+x_bucket{le="1"} 3
+x_bucket{le="+Inf"} 4
+x_sum 2.65
+x_count 4
+`
+	if got := b.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("histogram =\n%s\nwant it to end with\n%s", got, want)
+	}
+}
+
+func TestTypeLabel(t *testing.T) {
+	for _, msgType := range []string{protocol.TypeChat, protocol.TypeKick, protocol.TypeMute, protocol.TypeRoomMode} {
+		if got := typeLabel(msgType); got != msgType {
+			t.Errorf("typeLabel(%q) = %q, want it counted by name", msgType, got)
+		}
+	}
+	if got := typeLabel("made-up"); got != "unknown" {
+		t.Errorf(`typeLabel("made-up") = %q, want "unknown"`, got)
+	}
+}
@@ -0,0 +1,536 @@
+package server
+
+// acá se manejan los roles de cada sala (dueño, moderadores y miembros) y
+// los pedidos de kick, ban, unban, op, deop, mute y unmute. Los roles, bans
+// y mutes van por username, así que sin autenticación valen lo que vale un
+// nickname
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Códigos de error de moderación
+const (
+	errKicked        = protocol.CodeKicked
+	errBanned        = protocol.CodeBanned
+	errUserNotInRoom = protocol.CodeUserNotInRoom
+	errNotBanned     = protocol.CodeNotBanned
+	errNotMuted      = protocol.CodeNotMuted
+)
+
+// maxReasonLength es el largo máximo del motivo de un kick, un ban o un mute
+const maxReasonLength = 200
+
+// RoomModeration es lo que se guarda de cada sala: su dueño, sus
+// moderadores, los usuarios baneados y silenciados, y sus restricciones
+type RoomModeration struct {
+	Owner      string     `json:"owner,omitempty"`
+	Moderators []string   `json:"moderators,omitempty"`
+	Bans       []Sanction `json:"bans,omitempty"`
+	Mutes      []Sanction `json:"mutes,omitempty"`
+	SlowMode   int        `json:"slow_mode,omitempty"` // segundos entre mensajes de un usuario
+	ReadOnly   bool       `json:"read_only,omitempty"`
+}
+
+// Sanction es un ban (no puede entrar a la sala) o un mute (puede leer pero
+// no escribir) de un usuario
+type Sanction struct {
+	User   string    `json:"user"`
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until,omitzero"` // cero es para siempre
+}
+
+// expired indica si la sanción ya venció
+func (s Sanction) expired(now time.Time) bool {
+	return !s.Until.IsZero() && !now.Before(s.Until)
+}
+
+// clone retorna una copia que no comparte las listas
+func (m RoomModeration) clone() RoomModeration {
+	m.Moderators = slices.Clone(m.Moderators)
+	m.Bans = slices.Clone(m.Bans)
+	m.Mutes = slices.Clone(m.Mutes)
+	return m
+}
+
+// rank ordena los roles: el dueño está por encima de los moderadores y
+// ellos por encima de los miembros comunes
+func rank(role string) int {
+	switch role {
+	case protocol.RoleOwner:
+		return 2
+	case protocol.RoleModerator:
+		return 1
+	}
+	return 0
+}
+
+// roleTitle nombra un rol para los mensajes de error
+func roleTitle(role string) string {
+	if role == protocol.RoleOwner {
+		return "the owner"
+	}
+	return "a moderator"
+}
+
+// restoreModeration carga en la sala lo guardado, sin las sanciones vencidas
+func (r *Room) restoreModeration(mod RoomModeration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owner = mod.Owner
+	r.moderators = make(map[string]string)
+	for _, name := range mod.Moderators {
+		r.moderators[strings.ToLower(name)] = name
+	}
+	r.bans = sanctionsByUser(mod.Bans)
+	r.mutes = sanctionsByUser(mod.Mutes)
+	r.slowMode = time.Duration(mod.SlowMode) * time.Second
+	r.readOnly = mod.ReadOnly
+}
+
+// sanctionsByUser indexa las sanciones vigentes por username en minúsculas
+func sanctionsByUser(list []Sanction) map[string]Sanction {
+	now := time.Now()
+	byUser := make(map[string]Sanction)
+	for _, sanction := range list {
+		if !sanction.expired(now) {
+			byUser[strings.ToLower(sanction.User)] = sanction
+		}
+	}
+	return byUser
+}
+
+// sortedSanctions retorna las sanciones ordenadas por usuario
+func sortedSanctions(byUser map[string]Sanction) []Sanction {
+	var list []Sanction
+	for _, sanction := range byUser {
+		list = append(list, sanction)
+	}
+	slices.SortFunc(list, func(a, b Sanction) int {
+		return strings.Compare(a.User, b.User)
+	})
+	return list
+}
+
+// moderation retorna el estado de moderación de la sala para guardarlo
+func (r *Room) moderation() RoomModeration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mod := RoomModeration{
+		Owner:    r.owner,
+		Bans:     sortedSanctions(r.bans),
+		Mutes:    sortedSanctions(r.mutes),
+		SlowMode: int(r.slowMode / time.Second),
+		ReadOnly: r.readOnly,
+	}
+	for _, name := range r.moderators {
+		mod.Moderators = append(mod.Moderators, name)
+	}
+	slices.Sort(mod.Moderators)
+	return mod
+}
+
+// role retorna el rol de un usuario en la sala, "" si es un miembro común
+func (r *Room) role(username string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roleLocked(username)
+}
+
+// roleLocked es role con mu ya tomado
+func (r *Room) roleLocked(username string) string {
+	switch {
+	case username == "":
+		return ""
+	case strings.EqualFold(username, r.owner):
+		return protocol.RoleOwner
+	case r.moderators[strings.ToLower(username)] != "":
+		return protocol.RoleModerator
+	}
+	return ""
+}
+
+// roles retorna el rol de los miembros que no son miembros comunes, para
+// la lista de usuarios
+func (r *Room) roles() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var roles map[string]string
+	for _, members := range []map[*Client]bool{r.clients, r.away} {
+		for client := range members {
+			if role := r.roleLocked(client.username); role != "" {
+				if roles == nil {
+					roles = make(map[string]string)
+				}
+				roles[client.username] = role
+			}
+		}
+	}
+	return roles
+}
+
+// setOwner deja a username como único dueño de la sala
+func (r *Room) setOwner(username string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owner = username
+	delete(r.moderators, strings.ToLower(username))
+}
+
+// setModerator da o quita el rol de moderador
+func (r *Room) setModerator(username string, moderator bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if moderator {
+		r.moderators[strings.ToLower(username)] = username
+	} else {
+		delete(r.moderators, strings.ToLower(username))
+	}
+}
+
+// addBan banea a un usuario; si era moderador deja de serlo
+func (r *Room) addBan(ban Sanction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(ban.User)
+	r.bans[key] = ban
+	delete(r.moderators, key)
+}
+
+// removeBan levanta el ban de un usuario, retorna false si no estaba baneado
+func (r *Room) removeBan(username string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return lift(r.bans, username)
+}
+
+// activeBan retorna el ban vigente de un usuario
+func (r *Room) activeBan(username string) (Sanction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return active(r.bans, username)
+}
+
+// addMute silencia a un usuario en la sala
+func (r *Room) addMute(mute Sanction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mutes[strings.ToLower(mute.User)] = mute
+}
+
+// removeMute levanta el mute de un usuario, retorna false si no estaba
+// silenciado
+func (r *Room) removeMute(username string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return lift(r.mutes, username)
+}
+
+// activeMute retorna el mute vigente de un usuario
+func (r *Room) activeMute(username string) (Sanction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return active(r.mutes, username)
+}
+
+// active busca la sanción vigente de un usuario; las vencidas se borran al
+// encontrarlas (con mu tomado)
+func active(byUser map[string]Sanction, username string) (Sanction, bool) {
+	key := strings.ToLower(username)
+	sanction, ok := byUser[key]
+	if ok && sanction.expired(time.Now()) {
+		delete(byUser, key)
+		return Sanction{}, false
+	}
+	return sanction, ok
+}
+
+// lift borra la sanción de un usuario y retorna si estaba vigente (con mu
+// tomado)
+func lift(byUser map[string]Sanction, username string) bool {
+	key := strings.ToLower(username)
+	sanction, ok := byUser[key]
+	delete(byUser, key)
+	return ok && !sanction.expired(time.Now())
+}
+
+// carry pasa la sanción vigente de oldName a newName, salvo que newName ya
+// tenga una que dure más (con mu tomado). Retorna si cambió algo
+func carry(byUser map[string]Sanction, oldName, newName string) bool {
+	sanction, ok := active(byUser, oldName)
+	if !ok {
+		return false
+	}
+	delete(byUser, strings.ToLower(oldName))
+	if current, ok := active(byUser, newName); ok &&
+		(current.Until.IsZero() || (!sanction.Until.IsZero() && current.Until.After(sanction.Until))) {
+		return true
+	}
+	sanction.User = newName
+	byUser[strings.ToLower(newName)] = sanction
+	return true
+}
+
+// renameUser pasa el rol, el ban y el mute de oldName a newName después de
+// un /nick, y también su último mensaje para el slow mode (que no se guarda)
+func (r *Room) renameUser(oldName, newName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.lastPost[strings.ToLower(oldName)]; ok {
+		delete(r.lastPost, strings.ToLower(oldName))
+		r.lastPost[strings.ToLower(newName)] = last
+	}
+	renamed := carry(r.bans, oldName, newName)
+	renamed = carry(r.mutes, oldName, newName) || renamed
+	switch {
+	case strings.EqualFold(oldName, r.owner):
+		r.owner = newName
+	case r.moderators[strings.ToLower(oldName)] != "":
+		delete(r.moderators, strings.ToLower(oldName))
+		r.moderators[strings.ToLower(newName)] = newName
+	default:
+		return renamed
+	}
+	return true
+}
+
+// membersNamed retorna las conexiones de un usuario que están en la sala,
+// incluidas las que perdieron la conexión
+func (r *Room) membersNamed(username string) []*Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var members []*Client
+	for _, clients := range []map[*Client]bool{r.clients, r.away} {
+		for client := range clients {
+			if strings.EqualFold(client.username, username) {
+				members = append(members, client)
+			}
+		}
+	}
+	return members
+}
+
+// sanctionMessage describe un ban o un mute para quien lo tiene, como
+// "you are banned from #sala until ...: motivo"
+func sanctionMessage(prefix string, sanction Sanction) string {
+	content := prefix
+	if !sanction.Until.IsZero() {
+		content += " until " + sanction.Until.UTC().Format("2006-01-02 15:04 MST")
+	}
+	if sanction.Reason != "" {
+		content += ": " + sanction.Reason
+	}
+	return content
+}
+
+// formatDuration muestra una duración sin los ceros del final ("1h", no
+// "1h0m0s")
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// withReason agrega el motivo entre paréntesis, si hay uno
+func withReason(content, reason string) string {
+	if reason == "" {
+		return content
+	}
+	return content + " (" + reason + ")"
+}
+
+// claimRoom deja a owner como dueño de una sala recién creada, sin los
+// moderadores, sanciones ni restricciones que pudo haber guardado una sala
+// anterior con el mismo nombre. Las salas de los usuarios se guardan, así
+// que una sala que ya existía se restaura al arrancar y no llega acá
+func (h *Hub) claimRoom(room *Room, owner string) {
+	room.restoreModeration(RoomModeration{Owner: owner})
+	h.saveModeration(room)
+}
+
+// saveModeration guarda los roles y bans de una sala
+func (h *Hub) saveModeration(room *Room) {
+	if err := h.store.SaveModeration(room.name, room.moderation()); err != nil {
+		log.Printf("❌ Error saving moderation of #%s: %v", room.name, err)
+	}
+}
+
+// handleModeration procesa kick, ban, unban, op, deop, mute y unmute sobre
+// un usuario de la sala actual. El dueño puede todo; los moderadores solo
+// echan, banean y silencian a miembros comunes
+func (h *Hub) handleModeration(client *Client, msg WSMessage) {
+	roomName := h.resolveRoom(client, msg.Room)
+	room, ok := h.rooms[roomName]
+	if !ok || !room.isMember(client) {
+		h.sendError(client, errNotInRoom, fmt.Sprintf("you must be in #%s to moderate it", roomName))
+		return
+	}
+
+	target, err := validateNickname(msg.Target)
+	if err != nil {
+		h.sendError(client, errInvalidNickname, err.Error())
+		return
+	}
+	reason := strings.TrimSpace(msg.Content)
+	if len(reason) > maxReasonLength {
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("the reason can be at most %d characters", maxReasonLength))
+		return
+	}
+	if msg.ExpiresIn < 0 {
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("%s duration can't be negative", msg.Type))
+		return
+	}
+	// Se revisa antes de convertir a time.Duration, como el de las invitaciones
+	if msg.ExpiresIn > protocol.MaxExpiresIn {
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("%s duration can be at most %d seconds", msg.Type, protocol.MaxExpiresIn))
+		return
+	}
+	if strings.EqualFold(target, client.username) {
+		h.sendError(client, errForbidden, fmt.Sprintf("you can't %s yourself", msg.Type))
+		return
+	}
+
+	// Permisos: op y deop son del dueño, el resto también de los moderadores,
+	// y nadie puede actuar sobre alguien de su mismo rol o superior
+	actor := room.role(client.username)
+	switch msg.Type {
+	case protocol.TypeOp, protocol.TypeDeop:
+		if actor != protocol.RoleOwner {
+			h.sendError(client, errForbidden, fmt.Sprintf("only the owner of #%s can %s users", roomName, msg.Type))
+			return
+		}
+	default:
+		if actor == "" {
+			h.sendError(client, errForbidden, fmt.Sprintf("only the owner and moderators of #%s can %s users", roomName, msg.Type))
+			return
+		}
+	}
+	if role := room.role(target); role != "" && rank(role) >= rank(actor) {
+		h.sendError(client, errForbidden, fmt.Sprintf("you can't %s %s, they are %s of #%s", msg.Type, target, roleTitle(role), roomName))
+		return
+	}
+
+	switch msg.Type {
+	case protocol.TypeKick:
+		h.kick(client, room, target, reason)
+	case protocol.TypeBan:
+		h.ban(client, room, target, reason, time.Duration(msg.ExpiresIn)*time.Second)
+	case protocol.TypeUnban:
+		h.unban(client, room, target)
+	case protocol.TypeOp, protocol.TypeDeop:
+		h.setModerator(client, room, target, msg.Type == protocol.TypeOp)
+	case protocol.TypeMute:
+		h.mute(client, room, target, reason, time.Duration(msg.ExpiresIn)*time.Second)
+	case protocol.TypeUnmute:
+		h.unmute(client, room, target)
+	}
+}
+
+// kick saca a un usuario de la sala; puede volver a entrar
+func (h *Hub) kick(client *Client, room *Room, target, reason string) {
+	notice := withReason(fmt.Sprintf("you were kicked from #%s by %s", room.name, client.username), reason)
+	announcement := withReason(fmt.Sprintf("%s was kicked from #%s by %s", target, room.name, client.username), reason)
+	if !h.expel(room, target, errKicked, notice, announcement) {
+		h.sendError(client, errUserNotInRoom, fmt.Sprintf("%s is not in #%s", target, room.name))
+		return
+	}
+	h.log("👢 %s kicked %s from #%s", client.username, target, room.name)
+}
+
+// ban saca a un usuario de la sala, si está, y no lo deja volver hasta que
+// venza el ban (duration 0 es para siempre) o alguien lo levante
+func (h *Hub) ban(client *Client, room *Room, target, reason string, duration time.Duration) {
+	ban := Sanction{User: target, By: client.username, Reason: reason, Since: time.Now()}
+	description := "permanently"
+	if duration > 0 {
+		ban.Until = ban.Since.Add(duration)
+		description = "for " + formatDuration(duration)
+	}
+	room.addBan(ban)
+	h.saveModeration(room)
+	h.log("🔨 %s banned %s from #%s %s", client.username, target, room.name, description)
+
+	notice := withReason(fmt.Sprintf("you were banned from #%s by %s %s", room.name, client.username, description), reason)
+	announcement := withReason(fmt.Sprintf("%s was banned from #%s by %s %s", target, room.name, client.username, description), reason)
+	if !h.expel(room, target, errBanned, notice, announcement) {
+		// No estaba en la sala: el aviso es solo para la sala
+		room.announce(announcement)
+	}
+}
+
+// unban levanta el ban de un usuario
+func (h *Hub) unban(client *Client, room *Room, target string) {
+	if !room.removeBan(target) {
+		h.sendError(client, errNotBanned, fmt.Sprintf("%s is not banned from #%s", target, room.name))
+		return
+	}
+	h.saveModeration(room)
+	h.log("🕊️ %s unbanned %s from #%s", client.username, target, room.name)
+	room.announce(fmt.Sprintf("%s was unbanned from #%s by %s", target, room.name, client.username))
+}
+
+// setModerator nombra moderador a un usuario o le quita el rol
+func (h *Hub) setModerator(client *Client, room *Room, target string, moderator bool) {
+	isModerator := room.role(target) == protocol.RoleModerator
+	switch {
+	case moderator && isModerator:
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("%s is already a moderator of #%s", target, room.name))
+		return
+	case !moderator && !isModerator:
+		h.sendError(client, errInvalidMessage, fmt.Sprintf("%s is not a moderator of #%s", target, room.name))
+		return
+	}
+
+	room.setModerator(target, moderator)
+	h.saveModeration(room)
+	if moderator {
+		h.log("🛡️ %s made %s a moderator of #%s", client.username, target, room.name)
+		room.announce(fmt.Sprintf("%s is now a moderator of #%s (by %s)", target, room.name, client.username))
+	} else {
+		h.log("🛡️ %s removed %s as moderator of #%s", client.username, target, room.name)
+		room.announce(fmt.Sprintf("%s is no longer a moderator of #%s (by %s)", target, room.name, client.username))
+	}
+}
+
+// expel saca de la sala todas las conexiones de un usuario, les avisa con
+// un error code y anuncia la salida a la sala. Retorna false si el usuario
+// no estaba en la sala
+func (h *Hub) expel(room *Room, target, code, notice, announcement string) bool {
+	members := room.membersNamed(target)
+	if len(members) == 0 {
+		return false
+	}
+	for _, member := range members {
+		room.remove(member)
+		member.room = nil
+		// Una sesión desconectada se entera al retomarla, vuelve al lobby
+		if !member.detached {
+			h.sendError(member, code, notice)
+		}
+	}
+	room.announce(announcement)
+	h.broadcastRoomList()
+	return true
+}
+
+// renameUser pasa los roles, bans y mutes de un usuario que cambió de
+// nickname en todas las salas, así nadie esquiva una sanción con otro nombre
+func (h *Hub) renameUser(oldName, newName string) {
+	for _, room := range h.rooms {
+		if room.renameUser(oldName, newName) {
+			h.saveModeration(room)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// denConfig es un hub con la sala #den, de alice
+func denConfig(store Store) HubConfig {
+	return HubConfig{Store: store, Rooms: []RoomConfig{{Name: "den", Owner: "alice", MaxUsers: 10}}}
+}
+
+func TestKick(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeKick, Target: "bob", Content: "spam"})
+	if msg := bob.expectError(errKicked); msg.Content != "you were kicked from #den by alice (spam)" {
+		t.Errorf("kick notice = %q", msg.Content)
+	}
+	alice.expectContent(protocol.TypeSystem, "bob was kicked from #den by alice")
+
+	// Un kick no impide volver
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	bob.expectContent(protocol.TypeSystem, "bob joined #den")
+
+	alice.send(WSMessage{Type: protocol.TypeKick, Target: "carol"})
+	alice.expectError(errUserNotInRoom)
+}
+
+func TestBanAndUnban(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeBan, Target: "bob"})
+	bob.expectError(errBanned)
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	bob.expectError(errBanned)
+
+	alice.send(WSMessage{Type: protocol.TypeUnban, Target: "bob"})
+	alice.expectContent(protocol.TypeSystem, "bob was unbanned")
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	bob.expectContent(protocol.TypeSystem, "bob joined #den")
+
+	alice.send(WSMessage{Type: protocol.TypeUnban, Target: "bob"})
+	alice.expectError(errNotBanned)
+}
+
+func TestTemporaryBan(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	// Una duración enorme no puede dar la vuelta al convertirla
+	for _, msgType := range []string{protocol.TypeBan, protocol.TypeMute} {
+		for _, seconds := range []int{protocol.MaxExpiresIn + 1, math.MaxInt} {
+			alice.send(WSMessage{Type: msgType, Target: "bob", ExpiresIn: seconds})
+			alice.expectError(errInvalidMessage)
+		}
+	}
+
+	alice.send(WSMessage{Type: protocol.TypeBan, Target: "bob", ExpiresIn: 1})
+	bob.expectError(errBanned)
+	time.Sleep(1100 * time.Millisecond)
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	bob.expectContent(protocol.TypeSystem, "bob joined #den")
+}
+
+func TestBanFollowsNick(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeBan, Target: "bob"})
+	bob.expectError(errBanned)
+
+	// Cambiar de nombre no levanta el ban, y el nombre anterior queda libre
+	bob.send(WSMessage{Type: protocol.TypeNick, Content: "robert"})
+	bob.expect(protocol.TypeNick)
+	bob.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	bob.expectError(errBanned)
+	carol, _ := join(t, url, "bob", "")
+	carol.send(WSMessage{Type: protocol.TypeJoin, Room: "den"})
+	carol.expectContent(protocol.TypeSystem, "bob joined #den")
+}
+
+func TestCarrySanction(t *testing.T) {
+	now := time.Now()
+	short := Sanction{User: "bob", Until: now.Add(time.Minute)}
+	long := Sanction{User: "robert", Until: now.Add(time.Hour)}
+
+	// Si el nombre nuevo ya tenía una más larga, se queda con esa
+	byUser := map[string]Sanction{"bob": short, "robert": long}
+	if !carry(byUser, "bob", "robert") || byUser["robert"] != long {
+		t.Errorf("after carrying a shorter ban: %v, want robert's own", byUser)
+	}
+	if _, ok := byUser["bob"]; ok {
+		t.Error("the old name kept its ban")
+	}
+
+	byUser = map[string]Sanction{"robert": long}
+	if !carry(byUser, "Robert", "bobby") || byUser["bobby"].User != "bobby" || !byUser["bobby"].Until.Equal(long.Until) {
+		t.Errorf("after carrying: %v, want robert's ban under bobby", byUser)
+	}
+	if carry(byUser, "carol", "caroline") {
+		t.Error("carry moved a ban that didn't exist")
+	}
+}
+
+func TestModerationPermissions(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	join(t, url, "carol", "den")
+
+	// Un miembro no modera
+	bob.send(WSMessage{Type: protocol.TypeKick, Target: "carol"})
+	bob.expectError(errForbidden)
+
+	alice.send(WSMessage{Type: protocol.TypeOp, Target: "bob"})
+	alice.expectContent(protocol.TypeSystem, "bob is now a moderator")
+
+	// Un moderador modera a los miembros, pero no al dueño ni a otros
+	// moderadores, y no nombra moderadores
+	bob.send(WSMessage{Type: protocol.TypeKick, Target: "alice"})
+	bob.expectError(errForbidden)
+	bob.send(WSMessage{Type: protocol.TypeOp, Target: "carol"})
+	bob.expectError(errForbidden)
+	bob.send(WSMessage{Type: protocol.TypeKick, Target: "bob"})
+	bob.expectError(errForbidden)
+	bob.send(WSMessage{Type: protocol.TypeKick, Target: "carol"})
+	bob.expectContent(protocol.TypeSystem, "carol was kicked")
+
+	alice.send(WSMessage{Type: protocol.TypeDeop, Target: "bob"})
+	alice.expectContent(protocol.TypeSystem, "bob is no longer a moderator")
+	bob.send(WSMessage{Type: protocol.TypeBan, Target: "alice"})
+	bob.expectError(errForbidden)
+}
+
+func TestDeleteOthersMessages(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	carol, _ := join(t, url, "carol", "den")
+
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "spam"})
+	spam := carol.expectContent(protocol.TypeChat, "spam")
+
+	// Un miembro no toca los mensajes de otros
+	carol.send(WSMessage{Type: protocol.TypeDelete, RefID: spam.ID})
+	carol.expectError(errForbidden)
+	carol.send(WSMessage{Type: protocol.TypeEdit, RefID: spam.ID, Content: "edited"})
+	carol.expectError(errForbidden)
+
+	// El dueño borra pero no edita
+	alice.send(WSMessage{Type: protocol.TypeEdit, RefID: spam.ID, Content: "edited"})
+	alice.expectError(errForbidden)
+	alice.send(WSMessage{Type: protocol.TypeDelete, RefID: spam.ID})
+	if msg := carol.expect(protocol.TypeDelete); msg.RefID != spam.ID || !msg.Deleted {
+		t.Errorf("delete = %+v, want the tombstone of %s", msg, spam.ID)
+	}
+
+	// Un moderador borra los de los miembros, pero no los del dueño
+	alice.send(WSMessage{Type: protocol.TypeOp, Target: "carol"})
+	carol.expectContent(protocol.TypeSystem, "carol is now a moderator")
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "rules"})
+	rules := carol.expectContent(protocol.TypeChat, "rules")
+	carol.send(WSMessage{Type: protocol.TypeDelete, RefID: rules.ID})
+	if msg := carol.expectError(errForbidden); !strings.Contains(msg.Content, "the owner") {
+		t.Errorf("error = %q, want it to say alice is the owner", msg.Content)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "more spam"})
+	more := carol.expectContent(protocol.TypeChat, "more spam")
+	carol.send(WSMessage{Type: protocol.TypeDelete, RefID: more.ID})
+	if msg := carol.expect(protocol.TypeDelete); msg.RefID != more.ID {
+		t.Errorf("delete = %+v, want the tombstone of %s", msg, more.ID)
+	}
+}
+
+func TestUserRoomSurvivesRestart(t *testing.T) {
+	store := NewMemoryStore(100)
+	_, url := testHub(t, HubConfig{Store: store})
+	alice, _ := join(t, url, "alice", "")
+
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "den", MaxUsers: 3, Private: true})
+	alice.expect(protocol.TypeRoomCreated)
+	alice.send(WSMessage{Type: protocol.TypeBan, Target: "bob"})
+	alice.expectContent(protocol.TypeSystem, "bob was banned")
+
+	// Otro hub con el mismo almacenamiento es el servidor después de reiniciar
+	restarted, _ := testHub(t, HubConfig{Store: store})
+	room, ok := restarted.lookupRoom("den")
+	if !ok {
+		t.Fatal("#den was not recreated")
+	}
+	if info := room.info(); info.MaxUsers != 3 || !info.Private {
+		t.Errorf("#den = %+v, want private with 3 users", info)
+	}
+	if room.role("alice") != protocol.RoleOwner {
+		t.Errorf("alice is %q in #den, want the owner", room.role("alice"))
+	}
+	if _, banned := room.activeBan("bob"); !banned {
+		t.Error("bob is no longer banned from #den")
+	}
+}
+
+func TestFileStoreModeration(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod, err := s.LoadModeration("den"); err != nil || !reflect.DeepEqual(mod, RoomModeration{}) {
+		t.Errorf("LoadModeration of a new room = %+v, %v, want nothing", mod, err)
+	}
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mod := RoomModeration{
+		Owner:      "alice",
+		Moderators: []string{"bob"},
+		Bans:       []Sanction{{User: "mallory", By: "alice", Reason: "spam", Since: since}},
+		Mutes:      []Sanction{{User: "carol", By: "bob", Since: since, Until: since.Add(time.Hour)}},
+		SlowMode:   30,
+		ReadOnly:   true,
+	}
+	if err := s.SaveModeration("den", mod); err != nil {
+		t.Fatalf("SaveModeration: %v", err)
+	}
+	for _, room := range []SavedRoom{{Name: "den", MaxUsers: 5}, {Name: "lab", MaxUsers: 2, Private: true}, {Name: "den", MaxUsers: 8}} {
+		if err := s.SaveRoom(room); err != nil {
+			t.Fatalf("SaveRoom: %v", err)
+		}
+	}
+	s.Close()
+
+	s, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.LoadModeration("den")
+	if err != nil || !reflect.DeepEqual(got, mod) {
+		t.Errorf("LoadModeration = %+v, %v, want %+v", got, err, mod)
+	}
+	rooms, err := s.LoadRooms()
+	want := []SavedRoom{{Name: "lab", MaxUsers: 2, Private: true}, {Name: "den", MaxUsers: 8}}
+	if err != nil || !reflect.DeepEqual(rooms, want) {
+		t.Errorf("LoadRooms = %+v, %v, want %+v", rooms, err, want)
+	}
+}
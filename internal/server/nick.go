@@ -0,0 +1,120 @@
+package server
+
+// acá se manejan los nicknames: validación, unicidad y cambios con /nick
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Códigos de error de nicknames
+const (
+	errInvalidNickname = protocol.CodeInvalidNickname
+	errNicknameTaken   = protocol.CodeNicknameTaken
+)
+
+// nicknamePattern define los nicknames válidos: letras, dígitos, '.', '-'
+// y '_', entre 1 y 24 caracteres
+var nicknamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,24}$`)
+
+// reservedNickname es el nombre con el que el servidor firma sus mensajes
+const reservedNickname = "System"
+
+// validateNickname limpia y valida un nickname
+func validateNickname(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !nicknamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid nickname %q: use 1-24 letters, digits, '.', '-' or '_'", name)
+	}
+	if strings.EqualFold(name, reservedNickname) {
+		return "", fmt.Errorf("nickname %q is reserved", name)
+	}
+	return name, nil
+}
+
+// nicknameTaken indica si otra conexión activa o una sesión que todavía se
+// puede retomar ya usa el nickname, sin distinguir mayúsculas
+func (h *Hub) nicknameTaken(name string, except *Client) bool {
+	for client := range h.clients {
+		if client != except && strings.EqualFold(client.username, name) {
+			return true
+		}
+	}
+	for _, client := range h.sessions {
+		if client != except && client.detached && strings.EqualFold(client.username, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimNickname asigna el username del hello de un cliente sin
+// autenticación, retorna false si no es válido o ya está en uso
+func (h *Hub) claimNickname(client *Client, name string) bool {
+	name, err := validateNickname(name)
+	if err != nil {
+		h.sendError(client, errInvalidNickname, err.Error())
+		return false
+	}
+	if h.nicknameTaken(name, client) {
+		h.log("👥 Rejected duplicate nickname %s", name)
+		h.sendError(client, errNicknameTaken, fmt.Sprintf("nickname %s is already in use", name))
+		return false
+	}
+
+	h.setUsername(client, name)
+	client.status = "online"
+	return true
+}
+
+// handleNick cambia el nickname de un cliente y avisa a su sala
+func (h *Hub) handleNick(client *Client, msg WSMessage) {
+	if h.users != nil {
+		h.sendError(client, errForbidden, "nicknames are tied to accounts when authentication is enabled")
+		return
+	}
+
+	name, err := validateNickname(msg.Content)
+	if err != nil {
+		h.sendError(client, errInvalidNickname, err.Error())
+		return
+	}
+	oldName := client.username
+	if name == oldName {
+		return
+	}
+	if h.nicknameTaken(name, client) {
+		h.sendError(client, errNicknameTaken, fmt.Sprintf("nickname %s is already in use", name))
+		return
+	}
+
+	h.setUsername(client, name)
+	client.status = "online"
+	h.renameUser(oldName, name)
+	h.log("🏷️ %s is now known as %s", oldName, name)
+
+	// Confirmación al cliente con su nuevo nombre
+	h.sendTo(client, WSMessage{
+		Type:     protocol.TypeNick,
+		Username: name,
+		Content:  oldName,
+	})
+
+	if client.room != nil {
+		client.room.announce(oldName + " is now known as " + name)
+	}
+}
+
+// setUsername cambia el nombre del cliente bajo los locks del hub y de su
+// sala, que lo leen desde los handlers HTTP y al armar la lista de usuarios
+func (h *Hub) setUsername(client *Client, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.room != nil {
+		client.room.mu.Lock()
+		defer client.room.mu.Unlock()
+	}
+	client.username = name
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"testing"
+)
+
+func TestValidateNickname(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // "" espera un error
+	}{
+		{"alice", "alice"},
+		{"  bob_2.x-y ", "bob_2.x-y"},
+		{"", ""},
+		{"two words", ""},
+		{"ñandú", ""},
+		{"abcdefghijklmnopqrstuvwxy", ""},
+		{"system", ""},
+	}
+	for _, tt := range tests {
+		got, err := validateNickname(tt.name)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("validateNickname(%q) = %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("validateNickname(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestNicknameTaken(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	join(t, url, "alice", "den")
+
+	// Los nicknames no distinguen mayúsculas
+	c := dial(t, url)
+	c.send(WSMessage{Type: protocol.TypeHello, Protocol: protocol.Version, Username: "ALICE", Room: "den"})
+	c.expectError(errNicknameTaken)
+	c.send(WSMessage{Type: protocol.TypeHello, Protocol: protocol.Version, Username: "not a nickname!", Room: "den"})
+	c.expectError(errInvalidNickname)
+}
+
+func TestNickRename(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	alice.expectContent(protocol.TypeSystem, "bob joined #den")
+
+	bob.send(WSMessage{Type: protocol.TypeNick, Content: "robert"})
+	if msg := bob.expect(protocol.TypeNick); msg.Username != "robert" || msg.Content != "bob" {
+		t.Errorf("nick = %q from %q, want robert from bob", msg.Username, msg.Content)
+	}
+	alice.expectContent(protocol.TypeSystem, "bob is now known as robert")
+
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hi"})
+	if msg := alice.expect(protocol.TypeChat); msg.Username != "robert" {
+		t.Errorf("chat from %q, want robert", msg.Username)
+	}
+
+	// El nombre viejo queda libre
+	join(t, url, "bob", "den")
+}
+
+func TestNickErrors(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeNick, Content: "Bob"})
+	alice.expectError(errNicknameTaken)
+	alice.send(WSMessage{Type: protocol.TypeNick, Content: "two words"})
+	alice.expectError(errInvalidNickname)
+}
@@ -0,0 +1,166 @@
+package server
+
+// acá se decide desde qué orígenes (páginas web) se aceptan conexiones
+// WebSocket y pedidos a la API REST, para que un sitio ajeno no pueda usar
+// la sesión del navegador de un usuario (cross-site WebSocket hijacking)
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginPolicy es la lista de orígenes permitidos. Cada patrón es un origen
+// exacto ("https://chat.example.com"), un comodín para los subdominios
+// ("https://*.example.com") o "*" para cualquiera. Sin patrones solo se
+// acepta el mismo origen que el servidor. Los pedidos sin Origin (clientes
+// que no son navegadores, como la TUI) siempre se aceptan
+type OriginPolicy struct {
+	any      bool
+	patterns []originPattern
+}
+
+// originPattern es un patrón ya parseado
+type originPattern struct {
+	scheme string
+	host   string // sin el "*." de los comodines
+	port   string
+	suffix bool // comodín: cualquier subdominio de host
+}
+
+// NewOriginPolicy valida los patrones de orígenes permitidos
+func NewOriginPolicy(origins []string) (*OriginPolicy, error) {
+	policy := &OriginPolicy{}
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			policy.any = true
+			continue
+		}
+
+		u, err := url.Parse(strings.ToLower(origin))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid origin %q: use scheme://host[:port], e.g. https://chat.example.com or https://*.example.com", origin)
+		}
+		pattern := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+		if rest, ok := strings.CutPrefix(pattern.host, "*."); ok {
+			pattern.host, pattern.suffix = rest, true
+		}
+		if pattern.host == "" || strings.Contains(pattern.host, "*") {
+			return nil, fmt.Errorf("invalid origin %q: a wildcard is only allowed as the first label (*.example.com)", origin)
+		}
+		policy.patterns = append(policy.patterns, pattern)
+	}
+	return policy, nil
+}
+
+// String describe la política para los logs
+func (p *OriginPolicy) String() string {
+	switch {
+	case p == nil || (!p.any && len(p.patterns) == 0):
+		return "same origin only"
+	case p.any:
+		return "any origin"
+	}
+	origins := make([]string, len(p.patterns))
+	for i, pattern := range p.patterns {
+		host := pattern.host
+		if pattern.suffix {
+			host = "*." + host
+		}
+		if pattern.port != "" {
+			host += ":" + pattern.port
+		}
+		origins[i] = pattern.scheme + "://" + host
+	}
+	return strings.Join(origins, ", ")
+}
+
+// Check indica si se acepta el origen de un pedido
+func (p *OriginPolicy) Check(r *http.Request) bool {
+	ok, _ := p.check(r)
+	return ok
+}
+
+// check indica si se acepta el origen de un pedido y si es porque está en
+// la lista (o es el mismo origen) y no solo por el "*"
+func (p *OriginPolicy) check(r *http.Request) (ok, listed bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true, false
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false, false
+	}
+
+	if p == nil || (!p.any && len(p.patterns) == 0) {
+		same := strings.EqualFold(u.Host, r.Host)
+		return same, same
+	}
+	host, port := u.Hostname(), u.Port()
+	for _, pattern := range p.patterns {
+		if pattern.scheme != u.Scheme || pattern.port != port {
+			continue
+		}
+		if pattern.suffix {
+			if strings.HasSuffix(host, "."+pattern.host) {
+				return true, true
+			}
+		} else if host == pattern.host {
+			return true, true
+		}
+	}
+	return p.any, false
+}
+
+// Middleware aplica la política a la API REST: rechaza con 403 los
+// orígenes no permitidos y agrega los headers CORS para los permitidos,
+// respondiendo los preflight. Solo los orígenes de la lista reciben
+// credenciales; los que pasan por el "*" reciben un "*" literal, así un
+// sitio cualquiera no puede leer respuestas con las cookies del usuario
+func (p *OriginPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, listed := p.check(r)
+		if !ok {
+			log.Printf("🚫 Rejected %s %s from origin %s", r.Method, r.URL.Path, origin)
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if listed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS aplica Middleware con la política actual del hub, que puede cambiar
+// con Reload
+func (h *Hub) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.origins.Load().Middleware(next).ServeHTTP(w, r)
+	})
+}
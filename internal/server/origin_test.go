@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// originPolicy crea una política o falla el test
+func originPolicy(t *testing.T, origins ...string) *OriginPolicy {
+	t.Helper()
+	policy, err := NewOriginPolicy(origins)
+	if err != nil {
+		t.Fatalf("NewOriginPolicy(%v): %v", origins, err)
+	}
+	return policy
+}
+
+func TestNewOriginPolicyErrors(t *testing.T) {
+	for _, origin := range []string{
+		"chat.example.com",
+		"ftp://chat.example.com",
+		"https://",
+		"https://chat.example.com/app",
+		"https://chat.example.com/?x=1",
+		"https://chat.*.com",
+		"https://*.",
+	} {
+		if _, err := NewOriginPolicy([]string{origin}); err == nil {
+			t.Errorf("NewOriginPolicy accepted %q", origin)
+		}
+	}
+}
+
+func TestOriginCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *OriginPolicy
+		origin string
+		want   bool
+	}{
+		{"no origin", originPolicy(t, "https://chat.example.com"), "", true},
+		{"same origin by default", originPolicy(t), "http://bubblenet.test", true},
+		{"other origin by default", originPolicy(t), "http://evil.test", false},
+		{"nil policy", nil, "http://evil.test", false},
+		{"exact", originPolicy(t, "https://chat.example.com"), "https://Chat.Example.com", true},
+		{"other scheme", originPolicy(t, "https://chat.example.com"), "http://chat.example.com", false},
+		{"other port", originPolicy(t, "https://chat.example.com"), "https://chat.example.com:8443", false},
+		{"explicit port", originPolicy(t, "https://chat.example.com:8443"), "https://chat.example.com:8443", true},
+		{"subdomain", originPolicy(t, "https://*.example.com"), "https://a.b.example.com", true},
+		{"wildcard parent", originPolicy(t, "https://*.example.com"), "https://example.com", false},
+		{"suffix trick", originPolicy(t, "https://*.example.com"), "https://evilexample.com", false},
+		{"any", originPolicy(t, "*"), "http://evil.test", true},
+		{"garbage", originPolicy(t, "*"), "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://bubblenet.test/ws/chat", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := tt.policy.Check(r); got != tt.want {
+				t.Errorf("Check(%q) = %t, want %t", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	_, url := testHub(t, HubConfig{Origins: originPolicy(t, "https://chat.example.com")})
+
+	_, resp, err := dialHeader(t, url, "/ws/chat", http.Header{"Origin": {"https://evil.example.com"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("dial from another origin = %v, %v, want 403", resp, err)
+	}
+	if _, _, err := dialHeader(t, url, "/ws/chat", http.Header{"Origin": {"https://chat.example.com"}}); err != nil {
+		t.Errorf("dial from an allowed origin: %v", err)
+	}
+	// La TUI no manda Origin
+	if _, _, err := dialHeader(t, url, "/ws/chat", nil); err != nil {
+		t.Errorf("dial without origin: %v", err)
+	}
+}
+
+func TestOriginMiddleware(t *testing.T) {
+	handler := originPolicy(t, "https://chat.example.com").Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/rooms", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", "https://evil.example.com", false); w.Code != http.StatusForbidden {
+		t.Errorf("GET from another origin = %d, want 403", w.Code)
+	}
+	if w := serve("GET", "", false); w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("GET without origin = %d with CORS %q, want 200 without CORS", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	w := serve("GET", "https://chat.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://chat.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("GET from an allowed origin = %d %v", w.Code, w.Header())
+	}
+
+	w = serve("OPTIONS", "https://chat.example.com", true)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" {
+		t.Errorf("preflight = %d %v, want 204 allowing GET", w.Code, w.Header())
+	}
+}
+
+func TestOriginMiddlewareAnyOrigin(t *testing.T) {
+	handler := originPolicy(t, "*", "https://chat.example.com").Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(origin string) http.Header {
+		r := httptest.NewRequest("GET", "/rooms", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET from %s = %d, want 200", origin, w.Code)
+		}
+		return w.Header()
+	}
+
+	// Con "*" cualquier origen lee, pero sin credenciales
+	if h := serve("https://evil.example.com"); h.Get("Access-Control-Allow-Origin") != "*" || h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("GET from an unlisted origin got %v, want a literal * without credentials", h)
+	}
+	// Los de la lista siguen recibiéndolas
+	if h := serve("https://chat.example.com"); h.Get("Access-Control-Allow-Origin") != "https://chat.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("GET from a listed origin got %v, want its origin with credentials", h)
+	}
+}
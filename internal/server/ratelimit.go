@@ -0,0 +1,260 @@
+package server
+
+// acá se limita la velocidad a la que cada conexión y cada usuario pueden
+// mandar mensajes, para que un cliente no sature las salas
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Códigos de error de la protección contra floods
+const (
+	errRateLimited = protocol.CodeRateLimited
+	errMuted       = protocol.CodeMuted
+)
+
+const (
+	// Ráfaga que se tolera por encima del ritmo configurado, en segundos
+	rateBurst = 2 * time.Second
+
+	// Excesos seguidos que se avisan antes de silenciar al cliente
+	rateWarnings = 3
+
+	// Silencios después de los cuales se desconecta al cliente
+	rateMutes = 3
+
+	// Tiempo sin excesos después del cual se olvidan los avisos
+	rateForgive = time.Minute
+)
+
+// RateLimit configura cuántos mensajes y bytes por segundo se aceptan, 0 es
+// sin límite
+type RateLimit struct {
+	Messages float64
+	Bytes    float64
+}
+
+// tokenBucket es un balde de tokens: se llena a rate por segundo hasta burst
+// y cada mensaje consume los suyos
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket crea un balde lleno, nil si rate es 0 (sin límite). La
+// ráfaga alcanza siempre para al menos un mensaje de minSize
+func newTokenBucket(rate, minSize float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	burst := max(rate*rateBurst.Seconds(), minSize)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// refill suma los tokens acumulados desde la última vez
+func (b *tokenBucket) refill(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// has indica si quedan n tokens
+func (b *tokenBucket) has(n float64) bool {
+	return b == nil || b.tokens >= n
+}
+
+// take consume n tokens
+func (b *tokenBucket) take(n float64) {
+	if b != nil {
+		b.tokens -= n
+	}
+}
+
+// limiter agrupa los baldes de mensajes y de bytes de una conexión o de un
+// usuario
+type limiter struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+// newLimiter crea los baldes de un RateLimit, nil si no limita nada. El
+// balde de bytes siempre admite al menos un mensaje del tamaño máximo
+func newLimiter(limit RateLimit, maxMessageSize int64) *limiter {
+	if limit.Messages <= 0 && limit.Bytes <= 0 {
+		return nil
+	}
+	return &limiter{
+		messages: newTokenBucket(limit.Messages, 1),
+		bytes:    newTokenBucket(limit.Bytes, float64(maxMessageSize)),
+	}
+}
+
+// allows indica si el limiter acepta un mensaje de size bytes, sin consumir
+func (l *limiter) allows(now time.Time, size int) bool {
+	if l == nil {
+		return true
+	}
+	l.messages.refill(now)
+	l.bytes.refill(now)
+	return l.messages.has(1) && l.bytes.has(float64(size))
+}
+
+// consume descuenta un mensaje de size bytes
+func (l *limiter) consume(size int) {
+	if l == nil {
+		return
+	}
+	l.messages.take(1)
+	l.bytes.take(float64(size))
+}
+
+// floodState lleva los excesos y silencios de un usuario (solo lo usa el hub)
+type floodState struct {
+	strikes    int
+	mutes      int
+	lastStrike time.Time
+	mutedUntil time.Time
+}
+
+// expired indica si ya no queda nada que recordar: ni un silencio vigente
+// ni excesos recientes
+func (f *floodState) expired(now time.Time) bool {
+	return !now.Before(f.mutedUntil) && now.Sub(f.lastStrike) > rateForgive
+}
+
+// floodOf retorna los excesos del usuario del cliente, que se mantienen
+// aunque se reconecte. Una conexión que todavía no tiene nombre usa los suyos
+func (h *Hub) floodOf(client *Client) *floodState {
+	if client.username == "" {
+		return &client.flood
+	}
+	flood, ok := h.floods[client.username]
+	if !ok {
+		flood = &floodState{}
+		h.floods[client.username] = flood
+	}
+	return flood
+}
+
+// forgetFloods descarta los excesos que ya vencieron
+func (h *Hub) forgetFloods(now time.Time) {
+	for username, flood := range h.floods {
+		if flood.expired(now) {
+			delete(h.floods, username)
+		}
+	}
+}
+
+// userLimiter retorna el limiter compartido por las conexiones de un usuario
+func (h *Hub) userLimiter(username string) *limiter {
+	if username == "" {
+		return nil
+	}
+	l, ok := h.userLimits[username]
+	if !ok {
+		l = newLimiter(h.userRate, h.maxMessageSize)
+		if l == nil {
+			return nil
+		}
+		h.userLimits[username] = l
+	}
+	return l
+}
+
+// forgetUserLimiter descarta el limiter de un usuario sin conexiones
+func (h *Hub) forgetUserLimiter(username string) {
+	for client := range h.clients {
+		if client.username == username {
+			return
+		}
+	}
+	delete(h.userLimits, username)
+	h.forgetFloods(time.Now())
+}
+
+// allow aplica los límites de la conexión y del usuario a un mensaje. Los
+// excesos se avisan, al repetirse silencian al cliente por un rato y si
+// sigue se lo desconecta. Retorna false si el mensaje se descarta
+func (h *Hub) allow(client *Client, msg WSMessage, size int) bool {
+	now := time.Now()
+	user := h.userLimiter(client.username)
+	ok := client.limiter.allows(now, size) && user.allows(now, size)
+	if ok {
+		client.limiter.consume(size)
+		user.consume(size)
+	}
+
+	flood := h.floodOf(client)
+	muted := now.Before(flood.mutedUntil)
+	if ok && (!muted || !speaks(msg.Type)) {
+		return true
+	}
+	h.metrics.dropped.inc("rate_limited")
+	if ok {
+		// Silenciado pero sin exceder el ritmo: se le recuerda por qué se ignora
+		h.rejectFlood(client, msg, errMuted, fmt.Sprintf("you are muted for flooding, wait %s",
+			flood.mutedUntil.Sub(now).Round(time.Second)))
+		return false
+	}
+
+	if now.Sub(flood.lastStrike) > rateForgive {
+		flood.strikes = 0
+	}
+	flood.strikes++
+	flood.lastStrike = now
+
+	if flood.strikes < rateWarnings {
+		if !muted {
+			h.rejectFlood(client, msg, errRateLimited, "you are sending messages too fast, slow down")
+		}
+		return false
+	}
+
+	flood.strikes = 0
+	flood.mutes++
+	if flood.mutes > rateMutes {
+		log.Printf("🚫 Disconnecting %s for flooding", client.username)
+		h.sendError(client, errRateLimited, "disconnected for flooding")
+		h.disconnect(client, protocol.CloseRateLimited, "sending messages too fast")
+		return false
+	}
+
+	flood.mutedUntil = now.Add(h.muteDuration)
+	log.Printf("🔇 Muted %s for %s for flooding", client.username, h.muteDuration)
+	h.rejectFlood(client, msg, errMuted, fmt.Sprintf("you are muted for %s for flooding", h.muteDuration))
+	return false
+}
+
+// speaks indica si un tipo de mensaje puede llegar a otros usuarios, los
+// que no se aceptan mientras el cliente está silenciado. Se listan los
+// pedidos que solo le responden al cliente, así cualquier otro tipo (uno
+// nuevo o uno desconocido) cuenta como que habla
+func speaks(msgType string) bool {
+	switch msgType {
+	case protocol.TypeHello, protocol.TypeJoin, protocol.TypeLeave, protocol.TypeRoomList,
+		protocol.TypeHistoryRequest, protocol.TypeInvite:
+		return false
+	}
+	return true
+}
+
+// rejectFlood avisa al cliente que se descartó su mensaje. Los mensajes de
+// chat llevan su ID para que el cliente los marque como no entregados
+func (h *Hub) rejectFlood(client *Client, msg WSMessage, code, content string) {
+	if msg.Type == protocol.TypeTyping {
+		// Los indicadores se descartan sin avisar, salen solos mientras se escribe
+		return
+	}
+	if msg.Type == protocol.TypeChat && msg.ClientID != "" {
+		h.rejectChat(client, msg.ClientID, code, content)
+		return
+	}
+	h.sendError(client, code, content)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(2, 1)
+	b.last = start
+	if b.burst != 2*rateBurst.Seconds() || !b.has(b.burst) {
+		t.Fatalf("new bucket = %+v, want a full burst of %g", b, 2*rateBurst.Seconds())
+	}
+
+	b.take(b.burst)
+	if b.has(1) {
+		t.Error("an empty bucket has tokens")
+	}
+	b.refill(start.Add(500 * time.Millisecond))
+	if !b.has(1) || b.has(1.1) {
+		t.Errorf("after half a second tokens = %g, want 1", b.tokens)
+	}
+	b.refill(start.Add(time.Hour))
+	if b.tokens != b.burst {
+		t.Errorf("after an hour tokens = %g, want the burst %g", b.tokens, b.burst)
+	}
+
+	// La ráfaga siempre alcanza para un mensaje entero
+	if b := newTokenBucket(10, defaultMaxMessageSize); b.burst != defaultMaxMessageSize {
+		t.Errorf("byte bucket burst = %g, want %d", b.burst, defaultMaxMessageSize)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	if l := newLimiter(RateLimit{}, defaultMaxMessageSize); l != nil || !l.allows(time.Now(), defaultMaxMessageSize) {
+		t.Errorf("newLimiter without limits = %+v, want nil and allowing everything", l)
+	}
+
+	l := newLimiter(RateLimit{Bytes: 100}, defaultMaxMessageSize)
+	now := time.Now()
+	if !l.allows(now, defaultMaxMessageSize) {
+		t.Fatal("a full limiter refused one message")
+	}
+	l.consume(defaultMaxMessageSize)
+	if l.allows(now, 1) {
+		t.Error("the limiter allowed a message past its byte burst")
+	}
+}
+
+func TestSpeaks(t *testing.T) {
+	quiet := []string{protocol.TypeHello, protocol.TypeJoin, protocol.TypeLeave, protocol.TypeRoomList, protocol.TypeHistoryRequest, protocol.TypeInvite}
+	for _, msgType := range quiet {
+		if speaks(msgType) {
+			t.Errorf("speaks(%q) = true, want false", msgType)
+		}
+	}
+	loud := []string{protocol.TypeChat, protocol.TypeDM, protocol.TypeEdit, protocol.TypeDelete, protocol.TypeTyping, protocol.TypeNick, protocol.TypeCreateRoom, "message"}
+	for _, msgType := range loud {
+		if !speaks(msgType) {
+			t.Errorf("speaks(%q) = false, want true", msgType)
+		}
+	}
+}
+
+func TestFloodMute(t *testing.T) {
+	const rate = 5 // mensajes por segundo
+	_, url := testHub(t, HubConfig{RateLimit: RateLimit{Messages: rate}, MuteDuration: time.Minute})
+	join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	// Pasa la ráfaga, los excesos se avisan y al tercero se silencia
+	burst := int(rate * rateBurst.Seconds())
+	for i := range burst + rateWarnings {
+		bob.send(WSMessage{Type: protocol.TypeChat, Content: fmt.Sprint(i)})
+	}
+	for range rateWarnings - 1 {
+		bob.expectError(errRateLimited)
+	}
+	bob.expectError(errMuted)
+
+	// Ya sin exceder el ritmo, nada de lo que llega a otros pasa
+	for _, msg := range []WSMessage{
+		{Type: protocol.TypeChat, Content: "hi"},
+		{Type: protocol.TypeDM, To: "alice", Content: "psst"},
+		{Type: protocol.TypeNick, Content: "robert"},
+	} {
+		time.Sleep(2 * time.Second / rate)
+		bob.send(msg)
+		if got := bob.expectError(errMuted); !strings.Contains(got.Content, "muted for flooding") {
+			t.Errorf("%s: error = %q, want the flood mute", msg.Type, got.Content)
+		}
+	}
+
+	// Los pedidos que solo le responden a él sí
+	time.Sleep(2 * time.Second / rate)
+	bob.send(WSMessage{Type: protocol.TypeRoomList})
+	bob.expect(protocol.TypeRoomList)
+}
+
+func TestFloodMuteSurvivesReconnect(t *testing.T) {
+	const rate = 5
+	_, url := testHub(t, HubConfig{RateLimit: RateLimit{Messages: rate}, MuteDuration: time.Minute})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	burst := int(rate * rateBurst.Seconds())
+	for i := range burst + rateWarnings {
+		bob.send(WSMessage{Type: protocol.TypeChat, Content: fmt.Sprint(i)})
+	}
+	for range rateWarnings - 1 {
+		bob.expectError(errRateLimited)
+	}
+	bob.expectError(errMuted)
+
+	// Reconectarse no levanta el silencio: se lleva por usuario
+	bob.drop()
+	alice.expectContent(protocol.TypeSystem, "bob left #"+defaultRoom)
+	bob, _ = join(t, url, "bob", defaultRoom)
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "back"})
+	if got := bob.expectError(errMuted); !strings.Contains(got.Content, "muted for flooding") {
+		t.Errorf("error after reconnecting = %q, want the flood mute", got.Content)
+	}
+}
+
+func TestForgetFloods(t *testing.T) {
+	now := time.Now()
+	h := &Hub{floods: map[string]*floodState{
+		"muted":    {mutedUntil: now.Add(time.Minute), lastStrike: now.Add(-time.Hour)},
+		"warned":   {strikes: 1, lastStrike: now},
+		"forgiven": {strikes: 2, mutes: 1, lastStrike: now.Add(-rateForgive - time.Second)},
+	}}
+	h.forgetFloods(now)
+	if _, ok := h.floods["forgiven"]; ok {
+		t.Error("an expired flood state was kept")
+	}
+	if len(h.floods) != 2 {
+		t.Errorf("kept %d flood states, want the muted and the warned user", len(h.floods))
+	}
+}
+
+func TestFloodDisconnect(t *testing.T) {
+	const rate = 5
+	_, url := testHub(t, HubConfig{RateLimit: RateLimit{Messages: rate}, MuteDuration: time.Millisecond})
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	// Cada tanda de excesos silencia, pasados rateMutes silencios se corta
+	burst := int(rate * rateBurst.Seconds())
+	// (el hub puede cortar antes de que terminen de salir)
+	for i := range burst + rateWarnings*(rateMutes+1) {
+		bob.conn.WriteJSON(WSMessage{Type: protocol.TypeChat, Content: fmt.Sprint(i)})
+	}
+	bob.expectClose(protocol.CloseRateLimited)
+}
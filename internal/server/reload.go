@@ -0,0 +1,128 @@
+package server
+
+// acá se aplica la configuración que se puede cambiar sin reiniciar el
+// servidor, cuando main recibe SIGHUP
+
+import (
+	"bubblenet/pkg/protocol"
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RoomConfig es una sala que se crea al arrancar el servidor
+type RoomConfig struct {
+	Name     string
+	MaxUsers int    // 0 usa la capacidad por defecto
+	Owner    string // dueño de la sala, "" deja el que tenga guardado
+	SlowMode time.Duration
+	ReadOnly bool
+}
+
+// ValidateRooms revisa los nombres y capacidades de las salas configuradas
+func ValidateRooms(rooms []RoomConfig) error {
+	var errs []error
+	for i, room := range rooms {
+		if _, err := normalizeRoomName(room.Name); err != nil {
+			errs = append(errs, fmt.Errorf("rooms[%d].name: %w", i, err))
+		}
+		if room.MaxUsers < 0 || room.MaxUsers > maxRoomCapacity {
+			errs = append(errs, fmt.Errorf("rooms[%d].max_users: must be between 1 and %d", i, maxRoomCapacity))
+		}
+		if room.SlowMode < 0 || room.SlowMode > maxSlowMode || room.SlowMode%time.Second != 0 {
+			errs = append(errs, fmt.Errorf("rooms[%d].slow_mode: must be whole seconds between 0 and %s", i, formatDuration(maxSlowMode)))
+		}
+		if room.Owner != "" {
+			if _, err := validateNickname(room.Owner); err != nil {
+				errs = append(errs, fmt.Errorf("rooms[%d].owner: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// createConfiguredRooms crea las salas configuradas que todavía no existen
+// y retorna cuántas creó. El dueño, el slow mode y el modo solo lectura
+// configurados se aplican también a las que ya existían, incluida la sala
+// por defecto; los moderadores pueden cambiar los modos hasta la próxima
+// recarga
+func (h *Hub) createConfiguredRooms(rooms []RoomConfig) int {
+	created := 0
+	for _, config := range rooms {
+		name, err := normalizeRoomName(config.Name)
+		if err != nil {
+			log.Printf("⚠️ Skipping configured room: %v", err)
+			continue
+		}
+		room, exists := h.rooms[name]
+		if !exists {
+			room = h.createRoom(name, cmp.Or(config.MaxUsers, defaultMaxUsers), false)
+			created++
+		}
+		changed := false
+		if config.Owner != "" && room.role(config.Owner) != protocol.RoleOwner {
+			room.setOwner(config.Owner)
+			h.log("👑 %s is the owner of #%s", config.Owner, name)
+			changed = true
+		}
+		slowMode, readOnly := room.restrictions()
+		if config.SlowMode > 0 && config.SlowMode != slowMode {
+			room.setSlowMode(config.SlowMode)
+			changed = true
+		}
+		if config.ReadOnly && !readOnly {
+			room.setReadOnly(true)
+			changed = true
+		}
+		if changed {
+			h.saveModeration(room)
+			h.broadcastRoomMode(room)
+		}
+	}
+	return created
+}
+
+// Reload aplica la parte de config que se puede cambiar en caliente: debug,
+// MOTD, historial que se envía al entrar, ventana de reanudación, límites
+// de velocidad, política de clientes lentos, orígenes permitidos y salas
+// nuevas. El resto (timeouts, tamaños, almacenamiento, usuarios) necesita
+// reiniciar el servidor y se ignora
+func (h *Hub) Reload(config HubConfig) {
+	select {
+	case h.reload <- config:
+	case <-h.done:
+	}
+}
+
+// applyReload corre dentro de Run, así no compite con nadie por los campos
+// que solo usa el loop principal
+func (h *Hub) applyReload(config HubConfig) {
+	h.debug.Store(config.Debug)
+	h.slowPolicy.Store(&config.SlowConsumer)
+	h.origins.Store(config.Origins)
+	h.motd = config.MOTD
+	h.historyReplay = config.HistoryReplay
+	h.resumeWindow = config.ResumeWindow
+	h.muteDuration = config.MuteDuration
+
+	// Límites nuevos: baldes nuevos para todos, llenos como al conectarse
+	if config.RateLimit != h.connRate || config.UserRateLimit != h.userRate {
+		h.connRate = config.RateLimit
+		h.userRate = config.UserRateLimit
+		clear(h.userLimits)
+		for client := range h.clients {
+			client.limiter = newLimiter(h.connRate, h.maxMessageSize)
+		}
+		for _, client := range h.sessions {
+			client.limiter = newLimiter(h.connRate, h.maxMessageSize)
+		}
+	}
+
+	if h.createConfiguredRooms(config.Rooms) > 0 {
+		h.broadcastRoomList()
+	}
+
+	log.Printf("🔄 Configuration reloaded")
+}
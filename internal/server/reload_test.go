@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestValidateRooms(t *testing.T) {
+	if err := ValidateRooms([]RoomConfig{{Name: "news"}, {Name: "help", MaxUsers: 10}}); err != nil {
+		t.Errorf("ValidateRooms: %v", err)
+	}
+	if err := ValidateRooms([]RoomConfig{{Name: "not a room!"}, {Name: "big", MaxUsers: maxRoomCapacity + 1}}); err == nil {
+		t.Error("ValidateRooms accepted a bad name and capacity")
+	}
+}
+
+func TestConfiguredRooms(t *testing.T) {
+	h, _ := testHub(t, HubConfig{Rooms: []RoomConfig{{Name: "news", MaxUsers: 5}, {Name: defaultRoom}}})
+
+	rooms := h.Rooms()
+	i := slices.IndexFunc(rooms, func(r protocol.RoomInfo) bool { return r.Name == "news" })
+	if i < 0 || rooms[i].MaxUsers != 5 {
+		t.Errorf("rooms = %+v, want news with 5 users", rooms)
+	}
+	if len(rooms) != 2 {
+		t.Errorf("rooms = %+v, want the default room and news", rooms)
+	}
+}
+
+func TestReload(t *testing.T) {
+	h, base := testHub(t, HubConfig{MOTD: "viejo"})
+	alice, _ := join(t, base, "alice", "")
+
+	h.Reload(HubConfig{
+		MOTD:          "nuevo",
+		HistoryReplay: testReplay,
+		SlowConsumer:  SlowDropOldest,
+		Origins:       originPolicy(t, "https://chat.example.com"),
+		SendBuffer:    1,
+		Rooms:         []RoomConfig{{Name: "news"}},
+	})
+
+	// Las salas nuevas se anuncian en el lobby
+	list := alice.expect(protocol.TypeRoomList)
+	if !slices.ContainsFunc(list.Rooms, func(r protocol.RoomInfo) bool { return r.Name == "news" }) {
+		t.Errorf("room list = %+v, want news", list.Rooms)
+	}
+
+	// El MOTD y los orígenes nuevos valen para las conexiones siguientes
+	if _, welcome := join(t, base, "bob", ""); welcome.MOTD != "nuevo" {
+		t.Errorf("welcome motd = %q, want nuevo", welcome.MOTD)
+	}
+	if _, resp, err := dialHeader(t, base, "/ws/chat", http.Header{"Origin": {"https://evil.example.com"}}); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("dial from another origin = %v, want 403", err)
+	}
+	if policy := *h.slowPolicy.Load(); policy != SlowDropOldest {
+		t.Errorf("slow consumer policy = %q, want %q", policy, SlowDropOldest)
+	}
+
+	// Los tamaños de los buffers solo se leen al arrancar
+	if h.sendBuffer != defaultSendBuffer {
+		t.Errorf("send buffer = %d, want it unchanged at %d", h.sendBuffer, defaultSendBuffer)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+// acá se aplican las restricciones para escribir en una sala: mutes, slow
+// mode y salas de solo lectura. El hub las revisa antes de guardar un
+// mensaje y mandarlo a la sala
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Códigos de error de las restricciones
+const (
+	errSlowMode = protocol.CodeSlowMode
+	errReadOnly = protocol.CodeReadOnly
+)
+
+// maxSlowMode es el mayor intervalo de slow mode que se puede fijar
+const maxSlowMode = time.Hour
+
+// setSlowMode fija el intervalo mínimo entre mensajes de cada usuario, 0
+// lo apaga
+func (r *Room) setSlowMode(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slowMode = interval
+	clear(r.lastPost)
+}
+
+// setReadOnly pone o saca la sala en modo solo lectura
+func (r *Room) setReadOnly(readOnly bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readOnly = readOnly
+}
+
+// restrictions retorna el slow mode y si la sala es de solo lectura
+func (r *Room) restrictions() (time.Duration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.slowMode, r.readOnly
+}
+
+// slowModeWait retorna cuánto le falta a un usuario para poder volver a
+// escribir, 0 si ya puede
+func (r *Room) slowModeWait(username string, now time.Time) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	last, ok := r.lastPost[strings.ToLower(username)]
+	if r.slowMode == 0 || !ok {
+		return 0
+	}
+	return max(last.Add(r.slowMode).Sub(now), 0)
+}
+
+// notePost registra que un usuario escribió, para el slow mode
+func (r *Room) notePost(username string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slowMode > 0 {
+		r.lastPost[strings.ToLower(username)] = now
+	}
+}
+
+// connected retorna los miembros de la sala con la conexión abierta
+func (r *Room) connected() []*Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := make([]*Client, 0, len(r.clients))
+	for client := range r.clients {
+		members = append(members, client)
+	}
+	return members
+}
+
+// restrain revisa si el cliente puede mandar un mensaje de tipo msgType a
+// la sala. Los silenciados no escriben nada; en las salas de solo lectura
+// y con slow mode el dueño y los moderadores no tienen límites. Si no
+// puede, retorna el código y el motivo
+func (h *Hub) restrain(client *Client, room *Room, msgType string) (string, string) {
+	if mute, muted := room.activeMute(client.username); muted {
+		return errMuted, sanctionMessage("you are muted in #"+room.name, mute)
+	}
+	if room.role(client.username) != "" {
+		return "", ""
+	}
+
+	_, readOnly := room.restrictions()
+	if readOnly {
+		return errReadOnly, fmt.Sprintf("#%s is read-only, only its owner and moderators can post", room.name)
+	}
+	if msgType == protocol.TypeChat {
+		if wait := room.slowModeWait(client.username, time.Now()); wait > 0 {
+			return errSlowMode, fmt.Sprintf("slow mode is on in #%s, wait %s", room.name, wait.Round(time.Second))
+		}
+	}
+	return "", ""
+}
+
+// roomMode arma el room_mode de la sala para un miembro: el slow mode, si
+// es de solo lectura y si ese miembro está silenciado y hasta cuándo
+func (h *Hub) roomMode(room *Room, client *Client) WSMessage {
+	slowMode, readOnly := room.restrictions()
+	msg := WSMessage{
+		Type:      protocol.TypeRoomMode,
+		Username:  "System",
+		Timestamp: time.Now(),
+		Room:      room.name,
+		SlowMode:  int(slowMode / time.Second),
+		ReadOnly:  readOnly,
+	}
+	if mute, muted := room.activeMute(client.username); muted {
+		msg.Muted = true
+		msg.Content = mute.Reason
+		if !mute.Until.IsZero() {
+			msg.ExpiresAt = &mute.Until
+		}
+	}
+	return msg
+}
+
+// broadcastRoomMode manda a cada miembro conectado su room_mode
+func (h *Hub) broadcastRoomMode(room *Room) {
+	for _, member := range room.connected() {
+		h.sendTo(member, h.roomMode(room, member))
+	}
+}
+
+// mute deja a un usuario sin poder escribir en la sala hasta que venza el
+// mute (duration 0 es hasta que alguien lo levante); puede seguir leyendo
+func (h *Hub) mute(client *Client, room *Room, target, reason string, duration time.Duration) {
+	mute := Sanction{User: target, By: client.username, Reason: reason, Since: time.Now()}
+	description := "until unmuted"
+	if duration > 0 {
+		mute.Until = mute.Since.Add(duration)
+		description = "for " + formatDuration(duration)
+	}
+	room.addMute(mute)
+	h.saveModeration(room)
+	h.log("🔇 %s muted %s in #%s %s", client.username, target, room.name, description)
+
+	room.announce(withReason(fmt.Sprintf("%s was muted in #%s by %s %s", target, room.name, client.username, description), reason))
+	h.broadcastRoomMode(room)
+}
+
+// unmute levanta el mute de un usuario
+func (h *Hub) unmute(client *Client, room *Room, target string) {
+	if !room.removeMute(target) {
+		h.sendError(client, errNotMuted, fmt.Sprintf("%s is not muted in #%s", target, room.name))
+		return
+	}
+	h.saveModeration(room)
+	h.log("🔊 %s unmuted %s in #%s", client.username, target, room.name)
+	room.announce(fmt.Sprintf("%s was unmuted in #%s by %s", target, room.name, client.username))
+	h.broadcastRoomMode(room)
+}
+
+// handleRoomRestriction cambia el slow mode o el modo solo lectura de la
+// sala actual, lo pueden hacer el dueño y los moderadores
+func (h *Hub) handleRoomRestriction(client *Client, msg WSMessage) {
+	roomName := h.resolveRoom(client, msg.Room)
+	room, ok := h.rooms[roomName]
+	if !ok || !room.isMember(client) {
+		h.sendError(client, errNotInRoom, fmt.Sprintf("you must be in #%s to moderate it", roomName))
+		return
+	}
+	if room.role(client.username) == "" {
+		h.sendError(client, errForbidden, fmt.Sprintf("only the owner and moderators of #%s can change %s", roomName, msg.Type))
+		return
+	}
+
+	var announcement string
+	if msg.Type == protocol.TypeSlowMode {
+		// El rango se revisa antes de convertir, un número enorme daría la
+		// vuelta al pasarlo a time.Duration
+		if limit := int(maxSlowMode / time.Second); msg.SlowMode < 0 || msg.SlowMode > limit {
+			h.sendError(client, errInvalidMessage, fmt.Sprintf("slow mode must be between 0 (off) and %d seconds", limit))
+			return
+		}
+		interval := time.Duration(msg.SlowMode) * time.Second
+		room.setSlowMode(interval)
+		announcement = fmt.Sprintf("%s turned off slow mode in #%s", client.username, roomName)
+		if interval > 0 {
+			announcement = fmt.Sprintf("%s turned on slow mode in #%s: one message every %s", client.username, roomName, formatDuration(interval))
+		}
+		h.log("🐢 %s set slow mode in #%s to %s", client.username, roomName, interval)
+	} else {
+		room.setReadOnly(msg.ReadOnly)
+		announcement = fmt.Sprintf("%s opened #%s to everyone", client.username, roomName)
+		if msg.ReadOnly {
+			announcement = fmt.Sprintf("%s made #%s read-only, only its owner and moderators can post", client.username, roomName)
+		}
+		h.log("📢 %s set #%s read-only: %t", client.username, roomName, msg.ReadOnly)
+	}
+
+	h.saveModeration(room)
+	room.announce(announcement)
+	h.broadcastRoomMode(room)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMute(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeMute, Target: "bob", Content: "calm down", ExpiresIn: 60})
+	if mode := bob.expect(protocol.TypeRoomMode); !mode.Muted || mode.ExpiresAt == nil || mode.Content != "calm down" {
+		t.Errorf("room_mode = %+v, want bob muted for a minute", mode)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hi"})
+	bob.expectError(errMuted)
+
+	// El mute pasa al nombre nuevo
+	bob.send(WSMessage{Type: protocol.TypeNick, Content: "robert"})
+	bob.expect(protocol.TypeNick)
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hi again"})
+	bob.expectError(errMuted)
+
+	// Silenciado sigue en la sala y leyendo
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "still reading?"})
+	bob.expectContent(protocol.TypeChat, "still reading?")
+
+	alice.send(WSMessage{Type: protocol.TypeUnmute, Target: "bob"})
+	alice.expectError(errNotMuted)
+	alice.send(WSMessage{Type: protocol.TypeUnmute, Target: "robert"})
+	if mode := bob.expect(protocol.TypeRoomMode); mode.Muted {
+		t.Errorf("room_mode = %+v, want robert unmuted", mode)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "thanks"})
+	alice.expectContent(protocol.TypeChat, "thanks")
+}
+
+func TestSlowMode(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeSlowMode, SlowMode: 60})
+	if mode := bob.expect(protocol.TypeRoomMode); mode.SlowMode != 60 {
+		t.Errorf("room_mode slow mode = %d, want 60", mode.SlowMode)
+	}
+
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "first"})
+	bob.expectContent(protocol.TypeChat, "first")
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "second"})
+	bob.expectError(errSlowMode)
+	bob.send(WSMessage{Type: protocol.TypeNick, Content: "robert"})
+	bob.expect(protocol.TypeNick)
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "second"})
+	bob.expectError(errSlowMode)
+
+	// El dueño no tiene slow mode
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "one"})
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "two"})
+	alice.expectContent(protocol.TypeChat, "two")
+
+	alice.send(WSMessage{Type: protocol.TypeSlowMode, SlowMode: 0})
+	bob.expect(protocol.TypeRoomMode)
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "third"})
+	bob.expectContent(protocol.TypeChat, "third")
+}
+
+func TestSlowModeRange(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	bob.send(WSMessage{Type: protocol.TypeSlowMode, SlowMode: 10})
+	bob.expectError(errForbidden)
+
+	// Un número enorme no puede dar la vuelta al convertirlo en duración
+	for _, seconds := range []int{-1, 3601, math.MaxInt64/int(time.Second) + 1, math.MaxInt} {
+		alice.send(WSMessage{Type: protocol.TypeSlowMode, SlowMode: seconds})
+		alice.expectError(errInvalidMessage)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	_, url := testHub(t, denConfig(nil))
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+	carol, _ := join(t, url, "carol", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeReadOnly, ReadOnly: true})
+	if mode := bob.expect(protocol.TypeRoomMode); !mode.ReadOnly {
+		t.Errorf("room_mode = %+v, want read-only", mode)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hi"})
+	bob.expectError(errReadOnly)
+
+	// Los moderadores sí escriben
+	alice.send(WSMessage{Type: protocol.TypeOp, Target: "carol"})
+	carol.expectContent(protocol.TypeSystem, "carol is now a moderator")
+	carol.send(WSMessage{Type: protocol.TypeChat, Content: "announcement"})
+	bob.expectContent(protocol.TypeChat, "announcement")
+}
+
+func TestConfiguredRestrictions(t *testing.T) {
+	_, url := testHub(t, HubConfig{Rooms: []RoomConfig{{Name: "news", Owner: "alice", SlowMode: 30 * time.Second, ReadOnly: true}}})
+	bob := dial(t, url)
+	bob.hello(WSMessage{Username: "bob", Room: "news"})
+
+	// Al entrar cada miembro recibe los modos de la sala
+	if mode := bob.expect(protocol.TypeRoomMode); mode.SlowMode != 30 || !mode.ReadOnly {
+		t.Errorf("room_mode = %+v, want slow mode 30 and read-only", mode)
+	}
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hi"})
+	bob.expectError(errReadOnly)
+
+	if err := ValidateRooms([]RoomConfig{{Name: "news", SlowMode: 1500 * time.Millisecond}}); err == nil {
+		t.Error("ValidateRooms accepted a slow mode that is not whole seconds")
+	}
+}
@@ -1,3 +1,253 @@
 package server
 
 // acá se manejara la logica de las salas
+
+import (
+	"bubblenet/pkg/protocol"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Sala a la que entran los clientes que no indican ninguna
+	defaultRoom = "general"
+
+	// Capacidad por defecto de una sala
+	defaultMaxUsers = 5
+
+	// Capacidad máxima que se puede pedir al crear una sala
+	maxRoomCapacity = 50
+)
+
+// roomNamePattern define los nombres de sala válidos: minúsculas, dígitos,
+// guiones y guiones bajos, entre 2 y 32 caracteres
+var roomNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+// normalizeRoomName limpia y valida el nombre de una sala
+func normalizeRoomName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if !roomNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid room name %q: use 2-32 lowercase letters, digits, '-' or '_'", name)
+	}
+	return name, nil
+}
+
+// RoomInfo describe una sala en el directorio de salas
+type RoomInfo = protocol.RoomInfo
+
+// Room representa una sala de chat con sus propios miembros
+type Room struct {
+	name     string
+	hub      *Hub
+	maxUsers int
+	private  bool
+
+	// Miembros de la sala: conectados y los que perdieron la conexión pero
+	// todavía pueden retomar su sesión (siguen contando como miembros)
+	mu      sync.RWMutex
+	clients map[*Client]bool
+	away    map[*Client]bool
+
+	// Dueño, moderadores, sanciones y restricciones (ver moderation.go y
+	// restrict.go), también bajo mu. Las claves de los mapas son el username
+	// en minúsculas
+	owner      string
+	moderators map[string]string
+	bans       map[string]Sanction
+	mutes      map[string]Sanction
+	slowMode   time.Duration
+	readOnly   bool
+	lastPost   map[string]time.Time // último mensaje de cada usuario, para el slow mode
+
+	// Mensajes a repartir entre los miembros
+	broadcast chan outbound
+}
+
+// outbound es un mensaje ya serializado para repartir en la sala, junto
+// con la capacidad que tiene que haber acordado cada miembro para recibirlo
+type outbound struct {
+	data       []byte
+	capability string
+	key        string // ver coalesceKey
+	msgType    string // para las métricas
+}
+
+// newRoom crea una nueva sala (hay que llamar a run para iniciarla)
+func newRoom(hub *Hub, name string, maxUsers int, private bool) *Room {
+	return &Room{
+		name:       name,
+		hub:        hub,
+		maxUsers:   maxUsers,
+		private:    private,
+		clients:    make(map[*Client]bool),
+		away:       make(map[*Client]bool),
+		moderators: make(map[string]string),
+		bans:       make(map[string]Sanction),
+		mutes:      make(map[string]Sanction),
+		lastPost:   make(map[string]time.Time),
+		broadcast:  make(chan outbound, hub.roomBuffer),
+	}
+}
+
+// run ejecuta el loop de broadcast de la sala
+func (r *Room) run() {
+	for message := range r.broadcast {
+		r.hub.log("📢 Broadcasting message to %d clients in #%s", r.size(), r.name)
+		r.fanOut(message)
+	}
+}
+
+// fanOut envía un mensaje a todos los miembros de la sala que lo soportan
+func (r *Room) fanOut(message outbound) {
+	defer r.hub.metrics.observeFanOut(time.Now())
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for client := range r.clients {
+		if !client.can(message.capability) {
+			continue
+		}
+		if !client.deliver(message) {
+			// Los clientes lentos se desconectan desde el hub, con aviso a la sala
+			client.reportSlow()
+		}
+	}
+}
+
+// add agrega un cliente a la sala y avisa al resto de los miembros
+func (r *Room) add(client *Client) {
+	r.mu.Lock()
+	r.clients[client] = true
+	r.mu.Unlock()
+	r.hub.log("🏠 %s joined #%s. Members: %d", client.username, r.name, r.size())
+
+	if client.username != "" {
+		r.announce(client.username + " joined #" + r.name)
+	}
+}
+
+// leave saca un cliente de la sala y avisa al resto de los miembros, con
+// el motivo si no se fue por su cuenta
+func (r *Room) leave(client *Client, reason string) {
+	if !r.remove(client) {
+		return
+	}
+	r.hub.log("🚪 %s left #%s. Members: %d", client.username, r.name, r.size())
+
+	if client.username != "" {
+		content := client.username + " left #" + r.name
+		if reason != "" {
+			content += " (" + reason + ")"
+		}
+		r.announce(content)
+	}
+}
+
+// remove saca un cliente de la sala, retorna false si no era miembro
+func (r *Room) remove(client *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.clients[client] && !r.away[client] {
+		return false
+	}
+	delete(r.clients, client)
+	delete(r.away, client)
+	return true
+}
+
+// detach deja de enviarle mensajes a un cliente que perdió la conexión,
+// sin avisar a la sala: sigue siendo miembro mientras pueda retomar la sesión
+func (r *Room) detach(client *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, client)
+	r.away[client] = true
+}
+
+// reattach reemplaza al cliente desconectado por la conexión que retomó
+// su sesión, sin avisar a la sala
+func (r *Room) reattach(old, client *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.away, old)
+	delete(r.clients, old)
+	r.clients[client] = true
+}
+
+// announce envía un mensaje de sistema y la lista de usuarios actualizada
+func (r *Room) announce(content string) {
+	r.send(WSMessage{
+		Type:      protocol.TypeSystem,
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+		Room:      r.name,
+	})
+	r.send(WSMessage{
+		Type:      protocol.TypeUserList,
+		Username:  "System",
+		Timestamp: time.Now(),
+		Room:      r.name,
+		Users:     r.usernames(),
+		Roles:     r.roles(),
+	})
+}
+
+// send encola un mensaje para todos los miembros de la sala
+func (r *Room) send(msg WSMessage) {
+	if msg.ID == "" {
+		r.hub.stamp(&msg)
+	}
+	if msgBytes, err := json.Marshal(msg); err == nil {
+		r.broadcast <- outbound{data: msgBytes, capability: protocol.CapabilityFor(msg.Type), key: coalesceKey(msg), msgType: typeLabel(msg.Type)}
+	}
+}
+
+// info retorna la descripción de la sala para el directorio
+func (r *Room) info() RoomInfo {
+	return RoomInfo{
+		Name:     r.name,
+		Users:    r.size(),
+		MaxUsers: r.maxUsers,
+		Private:  r.private,
+	}
+}
+
+// isFull indica si la sala alcanzó su capacidad
+func (r *Room) isFull() bool {
+	return r.size() >= r.maxUsers
+}
+
+// isMember indica si el cliente está en la sala
+func (r *Room) isMember(client *Client) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clients[client]
+}
+
+// size retorna la cantidad de miembros de la sala
+func (r *Room) size() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.clients) + len(r.away)
+}
+
+// usernames retorna los nombres de los miembros de la sala
+func (r *Room) usernames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := []string{}
+	seen := make(map[string]bool)
+	for _, members := range []map[*Client]bool{r.clients, r.away} {
+		for client := range members {
+			if client.username != "" && !seen[client.username] {
+				seen[client.username] = true
+				users = append(users, client.username)
+			}
+		}
+	}
+	return users
+}
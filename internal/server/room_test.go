@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"testing"
+)
+
+func TestNormalizeRoomName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"lab", "lab", true},
+		{" #Lab-2 ", "lab-2", true},
+		{"dev_ops", "dev_ops", true},
+		{"x", "", false},
+		{"-lab", "", false},
+		{"two words", "", false},
+		{"a23456789012345678901234567890123", "", false},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRoomName(tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("normalizeRoomName(%q) = %q, %v, want %q (ok %t)", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "#Lab", MaxUsers: 3})
+	if created := alice.expect(protocol.TypeRoomCreated); created.Room != "lab" || created.MaxUsers != 3 {
+		t.Errorf("room_created = %q for %d users, want lab for 3", created.Room, created.MaxUsers)
+	}
+	// El creador entra a la sala nueva
+	alice.expectContent(protocol.TypeSystem, "alice joined #lab")
+}
+
+func TestCreateRoomErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		room     string
+		maxUsers int
+		code     string
+	}{
+		{"invalid name", "no spaces", 0, errInvalidRoomName},
+		{"existing room", defaultRoom, 0, errRoomExists},
+		{"existing room, other case", "GENERAL", 0, errRoomExists},
+		{"too many users", "lab", maxRoomCapacity + 1, errInvalidCapacity},
+		{"negative capacity", "lab", -1, errInvalidCapacity},
+	}
+
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	for _, tt := range tests {
+		alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: tt.room, MaxUsers: tt.maxUsers})
+		if msg := alice.expect(protocol.TypeError); msg.Code != tt.code {
+			t.Errorf("%s: error %q (%s), want %q", tt.name, msg.Code, msg.Content, tt.code)
+		}
+	}
+}
+
+func TestRoomCapacity(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeCreateRoom, Room: "lab", MaxUsers: 2})
+	alice.expectContent(protocol.TypeSystem, "alice joined #lab")
+	join(t, url, "bob", "lab")
+
+	carol, _ := join(t, url, "carol", defaultRoom)
+	carol.send(WSMessage{Type: protocol.TypeJoin, Room: "lab"})
+	carol.expectError(errRoomFull)
+
+	// Un chat a una sala llena tampoco entra
+	carol.send(WSMessage{Type: protocol.TypeChat, Room: "lab", Content: "let me in"})
+	carol.expectError(errRoomFull)
+
+	carol.send(WSMessage{Type: protocol.TypeJoin, Room: "nowhere"})
+	carol.expectError(errRoomNotFound)
+}
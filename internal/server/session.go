@@ -0,0 +1,230 @@
+package server
+
+// acá se maneja el handshake: el cliente se presenta con un hello y el
+// servidor le responde con un welcome
+
+import (
+	"bubblenet/pkg/protocol"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version es la versión del servidor que se informa en el welcome
+const Version = "0.2.0"
+
+// Código de error para los mensajes que llegan antes del hello
+const errHelloRequired = protocol.CodeHelloRequired
+
+// Bytes aleatorios de cada ID de sesión
+const sessionIDBytes = 16
+
+// Máximo de mensajes perdidos que se reenvían al retomar una sesión; si se
+// perdió más, se avisa cuántos quedaron sin reenviar. Tiene que entrar en
+// el buffer de envío del cliente
+const maxResumeReplay = 200
+
+// Mensajes que se piden al historial por vez al buscar los perdidos
+const resumePageSize = 50
+
+// newSessionID genera un ID de sesión aleatorio
+func newSessionID() string {
+	b := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return strings.ToLower(inviteEncoding.EncodeToString(b))
+}
+
+// handleHello completa el handshake: fija la identidad del cliente, le
+// asigna una sesión, responde con el welcome y lo une a la sala pedida
+func (h *Hub) handleHello(client *Client, msg WSMessage) {
+	if client.sessionID != "" {
+		h.sendError(client, errInvalidMessage, "handshake already completed")
+		return
+	}
+
+	// Un hello sin versión es de un cliente anterior al versionado (la 1)
+	clientProtocol := msg.Protocol
+	if clientProtocol == 0 {
+		clientProtocol = 1
+	}
+	version, ok := protocol.NegotiateVersion(clientProtocol)
+	if !ok {
+		h.disconnect(client, protocol.CloseUnsupportedProtocol, fmt.Sprintf(
+			"unsupported protocol version %d, this server requires %d to %d: please upgrade your client",
+			clientProtocol, protocol.MinVersion, protocol.Version))
+		return
+	}
+
+	// Un hello con el ID de una sesión vigente la retoma
+	if old, ok := h.sessions[msg.SessionID]; ok && msg.SessionID != "" {
+		if h.users == nil || old.username == client.username {
+			h.resume(client, old, version, msg)
+			return
+		}
+	}
+
+	// Con autenticación la identidad ya viene del handshake HTTP
+	if client.username == "" {
+		if !h.claimNickname(client, msg.Username) {
+			return
+		}
+	}
+
+	client.sessionID = newSessionID()
+	client.version = msg.Version
+	client.protocol = version
+	client.capabilities = protocol.NegotiateFor(version, msg.Capabilities)
+	h.sessions[client.sessionID] = client
+	h.log("👋 %s said hello (client %s, protocol %d, capabilities %v, session %s)",
+		client.username, client.version, client.protocol, client.capabilities, client.sessionID)
+
+	// Sala pedida en el hello o en la URL, sin ninguna queda en el lobby
+	roomName := msg.Room
+	if roomName == "" {
+		roomName = client.roomHint
+	}
+	var room *Room
+	var code, reason string
+	if roomName != "" {
+		if name, err := normalizeRoomName(roomName); err != nil {
+			code, reason = errInvalidRoomName, err.Error()
+		} else {
+			room, code, reason = h.admit(client, name, msg.InviteCode)
+		}
+	}
+
+	h.sendTo(client, h.welcome(client, room))
+
+	// El anuncio de entrada y la lista de usuarios salen del handshake
+	if room != nil {
+		h.moveTo(client, room)
+	} else if code != "" {
+		h.sendError(client, code, reason)
+	}
+}
+
+// resume pasa una sesión a la nueva conexión del cliente: mismo nombre y
+// misma sala, sin anuncios, y le reenvía los mensajes que se perdió
+func (h *Hub) resume(client, old *Client, version int, msg WSMessage) {
+	if old.expireTimer != nil {
+		old.expireTimer.Stop()
+	}
+	if !old.detached {
+		// La conexión vieja sigue abierta (el servidor todavía no notó el corte)
+		old.closeCode = protocol.CloseSessionResumed
+		old.closeReason = "session resumed on another connection"
+		h.detach(old)
+	}
+
+	h.setUsername(client, old.username)
+	client.status = "online"
+	client.sessionID = old.sessionID
+	client.version = msg.Version
+	client.protocol = version
+	client.capabilities = protocol.NegotiateFor(version, msg.Capabilities)
+	client.acks = old.acks
+	client.limiter = old.limiter
+	client.room = old.room
+	old.room = nil
+	h.sessions[client.sessionID] = client
+	if client.room != nil {
+		client.room.reattach(old, client)
+	}
+	h.log("🔁 %s resumed session %s", client.username, client.sessionID)
+
+	welcome := h.welcome(client, client.room)
+	welcome.Resumed = true
+	h.sendTo(client, welcome)
+
+	if client.room != nil {
+		h.replayMissed(client, msg.LastID)
+	}
+}
+
+// welcome arma la respuesta al hello con la sesión, lo acordado y el estado
+// de la sala en la que queda el cliente
+func (h *Hub) welcome(client *Client, room *Room) WSMessage {
+	welcome := WSMessage{
+		Type:         protocol.TypeWelcome,
+		Username:     client.username,
+		SessionID:    client.sessionID,
+		Protocol:     client.protocol,
+		Capabilities: client.capabilities,
+		Version:      Version,
+		MOTD:         h.motd,
+		Rooms:        h.Rooms(),
+	}
+	if room != nil {
+		welcome.Room = room.name
+		welcome.Users = room.usernames()
+		welcome.Roles = room.roles()
+		if !slices.Contains(welcome.Users, client.username) {
+			welcome.Users = append(welcome.Users, client.username)
+		}
+	}
+	return welcome
+}
+
+// replayMissed reenvía los mensajes de la sala posteriores a lastID, el
+// último que recibió el cliente antes de perder la conexión
+func (h *Hub) replayMissed(client *Client, lastID string) {
+	room := client.room
+	var after int64
+	if lastID != "" {
+		last, ok, err := h.store.Find(room.name, lastID)
+		if err != nil {
+			log.Printf("❌ Error loading message %s: %v", lastID, err)
+			return
+		}
+		if !ok {
+			// Ya no está en el historial, mandar el historial reciente
+			h.replayHistory(client, room)
+			return
+		}
+		after = last.Seq
+	}
+
+	// Se pagina hacia atrás hasta llegar al último que recibió
+	var missed []WSMessage
+	var before int64
+	for len(missed) < maxResumeReplay {
+		page, err := h.store.Before(room.name, before, resumePageSize)
+		if err != nil {
+			log.Printf("❌ Error loading history for #%s: %v", room.name, err)
+			return
+		}
+		start := slices.IndexFunc(page, func(msg WSMessage) bool { return msg.Seq > after })
+		if start < 0 {
+			break
+		}
+		missed = slices.Concat(page[start:], missed)
+		if start > 0 || len(page) < resumePageSize || page[0].Seq == after+1 {
+			break
+		}
+		before = page[0].Seq
+	}
+	if len(missed) > maxResumeReplay {
+		missed = missed[len(missed)-maxResumeReplay:]
+	}
+
+	if lastID != "" && len(missed) > 0 {
+		if skipped := missed[0].Seq - after - 1; skipped > 0 {
+			h.sendTo(client, WSMessage{
+				Type:      protocol.TypeSystem,
+				Username:  "System",
+				Content:   fmt.Sprintf("%d older messages were missed while you were away", skipped),
+				Timestamp: time.Now(),
+				Room:      room.name,
+			})
+		}
+	}
+	for _, msg := range missed {
+		h.sendTo(client, msg)
+	}
+	h.log("📜 Replayed %d missed messages to %s in #%s", len(missed), client.username, room.name)
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHelloWelcome(t *testing.T) {
+	_, url := testHub(t, HubConfig{MOTD: "hola"})
+	_, welcome := join(t, url, "alice", defaultRoom)
+
+	if welcome.SessionID == "" {
+		t.Error("welcome without a session ID")
+	}
+	if welcome.Username != "alice" || welcome.Room != defaultRoom {
+		t.Errorf("welcome for %q in %q, want alice in %q", welcome.Username, welcome.Room, defaultRoom)
+	}
+	if !slices.Contains(welcome.Users, "alice") {
+		t.Errorf("welcome users = %v, want alice among them", welcome.Users)
+	}
+	if welcome.Version != Version || welcome.MOTD != "hola" {
+		t.Errorf("welcome version %q motd %q, want %q and hola", welcome.Version, welcome.MOTD, Version)
+	}
+}
+
+func TestHelloLobby(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	_, welcome := join(t, url, "alice", "")
+
+	if welcome.Room != "" || len(welcome.Users) != 0 {
+		t.Errorf("welcome puts alice in %q with %v, want the lobby", welcome.Room, welcome.Users)
+	}
+	if len(welcome.Rooms) == 0 {
+		t.Error("welcome without the room directory")
+	}
+}
+
+func TestHelloRoomErrors(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+
+	// El welcome llega igual y el cliente queda en el lobby con el motivo
+	c := dial(t, url)
+	if welcome := c.hello(WSMessage{Username: "alice", Room: "nowhere"}); welcome.Room != "" {
+		t.Errorf("welcome puts alice in %q, want the lobby", welcome.Room)
+	}
+	c.expectError(errRoomNotFound)
+}
+
+func TestHelloRequired(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	c := dial(t, url)
+
+	c.send(WSMessage{Type: protocol.TypeChat, Username: "alice", Content: "hi"})
+	c.expectError(errHelloRequired)
+
+	// Después del error el hello sigue valiendo
+	if welcome := c.hello(WSMessage{Username: "alice"}); welcome.Username != "alice" {
+		t.Errorf("welcome for %q, want alice", welcome.Username)
+	}
+}
+
+func TestHelloTwice(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	c, _ := join(t, url, "alice", "")
+
+	c.send(WSMessage{Type: protocol.TypeHello, Username: "mallory"})
+	c.expectError(errInvalidMessage)
+}
+
+func TestHelloJoinsAnnounced(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	_, welcome := join(t, url, "bob", defaultRoom)
+
+	alice.expectContent(protocol.TypeSystem, "bob joined")
+	if !slices.Contains(welcome.Users, "alice") || !slices.Contains(welcome.Users, "bob") {
+		t.Errorf("welcome users = %v, want alice and bob", welcome.Users)
+	}
+}
+
+func TestHelloCapabilities(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	c := dial(t, url)
+	welcome := c.hello(WSMessage{Username: "alice", Room: defaultRoom, Capabilities: []string{protocol.CapTyping, "video"}})
+
+	if welcome.Protocol != protocol.Version {
+		t.Errorf("welcome protocol = %d, want %d", welcome.Protocol, protocol.Version)
+	}
+	if !slices.Equal(welcome.Capabilities, []string{protocol.CapTyping}) {
+		t.Errorf("welcome capabilities = %v, want [typing]", welcome.Capabilities)
+	}
+
+	// Lo que no se acordó se rechaza
+	c.send(WSMessage{Type: protocol.TypeNick, Content: "alicia"})
+	c.expectError(errNotNegotiated)
+}
+
+func TestHelloProtocol1(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	c := dial(t, url)
+	welcome := c.hello(WSMessage{Username: "alice", Room: defaultRoom, Protocol: 1, Capabilities: []string{protocol.CapTyping}})
+
+	if welcome.Protocol != 1 || len(welcome.Capabilities) != 0 {
+		t.Errorf("welcome protocol %d with %v, want protocol 1 without capabilities", welcome.Protocol, welcome.Capabilities)
+	}
+
+	// El protocolo base sigue andando
+	c.send(WSMessage{Type: protocol.TypeChat, Content: "hola"})
+	if msg := c.expect(protocol.TypeChat); msg.Content != "hola" || msg.Username != "alice" {
+		t.Errorf("chat = %q from %q, want hola from alice", msg.Content, msg.Username)
+	}
+}
+
+func TestHelloUnsupportedProtocol(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	c := dial(t, url)
+
+	// Sin versión es la 1, así que hace falta una imposible
+	c.send(WSMessage{Type: protocol.TypeHello, Username: "alice", Protocol: -1})
+	c.expectClose(protocol.CloseUnsupportedProtocol)
+}
+
+func TestServerOnlyTypesRejected(t *testing.T) {
+	_, url := testHub(t, HubConfig{})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+	alice.expectContent(protocol.TypeSystem, "bob joined")
+
+	for _, msgType := range []string{protocol.TypeSystem, protocol.TypeUserList, protocol.TypeWelcome, "message"} {
+		bob.send(WSMessage{Type: msgType, Content: "fake"})
+		bob.expectError(errInvalidMessage)
+	}
+
+	// A alice le llega el siguiente mensaje de verdad, no los falsos
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "real"})
+	for {
+		msg := alice.expect(protocol.TypeChat)
+		if msg.Content == "fake" {
+			t.Fatalf("alice received a fake message: %+v", msg)
+		}
+		if msg.Content == "real" {
+			break
+		}
+	}
+}
+
+// drop corta la conexión sin close frame, como una red que se cae
+func (c *testConn) drop() {
+	c.conn.NetConn().Close()
+}
+
+// expectNoLeave lee los mensajes de c hasta el chat marker y falla si
+// alguno anuncia que username salió de la sala
+func expectNoLeave(t *testing.T, c *testConn, username, marker string) {
+	t.Helper()
+	for {
+		msg, err := c.read()
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", marker, err)
+		}
+		if msg.Type == protocol.TypeSystem && strings.HasPrefix(msg.Content, username+" left") {
+			t.Fatalf("the room saw %q", msg.Content)
+		}
+		if msg.Type == protocol.TypeChat && msg.Content == marker {
+			return
+		}
+	}
+}
+
+func TestResumeAfterDrop(t *testing.T) {
+	_, url := testHub(t, HubConfig{ResumeWindow: time.Minute})
+	alice, welcome := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "before"})
+	last := alice.expectContent(protocol.TypeChat, "before")
+	alice.drop()
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "missed"})
+
+	again := dial(t, url)
+	resumed := again.hello(WSMessage{Username: "alice", SessionID: welcome.SessionID, LastID: last.ID})
+	if !resumed.Resumed || resumed.SessionID != welcome.SessionID || resumed.Room != defaultRoom {
+		t.Errorf("welcome = resumed %t session %q room %q, want the same session in %q",
+			resumed.Resumed, resumed.SessionID, resumed.Room, defaultRoom)
+	}
+
+	// Le llega lo que se perdió, y no lo que ya tenía
+	if msg := again.expect(protocol.TypeChat); msg.Content != "missed" {
+		t.Errorf("first replayed message = %q, want missed", msg.Content)
+	}
+
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "after"})
+	expectNoLeave(t, bob, "alice", "after")
+	again.expectContent(protocol.TypeChat, "after")
+}
+
+func TestResumeLongGap(t *testing.T) {
+	h, url := testHub(t, HubConfig{ResumeWindow: time.Minute, Store: NewMemoryStore(1000)})
+	alice, welcome := join(t, url, "alice", defaultRoom)
+	alice.send(WSMessage{Type: protocol.TypeChat, Content: "before"})
+	last := alice.expectContent(protocol.TypeChat, "before")
+
+	// Más de una página del historial llega completa, y el aviso aparece
+	// solo si se perdió más de lo que se reenvía
+	for _, tc := range []struct {
+		missed  int
+		skipped int
+	}{
+		{resumePageSize + 50, 0},
+		{maxResumeReplay + 20, 20},
+	} {
+		alice.drop()
+		for i := 0; i < tc.missed; i++ {
+			if _, err := h.store.Append(WSMessage{Type: protocol.TypeChat, Room: defaultRoom, ID: newSessionID(), Content: strconv.Itoa(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		alice = dial(t, url)
+		alice.hello(WSMessage{Username: "alice", SessionID: welcome.SessionID, LastID: last.ID})
+
+		first, _ := alice.read()
+		if tc.skipped > 0 {
+			if want := fmt.Sprintf("%d older messages were missed", tc.skipped); first.Type != protocol.TypeSystem || !strings.HasPrefix(first.Content, want) {
+				t.Fatalf("missed %d: first message = %s %q, want a notice that %d were skipped", tc.missed, first.Type, first.Content, tc.skipped)
+			}
+			first, _ = alice.read()
+		}
+		replayed := 1
+		last = first
+		for replayed < min(tc.missed, maxResumeReplay) {
+			msg, err := alice.read()
+			if err != nil {
+				t.Fatalf("missed %d: after %d replayed messages: %v", tc.missed, replayed, err)
+			}
+			if msg.Type == protocol.TypeChat {
+				replayed++
+				last = msg
+			}
+		}
+		if first.Content != strconv.Itoa(tc.skipped) || last.Content != strconv.Itoa(tc.missed-1) {
+			t.Errorf("missed %d: replay went from %q to %q, want %d to %d", tc.missed, first.Content, last.Content, tc.skipped, tc.missed-1)
+		}
+	}
+}
+
+func TestResumeReplacesOpenConnection(t *testing.T) {
+	_, url := testHub(t, HubConfig{ResumeWindow: time.Minute})
+	old, welcome := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	again := dial(t, url)
+	if resumed := again.hello(WSMessage{Username: "alice", SessionID: welcome.SessionID}); !resumed.Resumed {
+		t.Fatal("the session was not resumed")
+	}
+	old.expectClose(protocol.CloseSessionResumed)
+
+	// Los mensajes de la sala van solo a la conexión nueva
+	bob.send(WSMessage{Type: protocol.TypeChat, Content: "hola"})
+	expectNoLeave(t, bob, "alice", "hola")
+	again.expectContent(protocol.TypeChat, "hola")
+}
+
+func TestResumeExpired(t *testing.T) {
+	_, url := testHub(t, HubConfig{ResumeWindow: 50 * time.Millisecond})
+	alice, welcome := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+
+	alice.drop()
+	bob.expectContent(protocol.TypeSystem, "alice left")
+
+	again := dial(t, url)
+	fresh := again.hello(WSMessage{Username: "alice", SessionID: welcome.SessionID})
+	if fresh.Resumed || fresh.SessionID == welcome.SessionID {
+		t.Errorf("welcome = resumed %t session %q, want a new session", fresh.Resumed, fresh.SessionID)
+	}
+	if fresh.Username != "alice" {
+		t.Errorf("welcome for %q, want alice", fresh.Username)
+	}
+}
+
+func TestResumeOtherUsersSession(t *testing.T) {
+	_, url := testHub(t, HubConfig{ResumeWindow: time.Minute})
+	_, welcome := join(t, url, "alice", defaultRoom)
+
+	// Con autenticación desactivada el ID de sesión es la credencial
+	mallory := dial(t, url)
+	if resumed := mallory.hello(WSMessage{Username: "mallory", SessionID: "not-" + welcome.SessionID}); resumed.Resumed {
+		t.Error("an unknown session ID resumed a session")
+	}
+}
+
+func TestDetachLeavesRoomBeforeClosing(t *testing.T) {
+	h := NewHub(HubConfig{Store: NewMemoryStore(10), ResumeWindow: time.Minute})
+	room := h.rooms[defaultRoom]
+	client := &Client{hub: h, send: make(chan outbound, 1), username: "alice", sessionID: "s", room: room}
+	room.mu.Lock()
+	room.clients[client] = true
+	room.mu.Unlock()
+
+	// Mientras la sala reparte un mensaje (con mu tomado para lectura) el
+	// canal de envío tiene que seguir abierto, si no la sala escribiría en
+	// un canal cerrado
+	room.mu.RLock()
+	detached := make(chan struct{})
+	go func() {
+		h.detach(client)
+		close(detached)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if !client.deliver(outbound{data: []byte("{}")}) {
+		t.Error("the send channel stopped accepting messages while the room was delivering")
+	}
+	room.mu.RUnlock()
+	<-detached
+
+	if room.isMember(client) || !client.detached {
+		t.Errorf("after detach: member %t, detached %t, want out of the room and detached", room.isMember(client), client.detached)
+	}
+}
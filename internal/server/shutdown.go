@@ -0,0 +1,93 @@
+package server
+
+// acá se maneja el apagado ordenado del servidor: no se aceptan más
+// conexiones, se avisa a los clientes y se cierran sus conexiones
+
+import (
+	"bubblenet/pkg/protocol"
+	"context"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Motivo del close frame al apagar el servidor
+const shutdownReason = "server restarting"
+
+// Shutdown deja de aceptar conexiones, avisa a todos los clientes que el
+// servidor se reinicia, les cierra la conexión con "going away" y espera a
+// que salgan los close frames o a que venza ctx. Cuando retorna, Run ya
+// terminó y no se guardan más mensajes
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.draining.Store(true)
+
+	select {
+	case h.quit <- struct{}{}:
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// Esperar a que cada writePump mande lo que tenía y su close frame
+	flushed := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeAll avisa a los clientes que el servidor se reinicia, cierra sus
+// conexiones sin anunciar salidas en las salas y detiene las salas (solo la
+// llama Run)
+func (h *Hub) closeAll() {
+	log.Printf("🛑 Closing %d client connections", len(h.clients))
+	for _, client := range h.sessions {
+		if client.expireTimer != nil {
+			client.expireTimer.Stop()
+		}
+	}
+
+	notice := WSMessage{
+		Type:      protocol.TypeSystem,
+		Username:  "System",
+		Content:   "Server restarting, you will be reconnected shortly",
+		Timestamp: time.Now(),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if client.username != "" {
+			notice.Room = ""
+			if client.room != nil {
+				notice.Room = client.room.name
+			}
+			h.sendTo(client, notice)
+		}
+
+		// Fuera de la sala antes de cerrar send, así su goroutine no le escribe
+		if client.room != nil {
+			client.room.remove(client)
+		}
+		client.closeCode = websocket.CloseGoingAway
+		client.closeReason = shutdownReason
+		delete(h.clients, client)
+		client.closeSend()
+	}
+
+	// Ya no queda nadie que escriba en las salas, así terminan sus goroutines
+	for _, room := range h.rooms {
+		close(room.broadcast)
+	}
+}
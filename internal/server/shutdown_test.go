@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdown(t *testing.T) {
+	h, url := testHub(t, HubConfig{ResumeWindow: time.Minute})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+	alice.expectContent(protocol.TypeSystem, "bob joined")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testWait)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// Cada uno recibe el aviso y el close frame, sin anuncios de salidas
+	for _, c := range []*testConn{alice, bob} {
+		for {
+			msg, err := c.read()
+			if err != nil {
+				t.Fatalf("waiting for the shutdown notice: %v", err)
+			}
+			if msg.Type == protocol.TypeSystem && strings.Contains(msg.Content, " left ") {
+				t.Errorf("shutdown announced %q", msg.Content)
+			}
+			if msg.Type == protocol.TypeSystem && strings.HasPrefix(msg.Content, "Server restarting") {
+				break
+			}
+		}
+		c.expectClose(websocket.CloseGoingAway)
+	}
+
+	// Las salas dejan de repartir mensajes
+	for name, room := range h.rooms {
+		select {
+		case _, open := <-room.broadcast:
+			if open {
+				t.Errorf("#%s still has messages to deliver", name)
+			}
+		case <-time.After(testWait):
+			t.Errorf("#%s broadcast channel is still open", name)
+		}
+	}
+
+	// Apagar de nuevo no espera nada
+	if err := h.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
+
+func TestNoConnectionsWhileDraining(t *testing.T) {
+	h, url := testHub(t, HubConfig{})
+	h.draining.Store(true)
+
+	for _, path := range []string{"/ws/chat", "/ws/echo"} {
+		t.Run(path, func(t *testing.T) {
+			_, resp, err := dialHeader(t, url, path, nil)
+			if err == nil {
+				t.Fatal("the connection was accepted while draining")
+			}
+			if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("response = %v, want 503", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+// acá se maneja lo que pasa cuando un cliente no lee sus mensajes tan
+// rápido como le llegan y se le llena el buffer de envío
+
+import (
+	"bubblenet/pkg/protocol"
+	"fmt"
+	"log"
+)
+
+// SlowConsumerPolicy indica qué hacer cuando se llena el buffer de envío
+// de un cliente
+type SlowConsumerPolicy string
+
+const (
+	// Desconectar al cliente, avisando a su sala que se fue por lento
+	SlowDisconnect SlowConsumerPolicy = "disconnect"
+
+	// Descartar los mensajes más viejos del buffer para hacer lugar
+	SlowDropOldest SlowConsumerPolicy = "drop-oldest"
+
+	// Descartar las actualizaciones de estado reemplazadas por otras más
+	// nuevas (lista de usuarios, indicadores de escritura, directorio de
+	// salas); si no alcanza, desconectar
+	SlowCoalesce SlowConsumerPolicy = "coalesce"
+)
+
+// ParseSlowConsumerPolicy valida el nombre de una política
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch policy := SlowConsumerPolicy(name); policy {
+	case SlowDisconnect, SlowDropOldest, SlowCoalesce:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown slow consumer policy %q (use disconnect, drop-oldest or coalesce)", name)
+}
+
+// coalesceKey identifica las actualizaciones de estado que reemplazan a las
+// anteriores con la misma clave, "" si el mensaje no se puede descartar
+func coalesceKey(msg WSMessage) string {
+	switch msg.Type {
+	case protocol.TypeUserList:
+		return "user_list:" + msg.Room
+	case protocol.TypeTyping:
+		return "typing:" + msg.Room + ":" + msg.Username
+	case protocol.TypeRoomList:
+		return "room_list"
+	}
+	return ""
+}
+
+// deliver encola un mensaje para el cliente sin bloquear. Si el buffer está
+// lleno aplica la política del hub y retorna false si no pudo hacer lugar
+func (c *Client) deliver(message outbound) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	select {
+	case c.send <- message:
+		c.hub.metrics.sent.inc(message.msgType)
+		return true
+	default:
+	}
+
+	switch *c.hub.slowPolicy.Load() {
+	case SlowDropOldest:
+		select {
+		case <-c.send:
+			c.hub.metrics.dropped.inc("slow_consumer")
+			c.hub.log("🐢 Send buffer full for %s, dropped its oldest message", c.username)
+		default:
+		}
+		select {
+		case c.send <- message:
+			c.hub.metrics.sent.inc(message.msgType)
+			return true
+		default:
+		}
+
+	case SlowCoalesce:
+		return c.coalesce(message)
+	}
+	return false
+}
+
+// coalesce vacía el buffer, descarta las actualizaciones que tienen una
+// versión más nueva y vuelve a encolar el resto en orden. Retorna false si
+// aun así no entra todo
+func (c *Client) coalesce(message outbound) bool {
+	pending := []outbound{}
+	for drained := false; !drained; {
+		select {
+		case m := <-c.send:
+			pending = append(pending, m)
+		default:
+			drained = true
+		}
+	}
+	pending = append(pending, message)
+
+	// Se queda con la última actualización de cada clave
+	last := make(map[string]int)
+	for i, m := range pending {
+		if m.key != "" {
+			last[m.key] = i
+		}
+	}
+	kept := pending[:0]
+	for i, m := range pending {
+		if m.key == "" || last[m.key] == i {
+			kept = append(kept, m)
+		}
+	}
+	if dropped := len(pending) - len(kept); dropped > 0 {
+		c.hub.metrics.dropped.add("coalesced", int64(dropped))
+		c.hub.log("🐢 Send buffer full for %s, coalesced %d updates", c.username, dropped)
+	}
+
+	for _, m := range kept {
+		select {
+		case c.send <- m:
+		default:
+			return false
+		}
+	}
+	c.hub.metrics.sent.inc(message.msgType)
+	return true
+}
+
+// reportSlow avisa al hub que un cliente no puede recibir más mensajes, una
+// sola vez. Se puede llamar desde cualquier goroutine
+func (c *Client) reportSlow() {
+	if c.slow.CompareAndSwap(false, true) {
+		go func() {
+			select {
+			case c.hub.slowClients <- c:
+			case <-c.hub.done:
+			}
+		}()
+	}
+}
+
+// dropSlow desconecta a un cliente que no puede recibir sus mensajes: sale
+// de su sala con un aviso y recibe un close frame con el motivo
+func (h *Hub) dropSlow(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	h.metrics.slowDisconnects.Add(1)
+	log.Printf("🐢 Disconnecting %s: too slow to keep up", client.username)
+
+	if room := client.room; room != nil {
+		room.leave(client, "too slow")
+		client.room = nil
+		h.broadcastRoomList()
+	}
+	h.disconnect(client, protocol.CloseSlowConsumer, "too slow to keep up")
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"slices"
+	"testing"
+)
+
+// slowClient crea un cliente con un buffer de envío de size mensajes, lleno
+// con los indicados
+func slowClient(policy SlowConsumerPolicy, size int, queued ...outbound) *Client {
+	h := &Hub{metrics: newMetrics()}
+	h.slowPolicy.Store(&policy)
+	c := &Client{hub: h, send: make(chan outbound, size)}
+	for _, m := range queued {
+		c.send <- m
+	}
+	return c
+}
+
+// queued vacía el buffer de envío y retorna los datos en orden
+func queued(c *Client) []string {
+	var data []string
+	for len(c.send) > 0 {
+		data = append(data, string((<-c.send).data))
+	}
+	return data
+}
+
+func TestParseSlowConsumerPolicy(t *testing.T) {
+	for _, name := range []string{"disconnect", "drop-oldest", "coalesce"} {
+		if policy, err := ParseSlowConsumerPolicy(name); err != nil || string(policy) != name {
+			t.Errorf("ParseSlowConsumerPolicy(%q) = %q, %v", name, policy, err)
+		}
+	}
+	if _, err := ParseSlowConsumerPolicy("drop"); err == nil {
+		t.Error("ParseSlowConsumerPolicy accepted an unknown policy")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	tests := []struct {
+		msg  WSMessage
+		want string
+	}{
+		{WSMessage{Type: protocol.TypeUserList, Room: "den"}, "user_list:den"},
+		{WSMessage{Type: protocol.TypeTyping, Room: "den", Username: "alice"}, "typing:den:alice"},
+		{WSMessage{Type: protocol.TypeRoomList}, "room_list"},
+		{WSMessage{Type: protocol.TypeChat, Room: "den"}, ""},
+		{WSMessage{Type: protocol.TypeSystem, Room: "den"}, ""},
+	}
+	for _, tt := range tests {
+		if got := coalesceKey(tt.msg); got != tt.want {
+			t.Errorf("coalesceKey(%s) = %q, want %q", tt.msg.Type, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverPolicies(t *testing.T) {
+	list1 := outbound{data: []byte("list1"), key: "user_list:den"}
+	list2 := outbound{data: []byte("list2"), key: "user_list:den"}
+	chat1 := outbound{data: []byte("chat1")}
+	chat2 := outbound{data: []byte("chat2")}
+
+	tests := []struct {
+		name    string
+		client  *Client
+		message outbound
+		ok      bool
+		want    []string
+	}{
+		{"fits", slowClient(SlowDisconnect, 2, chat1), chat2, true, []string{"chat1", "chat2"}},
+		{"disconnect", slowClient(SlowDisconnect, 2, chat1, list1), chat2, false, []string{"chat1", "list1"}},
+		{"drop oldest", slowClient(SlowDropOldest, 2, chat1, list1), chat2, true, []string{"list1", "chat2"}},
+		{"coalesce", slowClient(SlowCoalesce, 2, list1, chat1), list2, true, []string{"chat1", "list2"}},
+		{"nothing to coalesce", slowClient(SlowCoalesce, 2, list1, chat1), chat2, false, []string{"list1", "chat1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := tt.client.deliver(tt.message); ok != tt.ok {
+				t.Errorf("deliver = %t, want %t", ok, tt.ok)
+			}
+			if got := queued(tt.client); !slices.Equal(got, tt.want) {
+				t.Errorf("buffer = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowConsumerDisconnected(t *testing.T) {
+	h, url := testHub(t, HubConfig{SlowConsumer: SlowDisconnect})
+	alice, _ := join(t, url, "alice", defaultRoom)
+	bob, _ := join(t, url, "bob", defaultRoom)
+	alice.expectContent(protocol.TypeSystem, "bob joined")
+
+	// Como si a bob se le hubiera llenado el buffer
+	room, _ := h.lookupRoom(defaultRoom)
+	room.mu.RLock()
+	for client := range room.clients {
+		if client.username == "bob" {
+			client.reportSlow()
+			client.reportSlow()
+		}
+	}
+	room.mu.RUnlock()
+
+	alice.expectContent(protocol.TypeSystem, "bob left #"+defaultRoom+" (too slow)")
+	bob.expectClose(protocol.CloseSlowConsumer)
+	if n := h.metrics.slowDisconnects.Load(); n != 1 {
+		t.Errorf("slow disconnects = %d, want 1", n)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+// acá se define el almacenamiento del historial de mensajes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Store guarda el historial de mensajes de las salas, sus roles y bans y
+// las salas que crearon los usuarios
+type Store interface {
+	// Append guarda un mensaje en el historial de su sala y lo retorna
+	// con Seq, su posición en el historial de la sala (empieza en 1)
+	Append(msg WSMessage) (WSMessage, error)
+
+	// Recent retorna los últimos n mensajes de una sala, del más viejo al más nuevo
+	Recent(room string, n int) ([]WSMessage, error)
+
+	// Before retorna hasta limit mensajes de una sala con Seq menor a before,
+	// del más viejo al más nuevo. Con before <= 0 se comporta como Recent
+	Before(room string, before int64, limit int) ([]WSMessage, error)
+
+	// Find busca un mensaje de una sala por su ID
+	Find(room, id string) (WSMessage, bool, error)
+
+	// Replace reemplaza un mensaje guardado (mismo ID) por su nueva versión,
+	// se usa para ediciones y borrados
+	Replace(msg WSMessage) error
+
+	// LoadModeration retorna el dueño, los moderadores y los bans guardados
+	// de una sala; una sala sin nada guardado retorna un RoomModeration vacío
+	LoadModeration(room string) (RoomModeration, error)
+
+	// SaveModeration reemplaza el dueño, los moderadores y los bans
+	// guardados de una sala
+	SaveModeration(room string, mod RoomModeration) error
+
+	// SaveRoom guarda una sala creada por un usuario, así al reiniciar el
+	// servidor se vuelve a crear con sus roles y bans
+	SaveRoom(room SavedRoom) error
+
+	// LoadRooms retorna las salas guardadas con SaveRoom
+	LoadRooms() ([]SavedRoom, error)
+
+	// Close libera los recursos del almacenamiento
+	Close() error
+}
+
+// SavedRoom es la configuración de una sala creada por un usuario
+type SavedRoom struct {
+	Name     string `json:"name"`
+	MaxUsers int    `json:"max_users"`
+	Private  bool   `json:"private,omitempty"`
+}
+
+// OpenStore crea el almacenamiento indicado por el flag --store:
+// "memory" guarda los últimos size mensajes por sala en memoria,
+// "file" guarda todo en archivos JSONL dentro del directorio path
+func OpenStore(kind, path string, size int) (Store, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryStore(size), nil
+	case "file":
+		return NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store %q, use memory or file", kind)
+	}
+}
+
+// errMessageNotStored se retorna al reemplazar un mensaje que no está en el historial
+var errMessageNotStored = errors.New("message not found in history")
+
+// pageBefore toma de msgs (ordenados por Seq) los últimos limit
+// mensajes con Seq menor a before
+func pageBefore(msgs []WSMessage, before int64, limit int) []WSMessage {
+	end := len(msgs)
+	if before > 0 {
+		end = sort.Search(len(msgs), func(i int) bool {
+			return msgs[i].Seq >= before
+		})
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	page := make([]WSMessage, end-start)
+	copy(page, msgs[start:end])
+	return page
+}
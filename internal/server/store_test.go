@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// stores arma uno de cada almacenamiento para correr las mismas pruebas
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return map[string]Store{"memory": NewMemoryStore(100), "file": file}
+}
+
+// appendAll guarda un mensaje por contenido en la sala y retorna lo guardado
+func appendAll(t *testing.T, s Store, room string, contents ...string) []WSMessage {
+	t.Helper()
+	var stored []WSMessage
+	for _, content := range contents {
+		msg, err := s.Append(WSMessage{ID: room + "-" + content, Type: protocol.TypeChat, Room: room, Content: content})
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		stored = append(stored, msg)
+	}
+	return stored
+}
+
+// contents retorna el contenido de cada mensaje, en orden
+func contents(msgs []WSMessage) []string {
+	out := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestStoreAppendNumbersEachRoom(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			general := appendAll(t, s, "general", "a", "b", "c")
+			random := appendAll(t, s, "random", "x")
+			for i, msg := range general {
+				if msg.Seq != int64(i+1) {
+					t.Errorf("general[%d].Seq = %d, want %d", i, msg.Seq, i+1)
+				}
+			}
+			if random[0].Seq != 1 {
+				t.Errorf("random[0].Seq = %d, want 1", random[0].Seq)
+			}
+		})
+	}
+}
+
+func TestStoreRecentAndBefore(t *testing.T) {
+	tests := []struct {
+		name   string
+		before int64
+		limit  int
+		want   []string
+	}{
+		{"latest page", 0, 2, []string{"d", "e"}},
+		{"page before a message", 4, 2, []string{"b", "c"}},
+		{"short first page", 2, 5, []string{"a"}},
+		{"nothing before the first message", 1, 5, []string{}},
+		{"limit larger than the history", 0, 10, []string{"a", "b", "c", "d", "e"}},
+	}
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			appendAll(t, s, "general", "a", "b", "c", "d", "e")
+			appendAll(t, s, "random", "x")
+
+			recent, err := s.Recent("general", 3)
+			if err != nil {
+				t.Fatalf("Recent: %v", err)
+			}
+			if got := contents(recent); !slices.Equal(got, []string{"c", "d", "e"}) {
+				t.Errorf("Recent = %v, want [c d e]", got)
+			}
+			all, _ := s.Recent("general", 10)
+			if got := contents(all); !slices.Equal(got, []string{"a", "b", "c", "d", "e"}) {
+				t.Errorf("Recent with a large n = %v, want the whole history", got)
+			}
+			if empty, err := s.Recent("nobody-here", 3); err != nil || len(empty) != 0 {
+				t.Errorf("Recent of an empty room = %v, %v, want nothing", empty, err)
+			}
+
+			for _, tt := range tests {
+				page, err := s.Before("general", tt.before, tt.limit)
+				if err != nil {
+					t.Fatalf("%s: Before: %v", tt.name, err)
+				}
+				if got := contents(page); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: Before(%d, %d) = %v, want %v", tt.name, tt.before, tt.limit, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreFindAndReplace(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			stored := appendAll(t, s, "general", "a", "b")
+
+			edited := stored[0]
+			edited.Content = "a (edited)"
+			if err := s.Replace(edited); err != nil {
+				t.Fatalf("Replace: %v", err)
+			}
+			got, ok, err := s.Find("general", edited.ID)
+			if err != nil || !ok {
+				t.Fatalf("Find = %v, %v, want the message", ok, err)
+			}
+			if got.Content != "a (edited)" || got.Seq != 1 {
+				t.Errorf("Find = %q (seq %d), want the edit with seq 1", got.Content, got.Seq)
+			}
+
+			if _, ok, _ := s.Find("random", edited.ID); ok {
+				t.Error("Find found a message in another room")
+			}
+			missing := WSMessage{ID: "missing", Room: "general"}
+			if err := s.Replace(missing); !errors.Is(err, errMessageNotStored) {
+				t.Errorf("Replace of an unknown message = %v, want errMessageNotStored", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeepsTheLastMessages(t *testing.T) {
+	s := NewMemoryStore(3)
+	appendAll(t, s, "general", "a", "b", "c", "d", "e")
+
+	recent, _ := s.Recent("general", 10)
+	if got := contents(recent); !slices.Equal(got, []string{"c", "d", "e"}) {
+		t.Errorf("Recent = %v, want [c d e]", got)
+	}
+	if recent[0].Seq != 3 {
+		t.Errorf("oldest kept Seq = %d, want 3", recent[0].Seq)
+	}
+	if _, ok, _ := s.Find("general", "general-a"); ok {
+		t.Error("Find found a message that fell out of the buffer")
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := appendAll(t, s, "general", "a", "b")
+	deleted := stored[0]
+	deleted.Content = ""
+	deleted.Deleted = true
+	if err := s.Replace(deleted); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Una línea corrupta no impide cargar el resto
+	f, err := os.OpenFile(filepath.Join(dir, "general.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+
+	// Un historial de antes de Seq se numera por posición
+	legacy := `{"type":"chat","room":"old","content":"first"}` + "\n" + `{"type":"chat","room":"old","content":"second"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "old.jsonl"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+	if next := appendAll(t, s, "general", "c"); next[0].Seq != 3 {
+		t.Errorf("Seq after reloading = %d, want 3", next[0].Seq)
+	}
+	// El borrado reemplaza al original en su lugar
+	msgs, _ := s.Recent("general", 10)
+	if got := contents(msgs); !slices.Equal(got, []string{"", "b", "c"}) || !msgs[0].Deleted || msgs[0].Seq != 1 {
+		t.Errorf("after reloading Recent = %v, want the deletion with seq 1, b and c", got)
+	}
+	old, _ := s.Recent("old", 10)
+	if len(old) != 2 || old[0].Seq != 1 || old[1].Seq != 2 {
+		t.Errorf("legacy history = %+v, want seq 1 and 2", old)
+	}
+}
+
+func TestFileStoreReadsOldPagesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := fileTailSize + 50
+	for i := 1; i <= total; i++ {
+		if _, err := s.Append(WSMessage{Type: protocol.TypeChat, Room: "general", ID: fmt.Sprintf("m%d", i), Content: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Se edita uno que ya no está en memoria
+	edited, ok, err := s.Find("general", "m3")
+	if err != nil || !ok {
+		t.Fatalf("Find of an old message = %v, %v", ok, err)
+	}
+	edited.Content = "edited"
+	if err := s.Replace(edited); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	s.Close()
+
+	s, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+	if tail := len(s.rooms["general"].tail); tail != fileTailSize {
+		t.Errorf("%d messages kept in memory, want %d", tail, fileTailSize)
+	}
+
+	// Una página que cruza el borde de lo que está en memoria
+	edge := int64(total - fileTailSize + 1)
+	page, err := s.Before("general", edge+2, 6)
+	if err != nil {
+		t.Fatalf("Before: %v", err)
+	}
+	if len(page) != 6 || page[0].Seq != edge-4 || page[5].Seq != edge+1 {
+		t.Errorf("page around the tail = %d messages from seq %d, want 6 from %d", len(page), page[0].Seq, edge-4)
+	}
+	first, _ := s.Before("general", 5, 10)
+	if got := contents(first); !slices.Equal(got, []string{"1", "2", "edited", "4"}) {
+		t.Errorf("first page = %v, want 1, 2, edited, 4", got)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	if s, err := OpenStore("memory", "", 10); err != nil {
+		t.Errorf("OpenStore(memory) = %v", err)
+	} else if _, ok := s.(*MemoryStore); !ok {
+		t.Errorf("OpenStore(memory) = %T", s)
+	}
+	if s, err := OpenStore("file", t.TempDir(), 0); err != nil {
+		t.Errorf("OpenStore(file) = %v", err)
+	} else {
+		s.Close()
+	}
+	if _, err := OpenStore("redis", "", 0); err == nil {
+		t.Error("OpenStore accepted an unknown backend")
+	}
+}
+
+func TestHistoryReplayedOnJoin(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	_, url := testHub(t, HubConfig{Store: store}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	for _, content := range []string{"one", "two"} {
+		alice.send(WSMessage{Type: protocol.TypeChat, Content: content})
+		alice.expectContent(protocol.TypeChat, content)
+	}
+
+	// Otro hub con el mismo archivo es el servidor después de reiniciar
+	_, restarted := testHub(t, HubConfig{Store: store}, "den")
+	bob := dial(t, restarted)
+	bob.hello(WSMessage{Username: "bob", Room: "den"})
+	history := bob.expect(protocol.TypeHistory)
+	if got := contents(history.Messages); history.Room != "den" || !slices.Equal(got, []string{"one", "two"}) {
+		t.Errorf("history of %q = %v, want [one two] in den", history.Room, got)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	created, err := EnsureSelfSignedCert(certFile, keyFile, []string{"chat.example", "10.0.0.1"})
+	if err != nil || !created {
+		t.Fatalf("EnsureSelfSignedCert = %t, %v, want a new certificate", created, err)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "chat.example", "10.0.0.1"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("certificate not valid for %s: %v", host, err)
+		}
+	}
+
+	// Si ya existe se reusa
+	if created, err := EnsureSelfSignedCert(certFile, keyFile, nil); err != nil || created {
+		t.Errorf("second EnsureSelfSignedCert = %t, %v, want the existing certificate", created, err)
+	}
+}
+
+func TestChatOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := EnsureSelfSignedCert(certFile, keyFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHub(HubConfig{Store: NewMemoryStore(10)})
+	go h.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testWait)
+		defer cancel()
+		h.Shutdown(ctx)
+	})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(h.HandleChat))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	srv.StartTLS()
+	defer srv.Close()
+	url := "wss" + strings.TrimPrefix(srv.URL, "https")
+
+	// Sin confiar en el certificado autofirmado no se conecta
+	if _, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		t.Fatal("connected without trusting the self-signed certificate")
+	}
+
+	roots := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(pair.Certificate[0])
+	roots.AddCert(cert)
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial with the certificate trusted: %v", err)
+	}
+	c := &testConn{t: t, conn: conn}
+	t.Cleanup(func() { conn.Close() })
+	if welcome := c.hello(WSMessage{Username: "alice"}); welcome.Type != protocol.TypeWelcome {
+		t.Errorf("got %s, want a welcome", welcome.Type)
+	}
+}
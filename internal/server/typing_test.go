@@ -0,0 +1,28 @@
+package server
+
+import (
+	"bubblenet/pkg/protocol"
+	"testing"
+)
+
+func TestTypingIndicator(t *testing.T) {
+	_, url := testHub(t, HubConfig{}, "den")
+	alice, _ := join(t, url, "alice", "den")
+	bob, _ := join(t, url, "bob", "den")
+
+	alice.send(WSMessage{Type: protocol.TypeTyping, Status: "typing"})
+	if msg := bob.expect(protocol.TypeTyping); msg.Username != "alice" || msg.Status != "typing" || msg.Room != "den" {
+		t.Errorf("typing = %+v, want alice typing in den", msg)
+	}
+	// Cualquier otro estado es que dejó de escribir
+	alice.send(WSMessage{Type: protocol.TypeTyping, Status: "idle"})
+	if msg := bob.expect(protocol.TypeTyping); msg.Status != "stopped_typing" {
+		t.Errorf("typing status = %q, want stopped_typing", msg.Status)
+	}
+
+	// Los avisos no quedan en el historial
+	bob.send(WSMessage{Type: protocol.TypeHistoryRequest})
+	if page := bob.expect(protocol.TypeHistoryPage); len(page.Messages) != 0 {
+		t.Errorf("history = %+v, want it empty", page.Messages)
+	}
+}
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"bubblenet/pkg/protocol"
+	"cmp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// commandCapabilities indica qué capacidad del protocolo necesita cada comando
+var commandCapabilities = map[string]string{
+	"msg":      protocol.CapDM,
+	"edit":     protocol.CapEdit,
+	"delete":   protocol.CapEdit,
+	"nick":     protocol.CapNick,
+	"kick":     protocol.CapModeration,
+	"ban":      protocol.CapModeration,
+	"unban":    protocol.CapModeration,
+	"op":       protocol.CapModeration,
+	"deop":     protocol.CapModeration,
+	"mute":     protocol.CapModeration,
+	"unmute":   protocol.CapModeration,
+	"slowmode": protocol.CapModeration,
+	"readonly": protocol.CapModeration,
+}
+
+// runCommand ejecuta un comando escrito en el chat, por ejemplo "/edit texto"
+func (m *Model) runCommand(input string) tea.Cmd {
+	name, args, _ := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+	args = strings.TrimSpace(args)
+	name = strings.ToLower(name)
+
+	if capability, ok := commandCapabilities[name]; ok && !m.wsClient.Can(capability) {
+		m.addSystemMessage("/" + name + " is not supported by this server")
+		return nil
+	}
+
+	switch name {
+	case "msg":
+		// /msg <usuario> <texto>: mensaje directo
+		to, text, _ := strings.Cut(args, " ")
+		text = strings.TrimSpace(text)
+		if to == "" || text == "" {
+			m.addSystemMessage("Usage: /msg <user> <text>")
+			return nil
+		}
+		m.wsClient.SendDirectMessage(to, text)
+
+	case "edit":
+		// /edit <texto>: corrige el último mensaje propio
+		if args == "" {
+			m.addSystemMessage("Usage: /edit <new text>")
+			return nil
+		}
+		last, ok := m.lastOwnMessage()
+		if !ok {
+			m.addSystemMessage("You have no message to edit")
+			return nil
+		}
+		m.wsClient.EditMessage(last.ID, args)
+
+	case "delete":
+		// /delete [usuario]: borra el último mensaje propio, o el último de
+		// otro usuario si quien lo pide modera la sala
+		if strings.Contains(args, " ") {
+			m.addSystemMessage("Usage: /delete [user]")
+			return nil
+		}
+		author := cmp.Or(args, m.config.Username)
+		last, ok := m.lastMessageBy(author)
+		if !ok && args == "" {
+			m.addSystemMessage("You have no message to delete")
+			return nil
+		}
+		if !ok {
+			m.addSystemMessage(author + " has no message to delete")
+			return nil
+		}
+		m.wsClient.DeleteMessage(last.ID)
+
+	case "nick":
+		// /nick <nombre>: cambia el nickname, el servidor confirma el cambio
+		if args == "" || strings.Contains(args, " ") {
+			m.addSystemMessage("Usage: /nick <new name>")
+			return nil
+		}
+		m.nickPending = true
+		m.wsClient.ChangeNick(args)
+
+	case "kick":
+		// /kick <usuario> [motivo]: saca a alguien de la sala
+		target, reason, _ := strings.Cut(args, " ")
+		if target == "" {
+			m.addSystemMessage("Usage: /kick <user> [reason]")
+			return nil
+		}
+		m.wsClient.Moderate(protocol.TypeKick, target, strings.TrimSpace(reason), 0)
+
+	case "ban":
+		// /ban <usuario> [duración] [motivo]: sin duración el ban es permanente
+		target, rest, _ := strings.Cut(args, " ")
+		if target == "" {
+			m.addSystemMessage("Usage: /ban <user> [duration, e.g. 30m, 2h or 7d] [reason]")
+			return nil
+		}
+		rest = strings.TrimSpace(rest)
+		first, reason, _ := strings.Cut(rest, " ")
+		duration, ok := parseBanDuration(first)
+		if !ok {
+			duration, reason = 0, rest
+		}
+		m.wsClient.Moderate(protocol.TypeBan, target, strings.TrimSpace(reason), duration)
+
+	case "mute":
+		// /mute <usuario> [duración] [motivo]: sin duración hasta el /unmute
+		target, rest, _ := strings.Cut(args, " ")
+		if target == "" {
+			m.addSystemMessage("Usage: /mute <user> [duration, e.g. 10m, 1h or 1d] [reason]")
+			return nil
+		}
+		rest = strings.TrimSpace(rest)
+		first, reason, _ := strings.Cut(rest, " ")
+		duration, ok := parseBanDuration(first)
+		if !ok {
+			duration, reason = 0, rest
+		}
+		m.wsClient.Moderate(protocol.TypeMute, target, strings.TrimSpace(reason), duration)
+
+	case "slowmode":
+		// /slowmode <intervalo|off>: tiempo mínimo entre mensajes de cada usuario
+		interval, ok := parseBanDuration(args)
+		if strings.EqualFold(args, "off") || args == "0" {
+			interval, ok = 0, true
+		}
+		if !ok || interval%time.Second != 0 {
+			m.addSystemMessage("Usage: /slowmode <interval in whole seconds, e.g. 10s or 1m | off>")
+			return nil
+		}
+		m.wsClient.SetSlowMode(interval)
+
+	case "readonly":
+		// /readonly on|off: solo el dueño y los moderadores escriben
+		switch strings.ToLower(args) {
+		case "on":
+			m.wsClient.SetReadOnly(true)
+		case "off":
+			m.wsClient.SetReadOnly(false)
+		default:
+			m.addSystemMessage("Usage: /readonly on|off")
+		}
+
+	case "unban", "unmute", "op", "deop":
+		// /unban, /unmute, /op y /deop <usuario>
+		if args == "" || strings.Contains(args, " ") {
+			m.addSystemMessage("Usage: /" + name + " <user>")
+			return nil
+		}
+		m.wsClient.Moderate(name, args, "", 0)
+
+	case "retry":
+		// /retry: reenvía los mensajes que no se confirmaron
+		return m.retryFailed()
+
+	case "help":
+		m.addSystemMessage("Commands: /msg <user> <text> • /edit <text> • /delete • /nick <name> • /retry")
+		if m.wsClient.Can(protocol.CapModeration) {
+			m.addSystemMessage("Moderation: /delete <user> • /kick <user> [reason] • /ban <user> [duration] [reason] • /unban <user> • /op <user> • /deop <user>")
+			m.addSystemMessage("Restrictions: /mute <user> [duration] [reason] • /unmute <user> • /slowmode <interval|off> • /readonly on|off")
+		}
+
+	default:
+		m.addSystemMessage("Unknown command /" + name + ", try /help")
+	}
+	return nil
+}
+
+// maxBanDuration es lo más que el servidor acepta para un ban o un mute
+const maxBanDuration = protocol.MaxExpiresIn * time.Second
+
+// parseBanDuration interpreta la duración de un /ban o un /mute: lo que acepta
+// time.ParseDuration ("30m", "2h") o días ("7d"). Las de más de un año se
+// toman como un año, el máximo del servidor
+func parseBanDuration(s string) (time.Duration, bool) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		// Se compara antes de multiplicar para que no dé la vuelta
+		if n > int(maxBanDuration/(24*time.Hour)) {
+			return maxBanDuration, true
+		}
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+	d, err := time.ParseDuration(s)
+	return min(d, maxBanDuration), err == nil && d >= time.Second
+}
+
+// lastOwnMessage retorna el último mensaje del usuario que se puede modificar
+func (m Model) lastOwnMessage() (Message, bool) {
+	return m.lastMessageBy(m.config.Username)
+}
+
+// lastMessageBy retorna el último mensaje de author que se puede modificar
+func (m Model) lastMessageBy(author string) (Message, bool) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if !msg.IsSystem && !msg.Deleted && msg.ID != "" && msg.Username == author {
+			return msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// applyEdit actualiza en su lugar un mensaje editado o borrado
+func (m *Model) applyEdit(id, content string, deleted bool) {
+	for i := range m.messages {
+		if m.messages[i].ID != id {
+			continue
+		}
+		m.messages[i].Content = content
+		if deleted {
+			m.messages[i].Deleted = true
+		} else {
+			m.messages[i].Edited = true
+		}
+		return
+	}
+}
+
+// addSystemMessage agrega una línea de sistema al chat
+func (m *Model) addSystemMessage(content string) {
+	m.messages = append(m.messages, Message{
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+		IsSystem:  true,
+	})
+}
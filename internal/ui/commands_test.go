@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"bubblenet/internal/client"
+	"bubblenet/pkg/protocol"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient conecta un cliente a un servidor falso que acuerda las
+// capacidades indicadas en el welcome
+func testClient(t *testing.T, capabilities []string) *client.WSClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+		conn.WriteJSON(client.WSMessage{Type: protocol.TypeWelcome, Protocol: protocol.Version, Capabilities: capabilities})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	ws := client.NewWSClient(addr.IP.String(), addr.Port, "alice", false)
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+func TestLastOwnMessage(t *testing.T) {
+	m := Model{config: Config{Username: "alice"}}
+	if _, ok := m.lastOwnMessage(); ok {
+		t.Error("found a message in an empty chat")
+	}
+
+	m.messages = []Message{
+		{ID: "1", Username: "alice", Content: "first"},
+		{ID: "2", Username: "alice", Content: "gone", Deleted: true},
+		{Username: "alice", Content: "not sent yet"},
+		{ID: "3", Username: "bob", Content: "bob's"},
+		{Username: "System", Content: "bob joined", IsSystem: true},
+	}
+	if last, ok := m.lastOwnMessage(); !ok || last.ID != "1" {
+		t.Errorf("lastOwnMessage = %+v, %t, want message 1", last, ok)
+	}
+}
+
+func TestApplyEdit(t *testing.T) {
+	m := Model{messages: []Message{{ID: "1", Content: "helo"}, {ID: "2", Content: "bye"}}}
+
+	m.applyEdit("1", "hello", false)
+	m.applyEdit("2", "", true)
+	m.applyEdit("3", "unknown", false)
+	if got := m.messages[0]; got.Content != "hello" || !got.Edited || got.Deleted {
+		t.Errorf("edited message = %+v", got)
+	}
+	if got := m.messages[1]; got.Content != "" || !got.Deleted {
+		t.Errorf("deleted message = %+v", got)
+	}
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	tests := []struct{ input, want string }{
+		{"/edit", "Usage: /edit"},
+		{"/edit fixed", "no message to edit"},
+		{"/delete", "no message to delete"},
+		{"/msg bob", "Usage: /msg"},
+		{"/nick", "Usage: /nick"},
+		{"/nick two words", "Usage: /nick"},
+		{"/kick", "Usage: /kick"},
+		{"/ban", "Usage: /ban"},
+		{"/op bob carol", "Usage: /op"},
+		{"/mute", "Usage: /mute"},
+		{"/slowmode 1500ms", "Usage: /slowmode"},
+		{"/readonly maybe", "Usage: /readonly"},
+		{"/shrug", "Unknown command /shrug"},
+	}
+	ws := testClient(t, protocol.Capabilities())
+	for _, tt := range tests {
+		m := Model{config: Config{Username: "alice"}, wsClient: ws}
+		m.runCommand(tt.input)
+		if len(m.messages) != 1 || !strings.Contains(m.messages[0].Content, tt.want) {
+			t.Errorf("%s: chat = %+v, want a line with %q", tt.input, m.messages, tt.want)
+		}
+	}
+}
+
+func TestCommandNeedsCapability(t *testing.T) {
+	m := Model{config: Config{Username: "alice"}, wsClient: testClient(t, []string{protocol.CapEdit})}
+	m.runCommand("/msg bob hi")
+	if len(m.messages) != 1 || !strings.Contains(m.messages[0].Content, "/msg is not supported") {
+		t.Errorf("chat = %+v, want /msg rejected", m.messages)
+	}
+}
+
+func TestParseBanDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30m", 30 * time.Minute, true},
+		{"2h", 2 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"365d", 365 * 24 * time.Hour, true},
+		{"400d", 365 * 24 * time.Hour, true},
+		{"99999999999999d", 365 * 24 * time.Hour, true},
+		{"10000h", 365 * 24 * time.Hour, true},
+		{"0d", 0, false},
+		{"500ms", 0, false},
+		{"spam", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseBanDuration(tt.input)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseBanDuration(%q) = %v, %t, want %v, %t", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
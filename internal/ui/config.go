@@ -1,5 +1,11 @@
 package ui
 
+import (
+	"bubblenet/pkg/config"
+	"crypto/tls"
+	"time"
+)
+
 type Config struct {
 	Room     string
 	Private  bool
@@ -7,6 +13,24 @@ type Config struct {
 	Host     string
 	Port     int
 	Username string
+
+	// Credenciales para servidores con autenticación
+	Token    string
+	Password string
+
+	// Conexión cifrada (wss://), nil usa ws://
+	TLS *tls.Config
+
+	// Invitaciones: código para entrar a una sala privada y
+	// opciones de los códigos que se generan con --invite
+	JoinCode   string
+	InviteTTL  time.Duration
+	InviteUses int
+
+	// Colores y atajos del archivo de configuración, vacíos usan los de
+	// siempre
+	Theme config.Theme
+	Keys  config.Keys
 }
 
 func (c Config) GetInitialState() AppState {
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"bubblenet/internal/client"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tiempo que se espera la confirmación de un mensaje estando conectado
+// antes de marcarlo como fallido
+const ackTimeout = 10 * time.Second
+
+// Delivery es el estado de entrega de un mensaje propio
+type Delivery int
+
+const (
+	DeliveryNone    Delivery = iota // mensaje de otro usuario o servidor sin acks
+	DeliveryPending                 // enviado o en el outbox, sin confirmar
+	DeliverySent                    // confirmado por el servidor
+	DeliveryFailed                  // rechazado o sin confirmación a tiempo
+)
+
+// ackTimeoutMsg revisa si un mensaje propio se confirmó a tiempo
+type ackTimeoutMsg struct{ clientID string }
+
+// sendChat envía un mensaje y lo muestra enseguida como pendiente, aunque no
+// haya conexión: queda en el outbox del cliente y sale al reconectar
+func (m *Model) sendChat(content string) tea.Cmd {
+	clientID := m.wsClient.SendMessage(content)
+	if clientID == "" {
+		// El servidor no confirma mensajes, se muestra cuando vuelve de la sala
+		return nil
+	}
+	m.messages = append(m.messages, Message{
+		ClientID:  clientID,
+		Username:  m.config.Username,
+		Content:   content,
+		Timestamp: time.Now(),
+		Delivery:  DeliveryPending,
+	})
+	m.scrollOffset = 0
+	return waitForAck(clientID)
+}
+
+// waitForAck programa la revisión de la confirmación de un mensaje
+func waitForAck(clientID string) tea.Cmd {
+	return tea.Tick(ackTimeout, func(time.Time) tea.Msg {
+		return ackTimeoutMsg{clientID: clientID}
+	})
+}
+
+// checkAck marca como fallido un mensaje que sigue sin confirmar. Sin
+// conexión se sigue esperando, el mensaje sale al reconectar
+func (m *Model) checkAck(clientID string) tea.Cmd {
+	i := m.pendingIndex(clientID)
+	if i < 0 || m.messages[i].Delivery != DeliveryPending {
+		return nil
+	}
+	if m.connectionStatus != client.StatusConnected {
+		return waitForAck(clientID)
+	}
+	m.messages[i].Delivery = DeliveryFailed
+	m.wsClient.Discard(clientID)
+	return nil
+}
+
+// applyAck marca como enviado el mensaje confirmado, con el ID y la posición
+// que le asignó el servidor. Si el mensaje ya llegó de la sala (por ejemplo
+// reenviado al retomar la sesión) se saca la copia local
+func (m *Model) applyAck(ack client.WSMessage) {
+	i := m.pendingIndex(ack.ClientID)
+	if i < 0 {
+		return
+	}
+	if m.hasMessage(ack.RefID) {
+		m.messages = append(m.messages[:i], m.messages[i+1:]...)
+		return
+	}
+	m.messages[i].ID = ack.RefID
+	m.messages[i].Seq = ack.Seq
+	m.messages[i].Timestamp = ack.Timestamp
+	m.messages[i].Delivery = DeliverySent
+}
+
+// rejectPending marca como fallido el mensaje que rechazó el servidor
+func (m *Model) rejectPending(clientID string) {
+	if i := m.pendingIndex(clientID); i >= 0 {
+		m.messages[i].Delivery = DeliveryFailed
+	}
+}
+
+// retryFailed vuelve a enviar los mensajes fallidos de la sala actual
+func (m *Model) retryFailed() tea.Cmd {
+	var cmds []tea.Cmd
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if msg.Delivery != DeliveryFailed {
+			continue
+		}
+		msg.Delivery = DeliveryPending
+		m.wsClient.Resend(msg.ClientID, msg.Content)
+		cmds = append(cmds, waitForAck(msg.ClientID))
+	}
+	if len(cmds) == 0 {
+		m.addSystemMessage("No failed messages to resend")
+		return nil
+	}
+	return tea.Batch(cmds...)
+}
+
+// pendingIndex retorna la posición del mensaje propio con ese clientID, -1
+// si ya no se muestra
+func (m Model) pendingIndex(clientID string) int {
+	if clientID == "" {
+		return -1
+	}
+	for i := range m.messages {
+		if m.messages[i].ClientID == clientID {
+			return i
+		}
+	}
+	return -1
+}
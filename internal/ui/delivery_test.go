@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"bubblenet/internal/client"
+	"testing"
+)
+
+func TestApplyAck(t *testing.T) {
+	m := Model{messages: []Message{
+		{ClientID: "c1", Content: "first", Delivery: DeliveryPending},
+		{ID: "m2", Content: "resent"},
+		{ClientID: "c2", Content: "resent", Delivery: DeliveryPending},
+	}}
+
+	m.applyAck(client.WSMessage{ClientID: "c1", RefID: "m1", Seq: 1})
+	if got := m.messages[0]; got.ID != "m1" || got.Seq != 1 || got.Delivery != DeliverySent {
+		t.Errorf("acked message = %+v, want m1 sent", got)
+	}
+
+	// La sala ya lo mostró, la copia local sobra
+	m.applyAck(client.WSMessage{ClientID: "c2", RefID: "m2", Seq: 2})
+	if len(m.messages) != 2 || m.messages[1].ID != "m2" {
+		t.Errorf("messages = %+v, want the local copy of m2 removed", m.messages)
+	}
+
+	m.applyAck(client.WSMessage{ClientID: "unknown", RefID: "m3"})
+	if len(m.messages) != 2 {
+		t.Errorf("an unknown ack changed the chat: %+v", m.messages)
+	}
+}
+
+func TestRejectPending(t *testing.T) {
+	m := Model{messages: []Message{{ClientID: "c1", Delivery: DeliveryPending}}}
+	m.rejectPending("c1")
+	m.rejectPending("")
+	if m.messages[0].Delivery != DeliveryFailed {
+		t.Errorf("delivery = %v, want failed", m.messages[0].Delivery)
+	}
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"bubblenet/pkg/config"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap son los atajos de teclado de la app
+type keyMap struct {
+	Quit       key.Binding
+	Back       key.Binding
+	Join       key.Binding
+	Create     key.Binding
+	Refresh    key.Binding
+	Send       key.Binding
+	ScrollUp   key.Binding
+	ScrollDown key.Binding
+	PageUp     key.Binding
+	PageDown   key.Binding
+}
+
+// newKeyMap arma los atajos con los del archivo de configuración, usando
+// los de siempre para los que no indica
+func newKeyMap(keys config.Keys) keyMap {
+	binding := func(configured []string, defaults ...string) key.Binding {
+		if len(configured) == 0 {
+			configured = defaults
+		}
+		return key.NewBinding(key.WithKeys(configured...))
+	}
+	return keyMap{
+		Quit:       binding(keys.Quit, "ctrl+c", "q"),
+		Back:       binding(keys.Back, "esc"),
+		Join:       binding(keys.Join, "enter"),
+		Create:     binding(keys.Create, "c"),
+		Refresh:    binding(keys.Refresh, "r"),
+		Send:       binding(keys.Send, "enter"),
+		ScrollUp:   binding(keys.ScrollUp, "up"),
+		ScrollDown: binding(keys.ScrollDown, "down"),
+		PageUp:     binding(keys.PageUp, "pgup"),
+		PageDown:   binding(keys.PageDown, "pgdown"),
+	}
+}
+
+// keyNames son los nombres que se muestran en la ayuda
+var keyNames = map[string]string{
+	"up":     "↑",
+	"down":   "↓",
+	"pgup":   "PgUp",
+	"pgdown": "PgDn",
+	"esc":    "Esc",
+	"enter":  "Enter",
+	" ":      "Space",
+}
+
+// label retorna la tecla principal de un atajo como se muestra en la
+// ayuda: "enter" es "Enter", "q" es "Q" y "ctrl+c" es "Ctrl+C"
+func label(b key.Binding) string {
+	keys := b.Keys()
+	if len(keys) == 0 {
+		return ""
+	}
+	// Se muestra la más corta, que suele ser la más cómoda: "q" antes que
+	// "ctrl+c"
+	name := keys[0]
+	for _, k := range keys[1:] {
+		if len(k) < len(name) {
+			name = k
+		}
+	}
+	if pretty, ok := keyNames[name]; ok {
+		return pretty
+	}
+	parts := strings.Split(name, "+")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
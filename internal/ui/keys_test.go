@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"bubblenet/pkg/config"
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+func TestNewKeyMap(t *testing.T) {
+	keys := newKeyMap(config.Keys{Quit: []string{"ctrl+q"}, PageUp: []string{"ctrl+u", "pgup"}})
+
+	if got := keys.Quit.Keys(); !slices.Equal(got, []string{"ctrl+q"}) {
+		t.Errorf("quit keys = %v, want the configured [ctrl+q]", got)
+	}
+	if got := keys.PageUp.Keys(); !slices.Equal(got, []string{"ctrl+u", "pgup"}) {
+		t.Errorf("page up keys = %v, want the configured ones", got)
+	}
+	if got := keys.Send.Keys(); !slices.Equal(got, []string{"enter"}) {
+		t.Errorf("send keys = %v, want the default [enter]", got)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"ctrl+c", "q"}, "Q"},
+		{[]string{"enter"}, "Enter"},
+		{[]string{"pgup"}, "PgUp"},
+		{[]string{"ctrl+shift+x"}, "Ctrl+Shift+X"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := label(key.NewBinding(key.WithKeys(tt.keys...))); got != tt.want {
+			t.Errorf("label(%v) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	t.Cleanup(func() { applyTheme(config.Theme{}) })
+
+	applyTheme(config.Theme{Accent: "#FF8800", Muted: "245"})
+	if colors.accent != "#FF8800" || colors.muted != "245" {
+		t.Errorf("theme colors = %q and %q, want the configured ones", colors.accent, colors.muted)
+	}
+	if colors.err != defaultPalette.err {
+		t.Errorf("error color = %q, want the default %q", colors.err, defaultPalette.err)
+	}
+}
@@ -7,8 +7,7 @@ func (m Model) LobbyView() string {
 
 	roomsList := m.roomList.View()
 
-	help := helpStyle.Render(
-		"[↑↓] Navigate • [Enter] Join • [C] Create • [R] Refresh • [Q] Quit")
+	help := helpStyle.Render(m.lobbyHelp())
 
 	status := statusStyle.Render(fmt.Sprintf("User: %s", m.config.Username))
 
@@ -18,3 +17,9 @@ func (m Model) LobbyView() string {
 		roomsList,
 		help)
 }
+
+// lobbyHelp arma la ayuda del lobby con los atajos configurados
+func (m Model) lobbyHelp() string {
+	return fmt.Sprintf("[↑↓] Navigate • [%s] Join • [%s] Create • [%s] Refresh • [%s] Quit",
+		label(m.keys.Join), label(m.keys.Create), label(m.keys.Refresh), label(m.keys.Quit))
+}
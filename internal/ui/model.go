@@ -2,6 +2,7 @@ package ui
 
 import (
 	"bubblenet/internal/client"
+	"bubblenet/pkg/protocol"
 	"fmt"
 	"time"
 
@@ -12,6 +13,9 @@ import (
 
 const MaxUsers = 5
 
+// Mensajes que se piden al servidor por cada página de historial
+const HistoryPageSize = 50
+
 type AppState int
 
 const (
@@ -57,6 +61,7 @@ type ChatData struct {
 type User struct {
 	UserName  string
 	UserState string
+	Role      string // owner o moderator, "" para los miembros comunes
 }
 
 type Room struct {
@@ -67,16 +72,26 @@ type Room struct {
 }
 
 type Message struct {
+	ID        string // asignado por el servidor
+	To        string // destinatario, solo en mensajes directos
+	Seq       int64  // posición en el historial de la sala, 0 si no se guardó
 	Username  string
 	Content   string
 	Timestamp time.Time
 	IsSystem  bool
+	Edited    bool
+	Deleted   bool
+
+	// Mensajes propios: ID local hasta la confirmación y estado de entrega
+	ClientID string
+	Delivery Delivery
 }
 
 type Model struct {
 	// estado de la app
 	state  AppState
 	config Config
+	keys   keyMap
 
 	// websocket client
 	wsClient         *client.WSClient
@@ -92,52 +107,40 @@ type Model struct {
 	messages     []Message
 	messageInput textinput.Model
 
+	// conversación de mensajes directos, se muestra aparte de la sala
+	directMessages []Message
+
+	// indicadores de escritura: vencimiento de los de otros usuarios
+	// y cuándo tecleamos / avisamos nosotros por última vez
+	typingUsers    map[string]time.Time
+	lastKeystroke  time.Time
+	lastTypingSent time.Time
+
+	// scroll del chat (líneas desde el final) y carga de historial
+	scrollOffset   int
+	historyLoading bool
+	historyDone    bool
+
+	// slow mode, mute y solo lectura de la sala actual
+	mode roomMode
+
+	// hay un /nick esperando la confirmación del servidor
+	nickPending bool
+
+	// mensaje del día recibido en el welcome
+	motd string
+
 	inviteCode  string
 	currentRoom string
 	errorMsg    string
 
+	// el error (un kick o un ban) permite volver al lobby en vez de salir
+	errorBack bool
+
 	width  int
 	height int
 }
 
-func getMockRooms() []Room {
-	return []Room{
-		{Name: "general", Users: 3, MaxUsers: 5, Private: false},
-		{Name: "development", Users: 1, MaxUsers: 5, Private: false},
-		{Name: "gaming", Users: 4, MaxUsers: 5, Private: false},
-		{Name: "random", Users: 0, MaxUsers: 5, Private: false},
-	}
-}
-
-func getMockMessages(roomName string) []Message {
-	now := time.Now()
-	return []Message{
-		{
-			Username:  "System",
-			Content:   fmt.Sprintf("Welcome to #%s!", roomName),
-			Timestamp: now.Add(-time.Minute * 5),
-			IsSystem:  true,
-		},
-		{
-			Username:  "Alice",
-			Content:   "Hello everyone!",
-			Timestamp: now.Add(-time.Minute * 3),
-			IsSystem:  false,
-		},
-		{
-			Username:  "Bob",
-			Content:   "Hey there! How's everyone doing?",
-			Timestamp: now.Add(-time.Minute * 1),
-			IsSystem:  false,
-		},
-	}
-}
-
-func generateInviteCode(roomName string) string {
-	// En implementación real, sería un UUID o token seguro
-	return fmt.Sprintf("invite-%s-%d", roomName, time.Now().Unix()%10000)
-}
-
 func NewApp(config Config) *Model {
 	// config text input
 	ti := textinput.New()
@@ -150,16 +153,29 @@ func NewApp(config Config) *Model {
 
 	// crea el cliente websocket
 	wsClient := client.NewWSClient(config.Host, config.Port, config.Username, true)
+	wsClient.SetCredentials(config.Token, config.Password)
+	if config.TLS != nil {
+		wsClient.SetTLS(config.TLS)
+	}
+	if config.Room != "" && !config.Private {
+		// La sala de --room se pide en el hello
+		wsClient.SetRoom(config.Room, config.JoinCode)
+	}
+
+	// colores del tema
+	applyTheme(config.Theme)
 
 	model := &Model{
 		state:            StateLoading, // Siempre empezar cargando
 		config:           config,
+		keys:             newKeyMap(config.Keys),
 		wsClient:         wsClient,
 		connectionStatus: client.StatusConnecting, // Empezar conectando
-		rooms:            getMockRooms(),
+		rooms:            []Room{},
 		selectedRoom:     0,
 		roomList:         roomList,
 		messages:         []Message{},
+		typingUsers:      make(map[string]time.Time),
 		messageInput:     ti,
 		currentRoom:      config.Room,
 		inviteCode:       "",
@@ -174,19 +190,46 @@ func NewApp(config Config) *Model {
 func (m *Model) setupInitialData() {
 	switch m.state {
 	case StateLobby:
-		items := make([]list.Item, len(m.rooms))
-		for i, room := range m.rooms {
-			items[i] = roomItem{room}
-		}
-		m.roomList.SetItems(items)
+		m.refreshRoomList()
+	}
+}
 
-	case StateJoining:
-		m.messages = getMockMessages(m.config.Room)
+// newMessage convierte un mensaje del servidor en una línea del chat
+func newMessage(msg client.WSMessage) Message {
+	return Message{
+		ID:        msg.ID,
+		To:        msg.To,
+		Seq:       msg.Seq,
+		Username:  msg.Username,
+		Content:   msg.Content,
+		Timestamp: msg.Timestamp,
+		IsSystem:  msg.Type == protocol.TypeSystem,
+		Edited:    msg.Edited,
+		Deleted:   msg.Deleted,
+	}
+}
 
-	case StateInviting:
-		m.inviteCode = generateInviteCode(m.config.Room)
-		m.messages = getMockMessages(m.config.Room)
+// setRooms reemplaza las salas con el directorio recibido del servidor
+func (m *Model) setRooms(infos []client.RoomInfo) {
+	m.rooms = make([]Room, len(infos))
+	for i, info := range infos {
+		m.rooms[i] = Room{
+			Name:     info.Name,
+			Users:    int64(info.Users),
+			MaxUsers: int8(info.MaxUsers),
+			Private:  info.Private,
+		}
+	}
+	m.refreshRoomList()
+}
+
+// refreshRoomList sincroniza la lista del lobby con m.rooms
+func (m *Model) refreshRoomList() {
+	items := make([]list.Item, len(m.rooms))
+	for i, room := range m.rooms {
+		items[i] = roomItem{room}
 	}
+	m.roomList.SetItems(items)
 }
 
 type roomItem struct {
@@ -210,7 +253,6 @@ func (m Model) Init() tea.Cmd {
 type (
 	loadCompleteMsg struct{}
 	joinCompleteMsg struct{ roomName string }
-	createRoomMsg   struct{ roomName string }
 )
 
 // Comando para conectar WebSocket
@@ -223,7 +265,7 @@ func connectWebSocket(wsClient *client.WSClient) tea.Cmd {
 		}
 		fmt.Printf("DEBUG: WebSocket Connect() returned successfully\n")
 		// Si Connect() no devolvió error, la conexión fue exitosa
-		return wsStatusMsg{status: client.StatusConnected}
+		return wsConnectedMsg{}
 	})
 }
 
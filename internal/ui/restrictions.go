@@ -0,0 +1,141 @@
+package ui
+
+// restricciones para escribir en la sala actual: slow mode, mute y solo
+// lectura, según el último room_mode del servidor. El chat muestra el aviso
+// y la cuenta regresiva al lado del input
+
+import (
+	"bubblenet/internal/client"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// roomModeTickMsg actualiza la cuenta regresiva del slow mode o del mute
+type roomModeTickMsg struct{}
+
+// roomMode son las restricciones de la sala actual
+type roomMode struct {
+	slowMode   time.Duration
+	readOnly   bool
+	muted      bool
+	mutedUntil time.Time // cero si el mute no vence
+	nextPost   time.Time // cuándo el slow mode deja volver a escribir
+	ticking    bool      // hay un roomModeTickMsg en camino
+}
+
+// applyRoomMode guarda las restricciones que mandó el servidor
+func (m *Model) applyRoomMode(msg client.WSMessage) tea.Cmd {
+	if msg.Room != m.currentRoom {
+		return nil
+	}
+	slowMode := time.Duration(msg.SlowMode) * time.Second
+	if slowMode != m.mode.slowMode {
+		// El servidor empieza a contar de nuevo al cambiar el slow mode
+		m.mode.nextPost = time.Time{}
+	}
+	m.mode.slowMode = slowMode
+	m.mode.readOnly = msg.ReadOnly
+	m.mode.muted = msg.Muted
+	m.mode.mutedUntil = time.Time{}
+	if msg.ExpiresAt != nil {
+		m.mode.mutedUntil = *msg.ExpiresAt
+	}
+	return m.tickRoomMode()
+}
+
+// resetRoomMode olvida las restricciones al cambiar de sala
+func (m *Model) resetRoomMode() {
+	m.mode = roomMode{ticking: m.mode.ticking}
+}
+
+// notePost arranca la espera del slow mode después de enviar un mensaje
+func (m *Model) notePost() tea.Cmd {
+	if m.mode.slowMode == 0 || m.exempt() {
+		return nil
+	}
+	m.mode.nextPost = time.Now().Add(m.mode.slowMode)
+	return m.tickRoomMode()
+}
+
+// tickRoomMode programa el próximo paso de la cuenta regresiva, si hay una
+func (m *Model) tickRoomMode() tea.Cmd {
+	if m.mode.ticking || !m.mode.countingDown(time.Now()) {
+		return nil
+	}
+	m.mode.ticking = true
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return roomModeTickMsg{}
+	})
+}
+
+// onRoomModeTick levanta el mute vencido y sigue la cuenta regresiva
+func (m *Model) onRoomModeTick() tea.Cmd {
+	m.mode.ticking = false
+	if m.mode.muted && !m.mode.isMuted(time.Now()) {
+		m.mode.muted = false
+		m.mode.mutedUntil = time.Time{}
+	}
+	return m.tickRoomMode()
+}
+
+// isMuted indica si el mute sigue vigente
+func (r roomMode) isMuted(now time.Time) bool {
+	return r.muted && (r.mutedUntil.IsZero() || now.Before(r.mutedUntil))
+}
+
+// countingDown indica si hay un slow mode o un mute por vencer
+func (r roomMode) countingDown(now time.Time) bool {
+	return now.Before(r.nextPost) || (r.muted && now.Before(r.mutedUntil))
+}
+
+// exempt indica si el usuario es dueño o moderador de la sala, ellos no
+// tienen slow mode y pueden escribir en las salas de solo lectura
+func (m Model) exempt() bool {
+	for _, user := range m.users {
+		if user.UserName == m.config.Username {
+			return user.Role != ""
+		}
+	}
+	return false
+}
+
+// postBlocked indica si el usuario no puede escribir en la sala ahora
+func (m Model) postBlocked() bool {
+	now := time.Now()
+	if m.mode.isMuted(now) {
+		return true
+	}
+	if m.exempt() {
+		return false
+	}
+	return m.mode.readOnly || now.Before(m.mode.nextPost)
+}
+
+// postNotice arma el aviso que va al lado del input: el mute, la sala de
+// solo lectura o el slow mode con lo que falta para poder escribir
+func (m Model) postNotice() string {
+	now := time.Now()
+	switch {
+	case m.mode.isMuted(now) && m.mode.mutedUntil.IsZero():
+		return fmt.Sprintf("🔇 You are muted in #%s", m.currentRoom)
+	case m.mode.isMuted(now):
+		return fmt.Sprintf("🔇 You are muted in #%s for %s", m.currentRoom, countdown(m.mode.mutedUntil.Sub(now)))
+	case m.exempt():
+		return ""
+	case m.mode.readOnly:
+		return "📢 Read-only room, only the owner and moderators can post"
+	case now.Before(m.mode.nextPost):
+		return "🐢 Slow mode: wait " + countdown(m.mode.nextPost.Sub(now))
+	case m.mode.slowMode > 0:
+		return fmt.Sprintf("🐢 Slow mode: one message every %s", countdown(m.mode.slowMode))
+	}
+	return ""
+}
+
+// countdown redondea hacia arriba al segundo, así la cuenta no muestra 0s
+// mientras todavía falta
+func countdown(d time.Duration) string {
+	return (d + time.Second - 1).Truncate(time.Second).String()
+}
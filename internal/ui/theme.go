@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"bubblenet/pkg/config"
+	"cmp"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// palette son los colores de la interfaz
+type palette struct {
+	accent  lipgloss.Color
+	title   lipgloss.Color
+	muted   lipgloss.Color
+	system  lipgloss.Color
+	own     lipgloss.Color
+	err     lipgloss.Color
+	success lipgloss.Color
+	warning lipgloss.Color
+}
+
+// defaultPalette son los colores de siempre, los que no cambia el tema
+var defaultPalette = palette{
+	accent:  "#7D56F4",
+	title:   "#FAFAFA",
+	muted:   "#626262",
+	system:  "#888888",
+	own:     "#00AA00",
+	err:     "#FF0000",
+	success: "#00FF00",
+	warning: "#FFFF00",
+}
+
+// colors es la paleta en uso
+var colors = defaultPalette
+
+func init() {
+	buildStyles()
+}
+
+// applyTheme cambia los colores por los del tema y arma los estilos de nuevo
+func applyTheme(theme config.Theme) {
+	pick := func(color string, fallback lipgloss.Color) lipgloss.Color {
+		return cmp.Or(lipgloss.Color(color), fallback)
+	}
+	colors = palette{
+		accent:  pick(theme.Accent, defaultPalette.accent),
+		title:   pick(theme.Title, defaultPalette.title),
+		muted:   pick(theme.Muted, defaultPalette.muted),
+		system:  pick(theme.System, defaultPalette.system),
+		own:     pick(theme.Own, defaultPalette.own),
+		err:     pick(theme.Error, defaultPalette.err),
+		success: pick(theme.Success, defaultPalette.success),
+		warning: pick(theme.Warning, defaultPalette.warning),
+	}
+	buildStyles()
+}
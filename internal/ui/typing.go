@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"bubblenet/pkg/protocol"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// Intervalo mínimo entre avisos de "typing" mientras se escribe
+	typingThrottle = 2 * time.Second
+
+	// Tiempo sin teclear después del cual se avisa "stopped_typing"
+	typingIdle = 3 * time.Second
+
+	// Tiempo que se muestra un indicador remoto si no llegan actualizaciones
+	typingTimeout = 5 * time.Second
+)
+
+type (
+	// typingIdleMsg revisa si el usuario dejó de escribir
+	typingIdleMsg struct{}
+	// typingExpireMsg limpia los indicadores remotos vencidos
+	typingExpireMsg struct{}
+)
+
+// onInputChanged avisa al servidor que el usuario está escribiendo,
+// como mucho una vez cada typingThrottle
+func (m *Model) onInputChanged() tea.Cmd {
+	if m.messageInput.Value() == "" || strings.HasPrefix(m.messageInput.Value(), "/") || m.postBlocked() {
+		m.stopTyping()
+		return nil
+	}
+
+	now := time.Now()
+	m.lastKeystroke = now
+	if now.Sub(m.lastTypingSent) < typingThrottle {
+		return nil
+	}
+
+	m.lastTypingSent = now
+	m.wsClient.SendTyping(true)
+	return tea.Tick(typingIdle, func(time.Time) tea.Msg {
+		return typingIdleMsg{}
+	})
+}
+
+// checkTypingIdle avisa "stopped_typing" si no hubo teclas en typingIdle,
+// si el usuario sigue escribiendo vuelve a revisar más tarde
+func (m *Model) checkTypingIdle() tea.Cmd {
+	if m.lastTypingSent.IsZero() {
+		return nil
+	}
+	idle := time.Since(m.lastKeystroke)
+	if idle >= typingIdle {
+		m.stopTyping()
+		return nil
+	}
+	return tea.Tick(typingIdle-idle, func(time.Time) tea.Msg {
+		return typingIdleMsg{}
+	})
+}
+
+// stopTyping avisa que el usuario dejó de escribir, si había avisado lo contrario
+func (m *Model) stopTyping() {
+	if m.lastTypingSent.IsZero() {
+		return
+	}
+	m.lastTypingSent = time.Time{}
+	m.wsClient.SendTyping(false)
+}
+
+// setTyping registra el indicador de otro usuario de la sala
+func (m *Model) setTyping(username, status string) tea.Cmd {
+	if username == m.config.Username {
+		return nil
+	}
+	if status != protocol.StatusTyping {
+		delete(m.typingUsers, username)
+		return nil
+	}
+	m.typingUsers[username] = time.Now().Add(typingTimeout)
+	return tea.Tick(typingTimeout, func(time.Time) tea.Msg {
+		return typingExpireMsg{}
+	})
+}
+
+// expireTyping borra los indicadores que no se actualizaron a tiempo
+func (m *Model) expireTyping() {
+	now := time.Now()
+	for username, expires := range m.typingUsers {
+		if now.After(expires) {
+			delete(m.typingUsers, username)
+		}
+	}
+}
+
+// typingLine arma el texto "alice is typing…" con los indicadores vigentes
+func (m Model) typingLine() string {
+	now := time.Now()
+	var names []string
+	for username, expires := range m.typingUsers {
+		if now.Before(expires) {
+			names = append(names, username)
+		}
+	}
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0] + " is typing…"
+	case 2:
+		return names[0] + " and " + names[1] + " are typing…"
+	default:
+		return "several people are typing…"
+	}
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypingLine(t *testing.T) {
+	m := Model{config: Config{Username: "alice"}, typingUsers: make(map[string]time.Time)}
+
+	// El propio usuario no se muestra
+	m.setTyping("alice", "typing")
+	if line := m.typingLine(); line != "" {
+		t.Errorf("own indicator shown: %q", line)
+	}
+
+	steps := []struct {
+		username, status, want string
+	}{
+		{"carol", "typing", "carol is typing…"},
+		{"bob", "typing", "bob and carol are typing…"},
+		{"dave", "typing", "several people are typing…"},
+		{"dave", "stopped_typing", "bob and carol are typing…"},
+	}
+	for _, step := range steps {
+		m.setTyping(step.username, step.status)
+		if line := m.typingLine(); line != step.want {
+			t.Errorf("after %s %s: %q, want %q", step.username, step.status, line, step.want)
+		}
+	}
+}
+
+func TestExpireTyping(t *testing.T) {
+	m := Model{typingUsers: map[string]time.Time{
+		"bob":   time.Now().Add(-time.Second),
+		"carol": time.Now().Add(time.Minute),
+	}}
+	m.expireTyping()
+	if _, ok := m.typingUsers["bob"]; ok {
+		t.Error("an expired indicator was kept")
+	}
+	if line := m.typingLine(); line != "carol is typing…" {
+		t.Errorf("typingLine = %q, want carol", line)
+	}
+}
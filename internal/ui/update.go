@@ -2,15 +2,15 @@ package ui
 
 import (
 	"bubblenet/internal/client"
+	"bubblenet/pkg/protocol"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-type reconnectMsg struct{}
-
 // Update maneja los mensajes y actualiza el modelo
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -47,25 +47,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case createRoomMsg:
-		m.state = StateChat
-		m.currentRoom = msg.roomName
-		return m, nil
-
 	case wsConnectedMsg:
 		m.connectionStatus = client.StatusConnected
 		m.errorMsg = ""
+		// El welcome trae el mensaje del día y el directorio de salas
+		m.adoptWelcome(m.wsClient.Welcome())
 		// Determinar el estado correcto según la configuración inicial
-		m.state = m.config.GetInitialState()
-		return m, listenForWSMessages(m.wsClient)
+		cmd := m.enterInitialState()
+		return m, tea.Batch(listenForWSMessages(m.wsClient), cmd)
 
 	case wsErrorMsg:
 		m.connectionStatus = client.StatusError
 		m.errorMsg = fmt.Sprintf("Connection error: %v", msg.err)
 		m.state = StateError
-		return m, tea.Tick(time.Second*3, func(t time.Time) tea.Msg {
-			return reconnectMsg{}
-		})
+		// El cliente reintenta solo los errores de red, los que llegan acá
+		// (credenciales, hello rechazado) no se arreglan reintentando
+		return m, nil
 
 	case wsStatusMsg:
 		fmt.Printf("DEBUG: Received wsStatusMsg with status: %v (current status: %v)\n", msg.status, m.connectionStatus)
@@ -76,54 +73,124 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			fmt.Printf("DEBUG: Ignored status downgrade from Connected to Connecting\n")
 		}
-		if msg.status == client.StatusConnected {
-			// Determinar el estado correcto según la configuración inicial
-			m.state = m.config.GetInitialState()
-			// Configurar datos iniciales para el nuevo estado
-			if m.state == StateLobby {
-				// Configurar lista de salas
-				items := make([]list.Item, len(m.rooms))
-				for i, room := range m.rooms {
-					items[i] = roomItem{room}
-				}
-				m.roomList.SetItems(items)
-			}
-			return m, listenForWSMessages(m.wsClient)
-		} else if msg.status == client.StatusError {
+		if msg.status == client.StatusError {
 			m.state = StateError
 		}
-		return m, nil
+		if msg.status == client.StatusConnected && m.state != StateLoading {
+			// Reconectó: si la sesión no se pudo retomar el servidor nos dio
+			// una nueva, con el nombre que haya podido asignar
+			m.adoptWelcome(m.wsClient.Welcome())
+		}
+		// Seguir escuchando, el estado inicial se configura con wsConnectedMsg
+		return m, listenForWSMessages(m.wsClient)
 
 	case wsMessageMsg:
 		// Manejar diferentes tipos de mensajes
 		switch msg.message.Type {
-		case "user_list":
+		case protocol.TypeRoomList:
+			// Actualizar directorio de salas del lobby
+			m.setRooms(msg.message.Rooms)
+
+		case protocol.TypeRoomCreated:
+			// El servidor creó la sala y ya nos unió a ella
+			m.handleRoomCreated(msg.message)
+
+		case protocol.TypeInvite:
+			// Código de invitación emitido por el servidor
+			m.inviteCode = msg.message.InviteCode
+			if m.state != StateInviting {
+				m.messages = append(m.messages, Message{
+					Username:  msg.message.Username,
+					Content:   fmt.Sprintf("%s: %s", msg.message.Content, msg.message.InviteCode),
+					Timestamp: msg.message.Timestamp,
+					IsSystem:  true,
+				})
+			}
+
+		case protocol.TypeAck:
+			// El servidor guardó un mensaje propio
+			m.applyAck(msg.message)
+
+		case protocol.TypeError:
+			m.handleServerError(msg.message)
+
+		case protocol.TypeUserList:
 			// Actualizar lista de usuarios
 			m.users = []User{}
 			for _, username := range msg.message.Users {
 				m.users = append(m.users, User{
 					UserName:  username,
 					UserState: "online",
+					Role:      msg.message.Roles[username],
 				})
 			}
+
+		case protocol.TypeHistory:
+			// Historial de la sala, va antes de los mensajes ya recibidos
+			if msg.message.Room == m.currentRoom {
+				m.prependHistory(msg.message.Messages)
+				m.historyDone = m.oldestSeq() == 1
+			}
+
+		case protocol.TypeTyping:
+			// Indicador de escritura de otro usuario de la sala
+			if msg.message.Room == m.currentRoom {
+				return m, tea.Batch(listenForWSMessages(m.wsClient), m.setTyping(msg.message.Username, msg.message.Status))
+			}
+
+		case protocol.TypeNick:
+			// El servidor confirmó el cambio de nickname
+			m.config.Username = msg.message.Username
+			m.wsClient.SetUsername(msg.message.Username)
+			m.nickPending = false
+
+		case protocol.TypeDM:
+			// Mensaje directo, va al panel de DMs y no al chat de la sala
+			m.directMessages = append(m.directMessages, newMessage(msg.message))
+
+		case protocol.TypeEdit, protocol.TypeDelete:
+			// Edición o borrado de un mensaje ya mostrado
+			m.applyEdit(msg.message.RefID, msg.message.Content, msg.message.Type == protocol.TypeDelete)
+
+		case protocol.TypeRoomMode:
+			// Restricciones para escribir en la sala, van junto al input
+			return m, tea.Batch(listenForWSMessages(m.wsClient), m.applyRoomMode(msg.message))
+
+		case protocol.TypeHistoryPage:
+			// Página de mensajes más viejos pedida al scrollear
+			if msg.message.Room == m.currentRoom {
+				m.prependHistory(msg.message.Messages)
+				m.historyLoading = false
+				m.historyDone = !msg.message.HasMore
+			}
+
 		default:
-			// Mensaje de chat normal o sistema
-			newMsg := Message{
-				Username:  msg.message.Username,
-				Content:   msg.message.Content,
-				Timestamp: msg.message.Timestamp,
-				IsSystem:  msg.message.Type == "system",
+			// Mensaje de chat normal o sistema (puede haber llegado ya con el historial)
+			if m.hasMessage(msg.message.ID) {
+				break
+			}
+			m.messages = append(m.messages, newMessage(msg.message))
+			// Si el usuario está leyendo mensajes viejos, no mover la vista
+			if m.scrollOffset > 0 {
+				m.scrollOffset++
 			}
-			m.messages = append(m.messages, newMsg)
 		}
 
 		return m, listenForWSMessages(m.wsClient)
 
-	case reconnectMsg:
-		if m.connectionStatus != client.StatusConnected {
-			return m, connectWebSocket(m.wsClient)
-		}
+	case ackTimeoutMsg:
+		return m, m.checkAck(msg.clientID)
+
+	case typingIdleMsg:
+		return m, m.checkTypingIdle()
+
+	case typingExpireMsg:
+		m.expireTyping()
 		return m, nil
+
+	case roomModeTickMsg:
+		return m, m.onRoomModeTick()
+
 	}
 	// Actualizar componentes según el estado actual
 	return m.updateComponents(msg)
@@ -131,28 +198,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleKeyPress maneja las teclas presionadas
 func (m Model) handleKeyPress(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q":
-		if m.state == StateLobby || m.state == StateInviting {
+	switch {
+	case key.Matches(msg, m.keys.Quit):
+		if m.state == StateLobby || m.state == StateInviting || m.state == StateError {
 			return m, tea.Quit
 		}
 		// En chat, 'q' vuelve al lobby
 		if m.state == StateChat {
-			m.state = StateLobby
-			m.currentRoom = ""
-			m.messages = []Message{}
+			m.leaveRoom()
 			return m, nil
 		}
 
-	case "esc":
+	case key.Matches(msg, m.keys.Back):
 		// Esc siempre vuelve al estado anterior o sale
 		switch m.state {
 		case StateChat, StateCreating, StateInviting:
-			m.state = StateLobby
-			m.currentRoom = ""
-			m.messages = []Message{}
+			m.leaveRoom()
 			m.errorMsg = ""
 			return m, nil
+		case StateError:
+			// Después de un kick o un ban se puede seguir en otra sala
+			if m.errorBack {
+				m.errorBack = false
+				m.errorMsg = ""
+				m.state = StateLobby
+				m.wsClient.RequestRoomList()
+				return m, nil
+			}
+			return m, tea.Quit
 		default:
 			return m, tea.Quit
 		}
@@ -175,35 +248,29 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 // handleLobbyKeys maneja teclas en el lobby
 func (m Model) handleLobbyKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "enter":
+	switch {
+	case key.Matches(msg, m.keys.Join):
 		// Unirse a la sala seleccionada (solo si está conectado)
 		if m.connectionStatus == client.StatusConnected && len(m.rooms) > 0 {
 			selected := m.roomList.SelectedItem()
 			if roomItem, ok := selected.(roomItem); ok {
-				m.currentRoom = roomItem.room.Name
-				m.messages = getMockMessages(roomItem.room.Name)
-				m.state = StateChat
+				m.enterRoom(roomItem.room.Name)
 				return m, nil
 			}
 		}
 
-	case "c":
+	case key.Matches(msg, m.keys.Create):
 		// Crear nueva sala (solo si está conectado)
 		if m.connectionStatus == client.StatusConnected {
 			m.state = StateCreating
+			m.errorMsg = ""
 			return m, nil
 		}
 
-	case "r":
+	case key.Matches(msg, m.keys.Refresh):
 		// Refrescar - reconectar si no está conectado, refrescar salas si está conectado
 		if m.connectionStatus == client.StatusConnected {
-			m.rooms = getMockRooms()
-			items := make([]list.Item, len(m.rooms))
-			for i, room := range m.rooms {
-				items[i] = roomItem{room}
-			}
-			m.roomList.SetItems(items)
+			m.wsClient.RequestRoomList()
 		} else {
 			// Intentar reconectar
 			m.connectionStatus = client.StatusConnecting
@@ -220,34 +287,67 @@ func (m Model) handleLobbyKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 // handleChatKeys maneja teclas en el chat
 func (m Model) handleChatKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "enter":
+	switch {
+	case key.Matches(msg, m.keys.Send):
 		// Enviar mensaje real al servidor
 		if m.messageInput.Value() != "" {
 			content := m.messageInput.Value()
 
-			// Enviar al servidor via WebSocket
-			if m.connectionStatus == client.StatusConnected {
-				m.wsClient.SendMessage(content)
+			// Los mensajes se envían aunque no haya conexión, quedan
+			// pendientes en el outbox y salen al reconectar
+			var cmd tea.Cmd
+			if strings.HasPrefix(content, "/") && m.connectionStatus == client.StatusConnected {
+				cmd = m.runCommand(content)
+			} else if strings.HasPrefix(content, "/") {
+				m.addSystemMessage("⚠️ Not connected to server. Commands are unavailable until it reconnects.")
+			} else if m.postBlocked() {
+				// Muteado, sala de solo lectura o slow mode: el texto queda
+				// en el input y el aviso al lado dice por qué
+				return m, nil
 			} else {
-				// Si no hay conexión, mostrar error
-				errorMsg := Message{
-					Username:  "System",
-					Content:   "⚠️ Not connected to server. Cannot send message.",
-					Timestamp: time.Now(),
-					IsSystem:  true,
-				}
-				m.messages = append(m.messages, errorMsg)
+				cmd = tea.Batch(m.sendChat(content), m.notePost())
 			}
 
 			m.messageInput.SetValue("")
+			m.stopTyping()
+			return m, cmd
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.ScrollUp, m.keys.PageUp):
+		// Scrollear hacia mensajes más viejos
+		step := 1
+		if key.Matches(msg, m.keys.PageUp) {
+			step = m.messageAreaHeight()
+		}
+		m.scrollOffset += step
+		if maxOffset := m.maxScrollOffset(); m.scrollOffset > maxOffset {
+			// Se pasó del principio: pedir la página anterior al servidor
+			m.scrollOffset = maxOffset
+			m.loadOlderMessages()
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.ScrollDown, m.keys.PageDown):
+		// Scrollear hacia los mensajes nuevos
+		step := 1
+		if key.Matches(msg, m.keys.PageDown) {
+			step = m.messageAreaHeight()
+		}
+		m.scrollOffset -= step
+		if m.scrollOffset < 0 {
+			m.scrollOffset = 0
 		}
 		return m, nil
 	}
 
 	// Pasar input al componente de texto
 	var cmd tea.Cmd
+	before := m.messageInput.Value()
 	m.messageInput, cmd = m.messageInput.Update(msg)
+	if m.messageInput.Value() != before {
+		return m, tea.Batch(cmd, m.onInputChanged())
+	}
 	return m, cmd
 }
 
@@ -255,8 +355,10 @@ func (m Model) handleChatKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 func (m Model) handleInvitingKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter", "space":
-		// Ir al chat después de mostrar la invitación
-		m.state = StateChat
+		// Ir al chat después de mostrar la invitación (ya estamos en la sala)
+		if m.inviteCode != "" {
+			m.showRoom(m.currentRoom)
+		}
 		return m, nil
 	}
 	return m, nil
@@ -266,12 +368,12 @@ func (m Model) handleInvitingKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 func (m Model) handleCreatingKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
-		// Crear sala con el nombre ingresado
+		// Pedir al servidor crear la sala, se espera room_created o error
 		roomName := m.messageInput.Value()
 		if roomName != "" {
-			return m, tea.Cmd(func() tea.Msg {
-				return createRoomMsg{roomName: roomName}
-			})
+			m.errorMsg = ""
+			m.wsClient.CreateRoom(roomName, MaxUsers, false)
+			return m, nil
 		}
 	}
 
@@ -281,6 +383,209 @@ func (m Model) handleCreatingKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+// enterInitialState pasa al estado inicial según la configuración y
+// hace los pedidos al servidor que ese estado necesita
+func (m *Model) enterInitialState() tea.Cmd {
+	m.state = m.config.GetInitialState()
+
+	switch m.state {
+	case StateJoining, StateInviting:
+		if m.config.Private {
+			// Crear la sala privada, se continúa al recibir room_created
+			m.wsClient.CreateRoom(m.config.Room, MaxUsers, true)
+			return nil
+		}
+		// La sala indicada con --room ya se pidió en el hello
+		roomName := m.config.Room
+		return func() tea.Msg {
+			return joinCompleteMsg{roomName: roomName}
+		}
+
+	case StateLobby:
+		// Pedir el directorio de salas al servidor
+		m.refreshRoomList()
+		m.wsClient.RequestRoomList()
+	}
+	return nil
+}
+
+// adoptWelcome toma del welcome el mensaje del día, el directorio de salas
+// y el nombre que nos asignó el servidor
+func (m *Model) adoptWelcome(welcome client.WSMessage) {
+	m.motd = welcome.MOTD
+	m.setRooms(welcome.Rooms)
+	if welcome.Username != "" && welcome.Username != m.config.Username {
+		m.config.Username = welcome.Username
+		m.wsClient.SetUsername(welcome.Username)
+	}
+}
+
+// handleRoomCreated continúa el flujo que pidió crear la sala
+func (m *Model) handleRoomCreated(msg client.WSMessage) {
+	// Adoptar el nombre normalizado por el servidor
+	m.wsClient.JoinRoom(msg.Room, "")
+
+	switch m.state {
+	case StateCreating, StateJoining:
+		m.messageInput.SetValue("")
+		m.showRoom(msg.Room)
+		m.messages = append(m.messages, Message{
+			Username:  msg.Username,
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+			IsSystem:  true,
+		})
+
+	case StateInviting:
+		// Sala privada con --invite: pedir el código antes de ir al chat
+		m.currentRoom = msg.Room
+		m.wsClient.RequestInvite(m.config.InviteTTL, m.config.InviteUses)
+	}
+}
+
+// enterRoom une al usuario a una sala y pasa a la vista de chat
+func (m *Model) enterRoom(roomName string) {
+	m.wsClient.JoinRoom(roomName, "")
+	m.showRoom(roomName)
+}
+
+// showRoom pasa a la vista de chat de una sala
+func (m *Model) showRoom(roomName string) {
+	m.errorMsg = ""
+	m.typingUsers = make(map[string]time.Time)
+	m.lastTypingSent = time.Time{}
+	m.scrollOffset = 0
+	m.historyLoading = false
+	m.historyDone = false
+	m.currentRoom = roomName
+	m.messages = []Message{}
+	m.users = []User{}
+	m.resetRoomMode()
+	m.state = StateChat
+	if m.motd != "" {
+		m.addSystemMessage(m.motd)
+	}
+}
+
+// leaveRoom sale de la sala actual y vuelve al lobby
+func (m *Model) leaveRoom() {
+	m.stopTyping()
+	m.wsClient.LeaveRoom()
+	m.wsClient.RequestRoomList()
+	m.state = StateLobby
+	m.currentRoom = ""
+	m.messages = []Message{}
+	m.users = []User{}
+	m.resetRoomMode()
+}
+
+// loadOlderMessages pide al servidor la página anterior al mensaje más viejo
+func (m *Model) loadOlderMessages() {
+	if m.historyLoading || m.historyDone || m.currentRoom == "" {
+		return
+	}
+	oldest := m.oldestSeq()
+	if oldest == 1 || !m.wsClient.Can(protocol.CapHistory) {
+		m.historyDone = true
+		return
+	}
+	m.historyLoading = true
+	m.wsClient.RequestHistory(m.currentRoom, oldest, HistoryPageSize)
+}
+
+// prependHistory agrega mensajes del historial antes de los actuales
+func (m *Model) prependHistory(msgs []client.WSMessage) {
+	history := make([]Message, 0, len(msgs)+len(m.messages))
+	for _, msg := range msgs {
+		if m.hasMessage(msg.ID) {
+			continue
+		}
+		history = append(history, newMessage(msg))
+	}
+	m.messages = append(history, m.messages...)
+}
+
+// hasMessage indica si ya se muestra el mensaje con ese ID
+func (m Model) hasMessage(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, msg := range m.messages {
+		if msg.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// oldestSeq retorna el Seq del mensaje guardado más viejo, 0 si no hay ninguno
+func (m Model) oldestSeq() int64 {
+	for _, msg := range m.messages {
+		if msg.Seq > 0 {
+			return msg.Seq
+		}
+	}
+	return 0
+}
+
+// handleServerError muestra el motivo de un rechazo del servidor
+func (m *Model) handleServerError(msg client.WSMessage) {
+	if msg.ClientID != "" {
+		// Rechazo de un mensaje propio, se marca para reenviarlo con /retry
+		m.rejectPending(msg.ClientID)
+		m.addSystemMessage(msg.Content)
+		return
+	}
+	switch msg.Code {
+	case protocol.CodeRateLimited, protocol.CodeMuted, protocol.CodeSlowMode, protocol.CodeReadOnly:
+		// Avisos de la protección contra floods y de las restricciones de
+		// la sala, se muestran en el chat
+		m.addSystemMessage("⚠️ " + msg.Content)
+		return
+	}
+
+	if msg.Code == protocol.CodeNicknameTaken || msg.Code == protocol.CodeInvalidNickname {
+		if m.nickPending {
+			// Falló un /nick, se sigue con el nombre anterior
+			m.nickPending = false
+			m.addSystemMessage(msg.Content)
+			return
+		}
+		// Sin un nickname aceptado el servidor ignora todo lo demás
+		m.state = StateError
+		m.errorMsg = msg.Content + ", restart with a different --user"
+		return
+	}
+
+	if msg.Code == protocol.CodeKicked || msg.Code == protocol.CodeBanned {
+		// Echado o baneado de la sala, o rechazado al entrar por un ban: el
+		// motivo va en la pantalla de error y desde ahí se vuelve al lobby
+		m.leaveRoom()
+		m.state = StateError
+		m.errorMsg = msg.Content
+		m.errorBack = true
+		return
+	}
+
+	m.errorMsg = msg.Content
+
+	// Si falló el flujo de --room, volver al lobby con el motivo
+	if m.state == StateJoining || m.state == StateInviting {
+		m.leaveRoom()
+		m.errorMsg = msg.Content
+		return
+	}
+
+	switch msg.Code {
+	case protocol.CodeRoomNotFound, protocol.CodeRoomFull, protocol.CodeInviteRequired, protocol.CodeInvalidInvite:
+		// No se pudo entrar a la sala, volver al lobby con el motivo
+		if m.state == StateChat {
+			m.leaveRoom()
+			m.errorMsg = msg.Content
+		}
+	}
+}
+
 // updateComponents actualiza los componentes específicos
 func (m Model) updateComponents(msg tea.Msg) (Model, tea.Cmd) {
 	var cmds []tea.Cmd
@@ -3,42 +3,67 @@ package ui
 
 import (
 	"bubblenet/internal/client"
+	"bubblenet/pkg/protocol"
 	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
-// Estilos
+// Estilos, armados con la paleta del tema
 var (
+	titleStyle         lipgloss.Style
+	statusStyle        lipgloss.Style
+	helpStyle          lipgloss.Style
+	errorStyle         lipgloss.Style
+	messageStyle       lipgloss.Style
+	systemMessageStyle lipgloss.Style
+	userMessageStyle   lipgloss.Style
+	directPaneStyle    lipgloss.Style
+	noticeStyle        lipgloss.Style
+)
+
+// buildStyles arma los estilos con los colores de la paleta en uso
+func buildStyles() {
 	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.Color("#7D56F4")).
-			Padding(0, 1)
+		Bold(true).
+		Foreground(colors.title).
+		Background(colors.accent).
+		Padding(0, 1)
 
 	statusStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262")).
-			Align(lipgloss.Right)
+		Foreground(colors.muted).
+		Align(lipgloss.Right)
 
 	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262"))
+		Foreground(colors.muted)
 
 	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF0000")).
-			Bold(true)
+		Foreground(colors.err).
+		Bold(true)
 
 	messageStyle = lipgloss.NewStyle().
-			Padding(0, 1)
+		Padding(0, 1)
 
 	systemMessageStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#888888")).
-				Italic(true)
+		Foreground(colors.system).
+		Italic(true)
 
 	userMessageStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#00AA00")).
-				Bold(true)
-)
+		Foreground(colors.own).
+		Bold(true)
+
+	directPaneStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colors.accent).
+		Padding(0, 1)
+
+	noticeStyle = lipgloss.NewStyle().
+		Foreground(colors.warning)
+}
+
+// Mensajes directos visibles en el panel de DMs
+const directPaneLines = 4
 
 // View renderiza la interfaz de usuario
 func (m Model) View() string {
@@ -70,16 +95,16 @@ func (m Model) loadingView() string {
 	switch m.connectionStatus {
 	case client.StatusConnecting:
 		statusText = "Connecting to server..."
-		statusColor = "#FFFF00"
+		statusColor = colors.warning
 	case client.StatusConnected:
 		statusText = "Connected! Loading..."
-		statusColor = "#00FF00"
+		statusColor = colors.success
 	case client.StatusError:
 		statusText = fmt.Sprintf("Connection failed: %s", m.errorMsg)
-		statusColor = "#FF0000"
+		statusColor = colors.err
 	default:
 		statusText = "Initializing..."
-		statusColor = "#888888"
+		statusColor = colors.system
 	}
 	
 	statusStyle := lipgloss.NewStyle().Foreground(statusColor)
@@ -98,13 +123,15 @@ func (m Model) lobbyView() string {
 	var connectionColor lipgloss.Color
 	switch m.connectionStatus {
 	case client.StatusConnected:
-		connectionColor = "#00FF00"
-	case client.StatusConnecting:
-		connectionColor = "#FFFF00"
+		connectionColor = colors.success
+	case client.StatusConnecting, client.StatusReconnecting:
+		connectionColor = colors.warning
+	case client.StatusRestarting:
+		connectionColor = colors.system
 	case client.StatusError:
-		connectionColor = "#FF0000"
+		connectionColor = colors.err
 	default:
-		connectionColor = "#888888"
+		connectionColor = colors.system
 	}
 	
 	connectionStyle := lipgloss.NewStyle().Foreground(connectionColor)
@@ -119,17 +146,26 @@ func (m Model) lobbyView() string {
 	if m.connectionStatus == client.StatusConnected {
 		// Lista de salas disponible
 		roomsList := m.roomList.View()
-		help = helpStyle.Render(
-			"[↑↓] Navigate • [Enter] Join • [C] Create • [R] Refresh • [Q] Quit")
+		help = helpStyle.Render(m.lobbyHelp())
 		content = roomsList
+		if m.motd != "" {
+			content = statusStyle.Render(m.motd) + "\n\n" + content
+		}
+		if m.errorMsg != "" {
+			content += "\n" + errorStyle.Render("⚠️ "+m.errorMsg)
+		}
 	} else {
 		// Mensaje de espera
 		var message string
 		switch m.connectionStatus {
 		case client.StatusConnecting:
 			message = "Connecting to server, please wait..."
+		case client.StatusReconnecting:
+			message = "Connection lost, reconnecting..."
+		case client.StatusRestarting:
+			message = "Server is restarting, you will be reconnected shortly..."
 		case client.StatusError:
-			message = errorStyle.Render("Connection failed. Retrying...")
+			message = errorStyle.Render("Connection failed.")
 		default:
 			message = "Initializing connection..."
 		}
@@ -155,6 +191,25 @@ func (m Model) joiningView() string {
 func (m Model) invitingView() string {
 	title := titleStyle.Render("ROOM CREATED")
 
+	// El código lo emite el servidor después de crear la sala
+	if m.inviteCode == "" {
+		return fmt.Sprintf("\n\n   %s\n\n   Creating private room #%s and generating invite code...\n\n",
+			title, m.config.Room)
+	}
+
+	// Restricciones del código según las opciones pedidas
+	var limits []string
+	if m.config.InviteTTL > 0 {
+		limits = append(limits, fmt.Sprintf("expires in %s", m.config.InviteTTL))
+	}
+	if m.config.InviteUses > 0 {
+		limits = append(limits, fmt.Sprintf("valid for %d use(s)", m.config.InviteUses))
+	}
+	note := "Anyone with this code can join your private room."
+	if len(limits) > 0 {
+		note += " It " + strings.Join(limits, " and ") + "."
+	}
+
 	content := fmt.Sprintf(`
    %s
 
@@ -164,18 +219,21 @@ func (m Model) invitingView() string {
 
    %s
 
-   Anyone with this code can join your private room.
+   %s
+   They can join with: --room %s --join-code <code>
 
    %s`,
 		title,
-		m.config.Room,
+		m.currentRoom,
 		lipgloss.NewStyle().
 			Bold(true).
-			Foreground(lipgloss.Color("#00FF00")).
+			Foreground(colors.success).
 			Background(lipgloss.Color("#333333")).
 			Padding(0, 1).
 			Render(m.inviteCode),
-		helpStyle.Render("[Enter] Continue to chat • [Esc] Back to lobby"))
+		note,
+		m.currentRoom,
+		helpStyle.Render(fmt.Sprintf("[Enter] Continue to chat • [%s] Back to lobby", label(m.keys.Back))))
 
 	return content
 }
@@ -185,6 +243,12 @@ func (m Model) chatView() string {
 	// Header simplificado
 	titleText := fmt.Sprintf("ROOM: #%s", m.currentRoom)
 	statusText := fmt.Sprintf("User: %s", m.config.Username)
+	switch m.connectionStatus {
+	case client.StatusReconnecting:
+		statusText += " | Reconnecting..."
+	case client.StatusRestarting:
+		statusText += " | Server restarting..."
+	}
 
 	title := titleStyle.Render(titleText)
 	status := statusStyle.Render(statusText)
@@ -194,7 +258,7 @@ func (m Model) chatView() string {
 	if len(m.users) > 0 {
 		var userNames []string
 		for _, user := range m.users {
-			userNames = append(userNames, "🟢 "+user.UserName)
+			userNames = append(userNames, "🟢 "+rolePrefix(user.Role)+user.UserName)
 		}
 		userList = " | Online: " + strings.Join(userNames, ", ")
 	}
@@ -207,6 +271,7 @@ func (m Model) chatView() string {
 		var msgStyle lipgloss.Style
 		var prefix string
 
+		content := msg.Content
 		if msg.IsSystem {
 			msgStyle = systemMessageStyle
 			prefix = "* "
@@ -215,27 +280,72 @@ func (m Model) chatView() string {
 			prefix = fmt.Sprintf("<%s> ", userMessageStyle.Render(msg.Username))
 		}
 
+		// Mensajes editados o borrados
+		suffix := ""
+		if msg.Deleted {
+			msgStyle = systemMessageStyle
+			content = "message deleted"
+		} else if msg.Edited {
+			suffix = " " + helpStyle.Render("(edited)")
+		}
+
+		// Estado de entrega de los mensajes propios
+		switch msg.Delivery {
+		case DeliveryPending:
+			suffix += " " + helpStyle.Render("…")
+		case DeliverySent:
+			suffix += " " + helpStyle.Render("✓")
+		case DeliveryFailed:
+			suffix += " " + errorStyle.Render("✗ failed, /retry to resend")
+		}
+
 		timestamp := msg.Timestamp.Format("15:04")
-		line := fmt.Sprintf("[%s] %s%s",
+		line := fmt.Sprintf("[%s] %s%s%s",
 			helpStyle.Render(timestamp),
 			prefix,
-			msgStyle.Render(msg.Content))
+			msgStyle.Render(content),
+			suffix)
 		messageLines = append(messageLines, line)
 	}
 
-	// Área de mensajes (limitamos a las últimas líneas que caben)
-	maxLines := m.height - 8 // Más espacio para header expandido
-	if len(messageLines) > maxLines {
-		messageLines = messageLines[len(messageLines)-maxLines:]
+	// Área de mensajes: las líneas que caben, desplazadas por el scroll
+	maxLines := m.messageAreaHeight()
+	end := len(messageLines) - m.scrollOffset
+	if end < 0 {
+		end = 0
+	}
+	start := end - maxLines
+	if start < 0 {
+		start = 0
+	}
+	messageLines = messageLines[start:end]
+	if m.historyLoading {
+		messageLines = append([]string{helpStyle.Render("Loading older messages...")}, messageLines...)
 	}
 
 	messagesArea := strings.Join(messageLines, "\n")
 
+	// Quién está escribiendo en la sala
+	if typing := m.typingLine(); typing != "" {
+		messagesArea += "\n" + systemMessageStyle.Render(typing)
+	}
+
+	// Panel de mensajes directos, separado de la conversación de la sala
+	if pane := m.directPane(); pane != "" {
+		messagesArea += "\n" + pane
+	}
+
 	// Input de mensaje simplificado
 	inputArea := fmt.Sprintf("> %s", m.messageInput.View())
+	if notice := m.postNotice(); notice != "" {
+		// Mute, solo lectura o slow mode con la cuenta regresiva
+		inputArea += "  " + noticeStyle.Render(notice)
+	}
 
 	// Ayuda
-	help := helpStyle.Render("[Enter] Send • [Q] Back to lobby • [Esc] Exit")
+	help := helpStyle.Render(fmt.Sprintf("[%s] Send • [%s%s/%s/%s] Scroll • /help Commands • [%s] Back to lobby • [%s] Exit",
+		label(m.keys.Send), label(m.keys.ScrollUp), label(m.keys.ScrollDown), label(m.keys.PageUp), label(m.keys.PageDown),
+		label(m.keys.Quit), label(m.keys.Back)))
 
 	// Mostrar error si hay
 	errorArea := ""
@@ -251,29 +361,102 @@ func (m Model) chatView() string {
 		help)
 }
 
+// directPane muestra los últimos mensajes directos enviados y recibidos
+func (m Model) directPane() string {
+	if len(m.directMessages) == 0 {
+		return ""
+	}
+
+	dms := m.directMessages
+	if len(dms) > directPaneLines {
+		dms = dms[len(dms)-directPaneLines:]
+	}
+
+	lines := []string{titleStyle.Render("DIRECT MESSAGES")}
+	for _, dm := range dms {
+		lines = append(lines, fmt.Sprintf("[%s] %s → %s: %s",
+			helpStyle.Render(dm.Timestamp.Format("15:04")),
+			userMessageStyle.Render(dm.Username),
+			userMessageStyle.Render(dm.To),
+			dm.Content))
+	}
+	return directPaneStyle.Render(strings.Join(lines, "\n"))
+}
+
+// directPaneHeight retorna las líneas que ocupa el panel de DMs
+func (m Model) directPaneHeight() int {
+	if len(m.directMessages) == 0 {
+		return 0
+	}
+	n := len(m.directMessages)
+	if n > directPaneLines {
+		n = directPaneLines
+	}
+	return n + 3 // título y bordes
+}
+
+// messageAreaHeight retorna cuántas líneas de mensajes caben en el chat
+func (m Model) messageAreaHeight() int {
+	maxLines := m.height - 9 - m.directPaneHeight() // Más espacio para header expandido e indicador de escritura
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	return maxLines
+}
+
+// maxScrollOffset retorna el scroll que muestra el mensaje más viejo cargado
+func (m Model) maxScrollOffset() int {
+	maxOffset := len(m.messages) - m.messageAreaHeight()
+	if maxOffset < 0 {
+		return 0
+	}
+	return maxOffset
+}
+
 // creatingView muestra la pantalla de creación de sala
 func (m Model) creatingView() string {
 	title := titleStyle.Render("CREATE NEW ROOM")
 
+	// Mostrar el motivo si el servidor rechazó la sala
+	errorArea := ""
+	if m.errorMsg != "" {
+		errorArea = "\n   " + errorStyle.Render("⚠️ "+m.errorMsg) + "\n"
+	}
+
 	content := fmt.Sprintf(`
    %s
 
    Enter room name:
    %s
-
+%s
    %s`,
 		title,
 		m.messageInput.View(),
-		helpStyle.Render("[Enter] Create • [Esc] Cancel"))
+		errorArea,
+		helpStyle.Render(fmt.Sprintf("[Enter] Create • [%s] Cancel", label(m.keys.Back))))
 
 	return content
 }
 
+// rolePrefix marca al dueño de la sala con @ y a los moderadores con +
+func rolePrefix(role string) string {
+	switch role {
+	case protocol.RoleOwner:
+		return "@"
+	case protocol.RoleModerator:
+		return "+"
+	}
+	return ""
+}
+
 // errorView muestra errores
 func (m Model) errorView() string {
 	title := titleStyle.Render("ERROR")
 	error := errorStyle.Render(m.errorMsg)
-	help := helpStyle.Render("[Esc] Back • [Q] Quit")
+	help := helpStyle.Render(fmt.Sprintf("[%s] Quit", label(m.keys.Quit)))
+	if m.errorBack {
+		help = helpStyle.Render(fmt.Sprintf("[%s] Back to lobby • [%s] Quit", label(m.keys.Back), label(m.keys.Quit)))
+	}
 
 	return fmt.Sprintf("\n\n   %s\n\n   %s\n\n   %s\n\n", title, error, help)
 }
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Client es la configuración del cliente de terminal, normalmente en
+// ~/.config/bubblenet/config.toml. Los flags pisan lo que indica
+type Client struct {
+	User           string             `config:"user"`
+	Room           string             `config:"room"`            // sala a la que entrar al conectarse
+	DefaultProfile string             `config:"default_profile"` // perfil que se usa sin --profile
+	Profiles       map[string]Profile `config:"profiles"`
+	Theme          Theme              `config:"theme"`
+	Keys           Keys               `config:"keys"`
+}
+
+// Profile es un servidor guardado con sus datos de conexión. Los campos
+// vacíos usan los valores generales o los por defecto
+type Profile struct {
+	Host               string `config:"host"`
+	Port               int    `config:"port"`
+	TLS                bool   `config:"tls"`
+	CAFile             string `config:"ca_file"`
+	InsecureSkipVerify bool   `config:"insecure_skip_verify"`
+	Token              string `config:"token"`
+	PasswordFile       string `config:"password_file"`
+	User               string `config:"user"`
+	Room               string `config:"room"`
+}
+
+// Theme son los colores de la interfaz, como "#7D56F4" o un número de la
+// paleta ANSI (0-255); vacío usa el color por defecto
+type Theme struct {
+	Accent  string `config:"accent"`  // fondo de los títulos y bordes
+	Title   string `config:"title"`   // texto de los títulos
+	Muted   string `config:"muted"`   // ayuda y barra de estado
+	System  string `config:"system"`  // mensajes del sistema
+	Own     string `config:"own"`     // tu nombre en el chat
+	Error   string `config:"error"`   // errores
+	Success string `config:"success"` // conectado
+	Warning string `config:"warning"` // conectando, reconectando y avisos de mute o slow mode
+}
+
+// Keys son los atajos de teclado, con los nombres de tecla de bubbletea
+// ("enter", "ctrl+c", "pgup", "q"); una lista vacía usa los por defecto
+type Keys struct {
+	Quit       []string `config:"quit"`    // salir desde el lobby, volver al lobby desde el chat
+	Back       []string `config:"back"`    // volver a la pantalla anterior o salir
+	Join       []string `config:"join"`    // entrar a la sala seleccionada
+	Create     []string `config:"create"`  // crear una sala
+	Refresh    []string `config:"refresh"` // refrescar las salas o reconectar
+	Send       []string `config:"send"`    // enviar el mensaje
+	ScrollUp   []string `config:"scroll_up"`
+	ScrollDown []string `config:"scroll_down"`
+	PageUp     []string `config:"page_up"`
+	PageDown   []string `config:"page_down"`
+}
+
+// DefaultClientPath retorna ~/.config/bubblenet/config.toml (o el
+// equivalente del sistema operativo)
+func DefaultClientPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bubblenet", "config.toml"), nil
+}
+
+// LoadClient lee la configuración del cliente de path, o del archivo por
+// defecto si path es "". Que no exista el archivo por defecto no es un
+// error; retorna la ruta que se leyó, "" si no se leyó ninguna
+func LoadClient(path string) (Client, string, error) {
+	var c Client
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = DefaultClientPath(); err != nil {
+			return c, "", nil
+		}
+	}
+
+	if err := Load(path, &c); err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return c, "", nil
+		}
+		return c, "", err
+	}
+	if err := c.Validate(); err != nil {
+		return c, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return c, path, nil
+}
+
+// colorPattern acepta colores #RGB y #RRGGBB
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validColor indica si lipgloss entiende el color
+func validColor(color string) bool {
+	if colorPattern.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// Validate revisa colores, atajos y perfiles y retorna todos los problemas
+// juntos, uno por línea
+func (c *Client) Validate() error {
+	var errs []error
+
+	colors := []struct{ name, value string }{
+		{"accent", c.Theme.Accent}, {"title", c.Theme.Title}, {"muted", c.Theme.Muted},
+		{"system", c.Theme.System}, {"own", c.Theme.Own}, {"error", c.Theme.Error},
+		{"success", c.Theme.Success}, {"warning", c.Theme.Warning},
+	}
+	for _, color := range colors {
+		if color.value != "" && !validColor(color.value) {
+			errs = append(errs, fmt.Errorf("theme.%s: invalid color %q, use #RRGGBB or an ANSI color number", color.name, color.value))
+		}
+	}
+
+	keys := []struct {
+		name  string
+		value []string
+	}{
+		{"quit", c.Keys.Quit}, {"back", c.Keys.Back}, {"join", c.Keys.Join},
+		{"create", c.Keys.Create}, {"refresh", c.Keys.Refresh}, {"send", c.Keys.Send},
+		{"scroll_up", c.Keys.ScrollUp}, {"scroll_down", c.Keys.ScrollDown},
+		{"page_up", c.Keys.PageUp}, {"page_down", c.Keys.PageDown},
+	}
+	for _, key := range keys {
+		if slices.Contains(key.value, "") {
+			errs = append(errs, fmt.Errorf("keys.%s: key names must not be empty", key.name))
+		}
+	}
+
+	for _, name := range c.profileNames() {
+		if port := c.Profiles[name].Port; port < 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("profiles.%s.port: invalid port %d", name, port))
+		}
+	}
+	if c.DefaultProfile != "" {
+		if _, ok := c.Profiles[c.DefaultProfile]; !ok {
+			errs = append(errs, fmt.Errorf("default_profile: %w", c.unknownProfile(c.DefaultProfile)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Profile retorna el perfil name, o el perfil por defecto si name es "".
+// Sin perfil por defecto retorna un perfil vacío
+func (c *Client) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, c.unknownProfile(name)
+	}
+	return profile, nil
+}
+
+func (c *Client) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (c *Client) unknownProfile(name string) error {
+	if len(c.Profiles) == 0 {
+		return fmt.Errorf("unknown profile %q, the config file has no profiles", name)
+	}
+	return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(c.profileNames(), ", "))
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const clientTOML = `
+user = "alice"
+default_profile = "work"
+
+[profiles.work]
+host = "chat.example.com"
+port = 443
+tls = true
+token = "secret"
+
+[profiles.local]
+host = "localhost"
+
+[theme]
+accent = "#FF8800"
+muted = "245"
+
+[keys]
+quit = ["ctrl+q"]
+`
+
+func TestLoadClient(t *testing.T) {
+	path := writeFile(t, "config.toml", clientTOML)
+	c, read, err := LoadClient(path)
+	if err != nil {
+		t.Fatalf("LoadClient: %v", err)
+	}
+	if read != path {
+		t.Errorf("LoadClient read %q, want %q", read, path)
+	}
+
+	want := Client{
+		User:           "alice",
+		DefaultProfile: "work",
+		Profiles: map[string]Profile{
+			"work":  {Host: "chat.example.com", Port: 443, TLS: true, Token: "secret"},
+			"local": {Host: "localhost"},
+		},
+		Theme: Theme{Accent: "#FF8800", Muted: "245"},
+		Keys:  Keys{Quit: []string{"ctrl+q"}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("LoadClient =\n%+v\nwant\n%+v", c, want)
+	}
+}
+
+func TestLoadClientDefaultPath(t *testing.T) {
+	// Sin archivo por defecto se usan los valores de siempre
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	c, read, err := LoadClient("")
+	if err != nil || read != "" || !reflect.DeepEqual(c, Client{}) {
+		t.Errorf("LoadClient without a file = %+v, %q, %v; want an empty config", c, read, err)
+	}
+
+	// Pero un archivo indicado tiene que existir
+	if _, _, err := LoadClient(filepath.Join(dir, "missing.toml")); !os.IsNotExist(err) {
+		t.Errorf("LoadClient with a missing file = %v, want a not-exist error", err)
+	}
+}
+
+func TestClientValidate(t *testing.T) {
+	c := Client{
+		DefaultProfile: "home",
+		Profiles:       map[string]Profile{"work": {Port: 70000}},
+		Theme:          Theme{Accent: "purple", Own: "#12", Title: "256"},
+		Keys:           Keys{Send: []string{"enter", ""}},
+	}
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	for _, want := range []string{
+		`theme.accent: invalid color "purple"`,
+		`theme.title: invalid color "256"`,
+		`theme.own: invalid color "#12"`,
+		"keys.send: key names must not be empty",
+		"profiles.work.port: invalid port 70000",
+		`default_profile: unknown profile "home" (available: work)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error = %q, want it to contain %q", err, want)
+		}
+	}
+
+	valid := Client{Theme: Theme{Accent: "#7D56F4", Muted: "#abc", System: "0"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestClientProfile(t *testing.T) {
+	c := Client{DefaultProfile: "work", Profiles: map[string]Profile{"work": {Host: "w"}, "home": {Host: "h"}}}
+	if p, err := c.Profile(""); err != nil || p.Host != "w" {
+		t.Errorf(`Profile("") = %+v, %v; want the default profile`, p, err)
+	}
+	if p, err := c.Profile("home"); err != nil || p.Host != "h" {
+		t.Errorf(`Profile("home") = %+v, %v`, p, err)
+	}
+	if _, err := c.Profile("office"); err == nil || !strings.Contains(err.Error(), "available: home, work") {
+		t.Errorf(`Profile("office") error = %v, want the available profiles`, err)
+	}
+	if p, err := (&Client{}).Profile(""); err != nil || p != (Profile{}) {
+		t.Errorf("Profile without profiles = %+v, %v; want an empty profile", p, err)
+	}
+}
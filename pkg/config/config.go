@@ -0,0 +1,197 @@
+// Package config carga la configuración de bubblenet desde archivos TOML o
+// YAML y variables de entorno. Los parsers cubren el subconjunto de cada
+// formato que usan nuestros archivos de configuración (tablas, listas,
+// strings, números y booleanos) y el resultado se decodifica sobre structs
+// con tags `config:"nombre"`, empezando desde sus valores por defecto.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Load lee el archivo de path y lo decodifica sobre v, que tiene que ser un
+// puntero a struct. El formato sale de la extensión: .toml, .yaml o .yml.
+// Las claves que no existen en v son un error, así un typo no pasa
+// desapercibido
+func Load(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		values, err = ParseTOML(data)
+	case ".yaml", ".yml":
+		values, err = ParseYAML(data)
+	default:
+		return fmt.Errorf("%s: unknown config format %q, use .toml, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := Decode(values, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Decode copia los valores ya parseados sobre v, un puntero a struct. Los
+// campos que no aparecen en values conservan su valor
+func Decode(values map[string]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: decode target must be a pointer to a struct, got %T", v)
+	}
+	return decodeStruct("", values, rv.Elem())
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldName retorna el nombre de un campo en el archivo, "" si no se configura
+func fieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name := field.Tag.Get("config")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// joinPath arma la ruta de una clave para los mensajes de error
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func decodeStruct(path string, values map[string]any, rv reflect.Value) error {
+	fields := make(map[string]int)
+	for i := 0; i < rv.NumField(); i++ {
+		if name := fieldName(rv.Type().Field(i)); name != "" {
+			fields[name] = i
+		}
+	}
+	for key, value := range values {
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown key %q", joinPath(path, key))
+		}
+		if err := decodeValue(joinPath(path, key), value, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeValue(path string, value any, rv reflect.Value) error {
+	// Una clave sin valor en YAML deja el campo como estaba
+	if value == nil {
+		return nil
+	}
+
+	if rv.Type() == durationType {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a duration like \"30s\", got %v", path, value)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %v", path, value)
+		}
+		rv.SetString(s)
+
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected true or false, got %v", path, value)
+		}
+		rv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer, got %v", path, value)
+		}
+		if rv.OverflowInt(n) {
+			return fmt.Errorf("%s: %d is out of range", path, n)
+		}
+		rv.SetInt(n)
+
+	case reflect.Float32, reflect.Float64:
+		switch n := value.(type) {
+		case float64:
+			rv.SetFloat(n)
+		case int64:
+			rv.SetFloat(float64(n))
+		default:
+			return fmt.Errorf("%s: expected a number, got %v", path, value)
+		}
+
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a list, got %v", path, value)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(fmt.Sprintf("%s[%d]", path, i), item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+
+	case reflect.Map:
+		// Tablas con claves libres, como los perfiles del cliente
+		table, ok := value.(map[string]any)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected a table, got %v", path, value)
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for key, item := range table {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if existing := rv.MapIndex(reflect.ValueOf(key)); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := decodeValue(joinPath(path, key), item, elem); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+
+	case reflect.Struct:
+		table, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a table, got %v", path, value)
+		}
+		return decodeStruct(path, table, rv)
+
+	default:
+		return fmt.Errorf("%s: unsupported field type %s", path, rv.Type())
+	}
+	return nil
+}
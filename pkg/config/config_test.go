@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const serverTOML = `
+listen = ":9000"
+motd = "hola"
+allowed_origins = ["https://chat.example.com", "https://*.example.com"]
+resume_window = "1m"
+
+[limits]
+rate_messages = 2.5
+send_buffer = 64
+
+[storage]
+backend = "file"
+path = "data/history"
+
+[[rooms]]
+name = "news"
+
+[[rooms]]
+name = "help"
+max_users = 10
+`
+
+const serverYAML = `
+listen: ":9000"
+motd: hola
+allowed_origins:
+  - https://chat.example.com
+  - "https://*.example.com"
+resume_window: 1m
+limits:
+  rate_messages: 2.5
+  send_buffer: 64
+storage:
+  backend: file
+  path: data/history
+rooms:
+  - name: news
+  - name: help
+    max_users: 10
+`
+
+// writeFile escribe un archivo de configuración en un directorio temporal
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadServer(t *testing.T) {
+	want := DefaultServer()
+	want.Listen = ":9000"
+	want.MOTD = "hola"
+	want.AllowedOrigins = []string{"https://chat.example.com", "https://*.example.com"}
+	want.ResumeWindow = time.Minute
+	want.Limits.RateMessages = 2.5
+	want.Limits.SendBuffer = 64
+	want.Storage.Backend = "file"
+	want.Rooms = []Room{
+		{Name: "news"},
+		{Name: "help", MaxUsers: 10},
+	}
+
+	for name, content := range map[string]string{"server.toml": serverTOML, "server.yaml": serverYAML, "server.yml": serverYAML} {
+		t.Run(name, func(t *testing.T) {
+			got := DefaultServer()
+			if err := Load(writeFile(t, name, content), &got); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load =\n%+v\nwant\n%+v", got, want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string // parte del mensaje de error
+	}{
+		{"unknown extension", "server.json", "{}", `unknown config format ".json"`},
+		{"syntax error", "server.toml", "listen = \n", "line 1: missing value"},
+		{"unknown key", "server.toml", "[limits]\nrate_mesages = 1\n", `unknown key "limits.rate_mesages"`},
+		{"wrong type", "server.yaml", "debug: yes\n", "debug: expected true or false"},
+		{"integer for a string", "server.toml", "listen = 8080\n", "listen: expected a string"},
+		{"float for an integer", "server.toml", "[limits]\nsend_buffer = 1.5\n", "limits.send_buffer: expected an integer"},
+		{"bad duration", "server.yaml", "resume_window: soon\n", "resume_window: time: invalid duration"},
+		{"number for a duration", "server.toml", "resume_window = 30\n", `resume_window: expected a duration like "30s"`},
+		{"table for a list", "server.toml", "[rooms]\nname = \"a\"\n", "rooms: expected a list"},
+		{"unknown key in a list of tables", "server.yaml", "rooms:\n  - name: a\n    colour: red\n", `unknown key "rooms[0].colour"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultServer()
+			path := writeFile(t, tt.file, tt.content)
+			err := Load(path, &cfg)
+			if err == nil {
+				t.Fatalf("Load succeeded, want an error with %q", tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), path+": ") {
+				t.Errorf("error %q doesn't name the file", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg := DefaultServer()
+	if err := Load(filepath.Join(t.TempDir(), "missing.toml"), &cfg); !os.IsNotExist(err) {
+		t.Errorf("Load = %v, want a not-exist error", err)
+	}
+}
+
+func TestDecodeKeepsDefaults(t *testing.T) {
+	cfg := DefaultServer()
+	if err := Decode(map[string]any{"limits": map[string]any{"send_buffer": int64(8)}}, &cfg); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := DefaultServer()
+	want.Limits.SendBuffer = 8
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Decode =\n%+v\nwant\n%+v", cfg, want)
+	}
+}
+
+func TestDecodeTarget(t *testing.T) {
+	var cfg Server
+	if err := Decode(map[string]any{}, cfg); err == nil {
+		t.Error("Decode into a struct value succeeded, want an error")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("TEST_LISTEN", ":7000")
+	t.Setenv("TEST_DEBUG", "true")
+	t.Setenv("TEST_RESUME_WINDOW", "45s")
+	t.Setenv("TEST_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("TEST_LIMITS_MAX_MESSAGE_SIZE", "1024")
+	t.Setenv("TEST_LIMITS_RATE_MESSAGES", "0.5")
+
+	cfg := DefaultServer()
+	if err := ApplyEnv("TEST", &cfg); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	want := DefaultServer()
+	want.Listen = ":7000"
+	want.Debug = true
+	want.ResumeWindow = 45 * time.Second
+	want.AllowedOrigins = []string{"https://a.example.com", "https://b.example.com"}
+	want.Limits.MaxMessageSize = 1024
+	want.Limits.RateMessages = 0.5
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ApplyEnv =\n%+v\nwant\n%+v", cfg, want)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	tests := []struct {
+		env, value string
+	}{
+		{"TEST_DEBUG", "maybe"},
+		{"TEST_RESUME_WINDOW", "30"},
+		{"TEST_LIMITS_SEND_BUFFER", "lots"},
+		{"TEST_LIMITS_RATE_BYTES", "fast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			cfg := DefaultServer()
+			err := ApplyEnv("TEST", &cfg)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.env+": ") {
+				t.Errorf("ApplyEnv with %s=%q = %v, want an error naming the variable", tt.env, tt.value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyEnv pisa los campos de v con las variables de entorno que empiezan
+// con prefix. El nombre de cada variable sale de la ruta del campo en
+// mayúsculas: con prefix "BUBBLENET", tls.cert es BUBBLENET_TLS_CERT y
+// limits.max_message_size es BUBBLENET_LIMITS_MAX_MESSAGE_SIZE. Las listas
+// de strings se separan con comas; las listas de tablas no se pueden
+// configurar desde el entorno
+func ApplyEnv(prefix string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: env target must be a pointer to a struct, got %T", v)
+	}
+	return applyEnvStruct(prefix, rv.Elem())
+}
+
+// EnvName retorna la variable de entorno que corresponde a una ruta como
+// "limits.max_message_size"
+func EnvName(prefix, path string) string {
+	return strings.ToUpper(prefix + "_" + strings.ReplaceAll(path, ".", "_"))
+}
+
+func applyEnvStruct(prefix string, rv reflect.Value) error {
+	for i := 0; i < rv.NumField(); i++ {
+		name := fieldName(rv.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		field := rv.Field(i)
+		env := EnvName(prefix, name)
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			if err := applyEnvStruct(env, field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setFromString(field, raw); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+	}
+	return nil
+}
+
+// setFromString asigna el valor de una variable de entorno a un campo
+func setFromString(rv reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if rv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(raw)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", raw)
+		}
+		rv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		rv.SetInt(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", raw)
+		}
+		rv.SetFloat(n)
+
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of %s can't be set from the environment", rv.Type().Elem())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		rv.Set(reflect.ValueOf(items).Convert(rv.Type()))
+
+	default:
+		return fmt.Errorf("%s can't be set from the environment", rv.Type())
+	}
+	return nil
+}
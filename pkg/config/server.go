@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// EnvPrefix es el prefijo de las variables de entorno que pisan el archivo
+const EnvPrefix = "BUBBLENET"
+
+// Server es la configuración del servidor. Los valores por defecto salen de
+// DefaultServer; el archivo, las variables BUBBLENET_* y los flags, en ese
+// orden, pisan los que indican
+type Server struct {
+	Listen          string        `config:"listen"`
+	Debug           bool          `config:"debug"`
+	MOTD            string        `config:"motd"`
+	UsersFile       string        `config:"users_file"`
+	AllowedOrigins  []string      `config:"allowed_origins"`
+	ResumeWindow    time.Duration `config:"resume_window"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+
+	Timeouts Timeouts `config:"timeouts"`
+	Limits   Limits   `config:"limits"`
+	Storage  Storage  `config:"storage"`
+	TLS      TLS      `config:"tls"`
+
+	// Salas que se crean al arrancar, además de la sala por defecto
+	Rooms []Room `config:"rooms"`
+}
+
+// Timeouts de cada conexión WebSocket
+type Timeouts struct {
+	Write time.Duration `config:"write"` // para escribir un mensaje
+	Pong  time.Duration `config:"pong"`  // sin noticias del cliente antes de cortarlo
+	Hello time.Duration `config:"hello"` // para recibir el hello al conectarse
+}
+
+// Limits son los tamaños de mensajes y buffers, los límites de velocidad y
+// qué hacer con los clientes lentos
+type Limits struct {
+	MaxMessageSize int64  `config:"max_message_size"`
+	SendBuffer     int    `config:"send_buffer"` // mensajes pendientes por cliente
+	RoomBuffer     int    `config:"room_buffer"` // mensajes pendientes por sala
+	SlowConsumer   string `config:"slow_consumer"`
+
+	RateMessages     float64       `config:"rate_messages"`
+	RateBytes        float64       `config:"rate_bytes"`
+	UserRateMessages float64       `config:"user_rate_messages"`
+	UserRateBytes    float64       `config:"user_rate_bytes"`
+	MuteDuration     time.Duration `config:"mute_duration"`
+}
+
+// Storage elige dónde se guarda el historial de mensajes
+type Storage struct {
+	Backend       string `config:"backend"`        // memory o file
+	Path          string `config:"path"`           // directorio del backend file
+	HistorySize   int    `config:"history_size"`   // mensajes por sala del backend memory
+	HistoryReplay int    `config:"history_replay"` // mensajes que se envían al entrar a una sala
+}
+
+// TLS configura wss:// y https://
+type TLS struct {
+	Cert       string `config:"cert"`
+	Key        string `config:"key"`
+	SelfSigned bool   `config:"self_signed"` // generar un certificado de desarrollo si no existe
+}
+
+// Room es una sala que se crea al arrancar
+type Room struct {
+	Name     string        `config:"name"`
+	MaxUsers int           `config:"max_users"`
+	Owner    string        `config:"owner"`     // puede moderar la sala y nombrar moderadores
+	SlowMode time.Duration `config:"slow_mode"` // tiempo mínimo entre mensajes de un usuario
+	ReadOnly bool          `config:"read_only"` // solo el dueño y los moderadores escriben
+}
+
+// DefaultServer retorna la configuración que usa el servidor sin archivo
+func DefaultServer() Server {
+	return Server{
+		Listen:          ":8080",
+		ResumeWindow:    30 * time.Second,
+		ShutdownTimeout: 10 * time.Second,
+		Timeouts: Timeouts{
+			Write: 10 * time.Second,
+			Pong:  60 * time.Second,
+			Hello: 10 * time.Second,
+		},
+		Limits: Limits{
+			MaxMessageSize:   512,
+			SendBuffer:       256,
+			RoomBuffer:       256,
+			SlowConsumer:     "disconnect",
+			RateMessages:     5,
+			RateBytes:        4096,
+			UserRateMessages: 10,
+			UserRateBytes:    8192,
+			MuteDuration:     30 * time.Second,
+		},
+		Storage: Storage{
+			Backend:       "memory",
+			Path:          "data/history",
+			HistorySize:   500,
+			HistoryReplay: 50,
+		},
+	}
+}
+
+// minMessageSize es el menor límite de mensaje con el que todavía entra un
+// hello con credenciales
+const minMessageSize = 256
+
+// Validate revisa los valores que no dependen del servidor y retorna todos
+// los problemas juntos, uno por línea
+func (s *Server) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(s.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	}
+	check(s.ResumeWindow >= 0, "resume_window: must not be negative")
+	check(s.ShutdownTimeout > 0, "shutdown_timeout: must be positive")
+
+	check(s.Timeouts.Write > 0, "timeouts.write: must be positive")
+	check(s.Timeouts.Pong >= time.Second, "timeouts.pong: must be at least 1s")
+	check(s.Timeouts.Hello > 0, "timeouts.hello: must be positive")
+
+	check(s.Limits.MaxMessageSize >= minMessageSize, "limits.max_message_size: must be at least %d bytes", minMessageSize)
+	check(s.Limits.SendBuffer > 0, "limits.send_buffer: must be positive")
+	check(s.Limits.RoomBuffer > 0, "limits.room_buffer: must be positive")
+	check(s.Limits.RateMessages >= 0, "limits.rate_messages: must not be negative")
+	check(s.Limits.RateBytes >= 0, "limits.rate_bytes: must not be negative")
+	check(s.Limits.UserRateMessages >= 0, "limits.user_rate_messages: must not be negative")
+	check(s.Limits.UserRateBytes >= 0, "limits.user_rate_bytes: must not be negative")
+	check(s.Limits.MuteDuration > 0, "limits.mute_duration: must be positive")
+
+	switch s.Storage.Backend {
+	case "memory":
+		check(s.Storage.HistorySize > 0, "storage.history_size: must be positive")
+	case "file":
+		check(s.Storage.Path != "", "storage.path: required by the file backend")
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend: unknown backend %q, use memory or file", s.Storage.Backend))
+	}
+	check(s.Storage.HistoryReplay >= 0, "storage.history_replay: must not be negative")
+
+	if !s.TLS.SelfSigned {
+		check((s.TLS.Cert == "") == (s.TLS.Key == ""), "tls: cert and key must be set together")
+	}
+
+	seen := make(map[string]bool)
+	for i, room := range s.Rooms {
+		check(room.Name != "", "rooms[%d].name: must not be empty", i)
+		check(!seen[room.Name], "rooms[%d].name: room %q is listed twice", i, room.Name)
+		check(room.MaxUsers >= 0, "rooms[%d].max_users: must not be negative", i)
+		seen[room.Name] = true
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,491 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParseTOML parsea un documento TOML. Soporta tablas, arrays de tablas,
+// claves con puntos, strings (básicos, literales y multilínea), enteros,
+// floats, booleanos, arrays e inline tables; las fechas no se usan en la
+// configuración y son un error. Los enteros quedan como int64 y los floats
+// como float64
+func ParseTOML(data []byte) (map[string]any, error) {
+	p := &tomlParser{src: string(data), line: 1, defined: make(map[uintptr]bool)}
+	root := make(map[string]any)
+	current := root
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+	}
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+
+	// tablas que ya tuvieron su propio [encabezado], una tabla no se puede
+	// definir dos veces
+	defined map[uintptr]bool
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) advance() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipSpace saltea espacios y tabs dentro de una línea
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment saltea un comentario hasta el fin de línea
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank saltea espacios, comentarios y líneas vacías
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		p.skipSpace()
+		p.skipComment()
+		if p.peek() == '\r' || p.peek() == '\n' {
+			p.advance()
+			continue
+		}
+		return
+	}
+}
+
+// endOfLine exige que no quede nada más en la línea salvo un comentario
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return fmt.Errorf("unexpected %q after value", p.peek())
+	}
+	p.advance()
+	return nil
+}
+
+// parseHeader procesa [tabla] o [[array.de.tablas]] y retorna la tabla
+// sobre la que van las claves siguientes
+func (p *tomlParser) parseHeader(root map[string]any) (map[string]any, error) {
+	p.advance()
+	array := p.peek() == '['
+	if array {
+		p.advance()
+	}
+	p.skipSpace()
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %q to close table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := descend(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	if array {
+		table := make(map[string]any)
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []any{table}
+		case []any:
+			parent[last] = append(existing, table)
+		default:
+			return nil, fmt.Errorf("key %q is not an array of tables", last)
+		}
+		p.defined[tableID(table)] = true
+		return table, nil
+	}
+
+	switch existing := parent[last].(type) {
+	case nil:
+		table := make(map[string]any)
+		parent[last] = table
+		p.defined[tableID(table)] = true
+		return table, nil
+	case map[string]any:
+		// Ya existe si la creó un [a.b] anterior, pero solo puede tener un
+		// encabezado propio
+		if p.defined[tableID(existing)] {
+			return nil, fmt.Errorf("table [%s] is defined twice", strings.Join(path, "."))
+		}
+		p.defined[tableID(existing)] = true
+		return existing, nil
+	default:
+		return nil, fmt.Errorf("key %q is already defined as a value", last)
+	}
+}
+
+// tableID identifica una tabla, así dos [[array]] con las mismas claves
+// siguen siendo tablas distintas
+func tableID(table map[string]any) uintptr {
+	return reflect.ValueOf(table).Pointer()
+}
+
+// descend recorre (y crea si hace falta) las tablas de path. En un array de
+// tablas sigue por la última, que es la que se está definiendo
+func descend(table map[string]any, path []string) (map[string]any, error) {
+	for _, key := range path {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]any)
+			table[key] = created
+			table = created
+		case map[string]any:
+			table = next
+		case []any:
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("key %q is not a table", key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("key %q is already defined as a value", key)
+		}
+	}
+	return table, nil
+}
+
+// parseKeyValue procesa una línea clave = valor sobre table
+func (p *tomlParser) parseKeyValue(table map[string]any) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return errors.New("expected '=' after key")
+	}
+	p.advance()
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := descend(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("key %q is defined twice", last)
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey lee una clave simple o con puntos (a.b."c")
+func (p *tomlParser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		var part string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, fmt.Errorf("expected a key, got %q", p.peek())
+			}
+			part = p.src[start:p.pos]
+		}
+		path = append(path, part)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return path, nil
+		}
+		p.advance()
+		p.skipSpace()
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	switch p.peek() {
+	case '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return p.parseMultilineString(`"""`)
+		}
+		return p.parseBasicString()
+	case '\'':
+		if strings.HasPrefix(p.src[p.pos:], `'''`) {
+			return p.parseMultilineString(`'''`)
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	case 0, '\n', '\r', '#':
+		return nil, errors.New("missing value")
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	return parseTOMLScalar(p.src[start:p.pos])
+}
+
+// parseTOMLScalar interpreta booleanos y números
+func parseTOMLScalar(token string) (any, error) {
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return strconv.ParseFloat(strings.TrimPrefix(token, "+"), 64)
+	}
+
+	number := strings.ReplaceAll(token, "_", "")
+	if leadingZero(number) {
+		return nil, fmt.Errorf("invalid number %q, leading zeros are not allowed", token)
+	}
+	for prefix, base := range tomlIntBases {
+		if digits, ok := strings.CutPrefix(number, prefix); ok {
+			// Con prefijo no se acepta signo
+			if n, err := strconv.ParseInt(digits, base, 64); err == nil && !strings.ContainsAny(digits, "+-") {
+				return n, nil
+			}
+			return nil, fmt.Errorf("invalid value %q (strings must be quoted)", token)
+		}
+	}
+	if strings.ContainsAny(number, ".eE") {
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			return f, nil
+		}
+	} else if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid value %q (strings must be quoted)", token)
+}
+
+// tomlIntBases son los prefijos de los enteros que no son decimales
+var tomlIntBases = map[string]int{"0x": 16, "0o": 8, "0b": 2}
+
+// leadingZero indica si un número decimal empieza con un cero de más, como
+// "010" o "-01.5"; TOML no los acepta (no son octales)
+func leadingZero(number string) bool {
+	digits := strings.TrimLeft(number, "+-")
+	return len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9'
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.advance()
+	start := p.pos
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", errors.New("unterminated string")
+		}
+		c := p.advance()
+		if c == '\\' && !p.eof() {
+			p.advance()
+			continue
+		}
+		if c == '"' {
+			break
+		}
+	}
+	return unescape(p.src[start : p.pos-1])
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.advance()
+	start := p.pos
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", errors.New("unterminated string")
+		}
+		if p.advance() == '\'' {
+			return p.src[start : p.pos-1], nil
+		}
+	}
+}
+
+// parseMultilineString lee un string multilínea hasta delim, sin el salto
+// de línea que sigue a la apertura
+func (p *tomlParser) parseMultilineString(delim string) (string, error) {
+	p.pos += len(delim)
+	end := strings.Index(p.src[p.pos:], delim)
+	if end < 0 {
+		return "", errors.New("unterminated multi-line string")
+	}
+	raw := p.src[p.pos : p.pos+end]
+	for i := 0; i < end+len(delim); i++ {
+		p.advance()
+	}
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "\r"), "\n")
+	if delim == `'''` {
+		return raw, nil
+	}
+	return unescape(raw)
+}
+
+// unescape resuelve las secuencias de escape de un string básico. Una barra
+// al final de la línea corta el salto y los espacios que le siguen
+func unescape(raw string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			b.WriteByte(raw[i])
+			continue
+		}
+		if i+1 == len(raw) {
+			return "", errors.New("invalid escape at end of string")
+		}
+
+		rest := raw[i+1:]
+		if trimmed := strings.TrimLeft(rest, " \t\r"); strings.HasPrefix(trimmed, "\n") {
+			i = len(raw) - len(strings.TrimLeft(trimmed, " \t\r\n")) - 1
+			continue
+		}
+
+		size := 2
+		switch rest[0] {
+		case 'u':
+			size = 6
+		case 'U':
+			size = 10
+		}
+		if i+size > len(raw) {
+			return "", fmt.Errorf("invalid escape %q", raw[i:])
+		}
+		r, _, tail, err := strconv.UnquoteChar(raw[i:i+size], '"')
+		if err != nil || tail != "" {
+			return "", fmt.Errorf("invalid escape %q", raw[i:i+size])
+		}
+		b.WriteRune(r)
+		i += size - 1
+	}
+	return b.String(), nil
+}
+
+func (p *tomlParser) parseArray() ([]any, error) {
+	p.advance()
+	items := []any{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.advance()
+			return items, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case ']':
+		default:
+			return nil, errors.New("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]any, error) {
+	p.advance()
+	table := make(map[string]any)
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.advance()
+		return table, nil
+	}
+	for {
+		p.skipSpace()
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case '}':
+			p.advance()
+			return table, nil
+		default:
+			return nil, errors.New("expected ',' or '}' in inline table")
+		}
+	}
+}
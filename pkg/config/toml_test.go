@@ -0,0 +1,196 @@
+package config
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[string]any
+	}{
+		{
+			name: "empty",
+			src:  "# solo un comentario\n\n",
+			want: map[string]any{},
+		},
+		{
+			name: "integers",
+			src:  "a = 10\nb = -3\nc = +7\nd = 1_000\ne = 0\n",
+			want: map[string]any{"a": int64(10), "b": int64(-3), "c": int64(7), "d": int64(1000), "e": int64(0)},
+		},
+		{
+			name: "prefixed integers",
+			src:  "hex = 0xff\noct = 0o10\nbin = 0b101\n",
+			want: map[string]any{"hex": int64(255), "oct": int64(8), "bin": int64(5)},
+		},
+		{
+			name: "floats",
+			src:  "a = 1.5\nb = -0.25\nc = 5e3\nd = 0.1e-2\n",
+			want: map[string]any{"a": 1.5, "b": -0.25, "c": 5000.0, "d": 0.001},
+		},
+		{
+			name: "booleans",
+			src:  "on = true\noff = false\n",
+			want: map[string]any{"on": true, "off": false},
+		},
+		{
+			name: "strings",
+			src:  `basic = "tab\there \"quoted\" \u00e9"` + "\n" + `literal = 'C:\path\n'` + "\n",
+			want: map[string]any{"basic": "tab\there \"quoted\" é", "literal": `C:\path\n`},
+		},
+		{
+			name: "multi-line strings",
+			src:  "basic = \"\"\"\nline one\nline two\"\"\"\nfolded = \"\"\"\none \\\n   two\"\"\"\nliteral = '''\nraw \\n'''\n",
+			want: map[string]any{"basic": "line one\nline two", "folded": "one two", "literal": `raw \n`},
+		},
+		{
+			name: "comments after values",
+			src:  "port = 8080 # el puerto\nname = \"a # b\" # no es comentario adentro\n",
+			want: map[string]any{"port": int64(8080), "name": "a # b"},
+		},
+		{
+			name: "arrays",
+			src:  "origins = [\"a\", \"b\",]\nnested = [[1, 2], []]\nmulti = [\n  1, # uno\n  2,\n]\n",
+			want: map[string]any{
+				"origins": []any{"a", "b"},
+				"nested":  []any{[]any{int64(1), int64(2)}, []any{}},
+				"multi":   []any{int64(1), int64(2)},
+			},
+		},
+		{
+			name: "inline tables",
+			src:  "tls = { cert = \"c.pem\", key = \"k.pem\" }\nempty = {}\n",
+			want: map[string]any{
+				"tls":   map[string]any{"cert": "c.pem", "key": "k.pem"},
+				"empty": map[string]any{},
+			},
+		},
+		{
+			name: "dotted keys",
+			src:  "limits.rate = 5\n\"quoted key\".x = 1\n",
+			want: map[string]any{
+				"limits":     map[string]any{"rate": int64(5)},
+				"quoted key": map[string]any{"x": int64(1)},
+			},
+		},
+		{
+			name: "tables",
+			src:  "listen = \":8080\"\n\n[limits]\nrate = 5\n\n[storage]\npath = \"data\"\n",
+			want: map[string]any{
+				"listen":  ":8080",
+				"limits":  map[string]any{"rate": int64(5)},
+				"storage": map[string]any{"path": "data"},
+			},
+		},
+		{
+			name: "sub-table before its parent",
+			src:  "[a.b]\nx = 1\n[a]\ny = 2\n",
+			want: map[string]any{"a": map[string]any{"b": map[string]any{"x": int64(1)}, "y": int64(2)}},
+		},
+		{
+			name: "arrays of tables",
+			src:  "[[rooms]]\nname = \"a\"\n\n[[rooms]]\nname = \"b\"\nmax_users = 3\n[rooms.limits]\nrate = 1\n",
+			want: map[string]any{"rooms": []any{
+				map[string]any{"name": "a"},
+				map[string]any{"name": "b", "max_users": int64(3), "limits": map[string]any{"rate": int64(1)}},
+			}},
+		},
+		{
+			name: "same sub-table in each array element",
+			src:  "[[rooms]]\n[rooms.limits]\nrate = 1\n[[rooms]]\n[rooms.limits]\nrate = 2\n",
+			want: map[string]any{"rooms": []any{
+				map[string]any{"limits": map[string]any{"rate": int64(1)}},
+				map[string]any{"limits": map[string]any{"rate": int64(2)}},
+			}},
+		},
+		{
+			name: "windows line endings",
+			src:  "a = 1\r\n[t]\r\nb = \"x\"\r\n",
+			want: map[string]any{"a": int64(1), "t": map[string]any{"b": "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTOML([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("ParseTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTOML =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLSpecialFloats(t *testing.T) {
+	got, err := ParseTOML([]byte("a = inf\nb = -inf\nc = nan\n"))
+	if err != nil {
+		t.Fatalf("ParseTOML: %v", err)
+	}
+	if a := got["a"].(float64); !math.IsInf(a, 1) {
+		t.Errorf("a = %v, want +Inf", a)
+	}
+	if b := got["b"].(float64); !math.IsInf(b, -1) {
+		t.Errorf("b = %v, want -Inf", b)
+	}
+	if c := got["c"].(float64); !math.IsNaN(c) {
+		t.Errorf("c = %v, want NaN", c)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // parte del mensaje de error
+	}{
+		{"leading zero", "a = 010\n", "leading zeros"},
+		{"leading zero in float", "a = 01.5\n", "leading zeros"},
+		{"negative leading zero", "a = -007\n", "leading zeros"},
+		{"sign on prefixed integer", "a = 0x-1\n", "invalid value"},
+		{"bad hex digit", "a = 0xfg\n", "invalid value"},
+		{"table defined twice", "[a]\nx = 1\n[a]\ny = 2\n", "table [a] is defined twice"},
+		{"sub-table defined twice", "[a.b]\n[a]\n[a.b]\n", "table [a.b] is defined twice"},
+		{"key defined twice", "a = 1\na = 2\n", `key "a" is defined twice`},
+		{"key defined twice in table", "[t]\na = 1\n[u]\n[t.a]\n", `key "a" is already defined as a value`},
+		{"table over a value", "a = 1\n[a]\n", `key "a" is already defined as a value`},
+		{"array of tables over a table", "[rooms]\n[[rooms]]\n", "not an array of tables"},
+		{"table over an array of tables", "[[rooms]]\n[rooms]\n", "already defined as a value"},
+		{"unquoted string", "a = hello\n", "strings must be quoted"},
+		{"unterminated string", "a = \"hello\n", "unterminated string"},
+		{"unterminated multi-line string", "a = \"\"\"hello\n", "unterminated multi-line string"},
+		{"invalid escape", `a = "\q"` + "\n", "invalid escape"},
+		{"missing value", "a =\n", "missing value"},
+		{"missing equals", "a 1\n", "expected '='"},
+		{"two values on a line", "a = 1 2\n", "after value"},
+		{"unclosed header", "[a\n", "to close table header"},
+		{"unclosed array", "a = [1, 2\n", "expected ',' or ']'"},
+		{"unclosed inline table", "a = { b = 1\n", "expected ',' or '}'"},
+		{"dates are not supported", "a = 2024-01-01\n", "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTOML([]byte(tt.src))
+			if err == nil {
+				t.Fatalf("ParseTOML(%q) succeeded, want an error with %q", tt.src, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseTOML(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrorLine(t *testing.T) {
+	_, err := ParseTOML([]byte("a = 1\n\n[t]\nb = oops\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 4:") {
+		t.Errorf("error = %v, want it on line 4", err)
+	}
+}
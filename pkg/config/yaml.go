@@ -0,0 +1,379 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseYAML parsea un documento YAML en bloque: mapas anidados por
+// indentación, listas con "- ", listas de mapas, listas y mapas en línea
+// ([a, b] y {a: 1}), strings con o sin comillas, números, booleanos y null.
+// No soporta anchors, tags ni bloques de texto con | o >. Los enteros
+// quedan como int64 y los floats como float64
+func ParseYAML(data []byte) (map[string]any, error) {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	if lines[0].indent != 0 || isSeqItem(lines[0].text) {
+		return nil, fmt.Errorf("line %d: the document must be a mapping", lines[0].num)
+	}
+
+	p := &yamlParser{lines: lines}
+	root, err := p.parseMap(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected %q", lines[p.pos].num, lines[p.pos].text)
+	}
+	return root, nil
+}
+
+// yamlLine es una línea con contenido, sin comentarios ni indentación
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlLines separa el documento en líneas con contenido
+func yamlLines(src string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(src, "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if trimmed[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines, nil
+}
+
+// stripYAMLComment corta un comentario que no esté dentro de comillas
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseBlock parsea el mapa o la lista que empieza en la línea actual
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+// parseNested parsea el valor de una clave o ítem vacío, que sigue en las
+// líneas más indentadas (o en una lista a la misma altura, si allowSeq)
+func (p *yamlParser) parseNested(indent int, allowSeq bool) (any, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	switch {
+	case next.indent > indent:
+		return p.parseBlock(next.indent)
+	case allowSeq && next.indent == indent && isSeqItem(next.text):
+		return p.parseSeq(indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || isSeqItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", line.num, line.text)
+		}
+		if _, exists := m[key]; exists {
+			return nil, fmt.Errorf("line %d: key %q is defined twice", line.num, key)
+		}
+		p.pos++
+
+		var value any
+		var err error
+		if rest == "" {
+			value, err = p.parseNested(indent, true)
+		} else {
+			value, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, wrapYAMLError(line.num, err)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) ([]any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || !isSeqItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		var item any
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.parseNested(indent, false)
+		case isMapItem(rest):
+			// "- clave: valor" abre un mapa alineado con la clave
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err = p.parseMap(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			item, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, wrapYAMLError(line.num, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// wrapYAMLError agrega el número de línea a los errores que no lo tienen
+func wrapYAMLError(num int, err error) error {
+	if strings.HasPrefix(err.Error(), "line ") {
+		return err
+	}
+	return fmt.Errorf("line %d: %w", num, err)
+}
+
+// isMapItem indica si el contenido de un ítem de lista es "clave: valor"
+func isMapItem(text string) bool {
+	if strings.ContainsRune("[{", rune(text[0])) {
+		return false
+	}
+	_, _, ok := splitYAMLKey(text)
+	return ok
+}
+
+// splitYAMLKey separa "clave: valor" en sus partes; la clave puede ir entre
+// comillas
+func splitYAMLKey(text string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if key == "" {
+				return "", "", false
+			}
+			if key[0] == '"' || key[0] == '\'' {
+				unquoted, err := parseYAMLScalar(key)
+				s, ok := unquoted.(string)
+				if err != nil || !ok {
+					return "", "", false
+				}
+				key = s
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func parseYAMLScalar(s string) (any, error) {
+	switch s[0] {
+	case '"':
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return unquoted, nil
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case '[':
+		return parseYAMLFlowSeq(s)
+	case '{':
+		return parseYAMLFlowMap(s)
+	case '|', '>':
+		return nil, errors.New("block scalars are not supported, use a quoted string")
+	case '&', '*', '!':
+		return nil, errors.New("anchors, aliases and tags are not supported")
+	}
+
+	switch s {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	}
+
+	// Números del esquema core de YAML 1.2: "010" es 10 y no un octal, que
+	// se escribe 0o10. Lo demás (como "1_000" o "Inf") queda como string
+	switch {
+	case yamlDecimal.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	case strings.HasPrefix(s, "0o") && yamlOctal.MatchString(s):
+		if n, err := strconv.ParseInt(s[2:], 8, 64); err == nil {
+			return n, nil
+		}
+	case strings.HasPrefix(s, "0x") && yamlHex.MatchString(s):
+		if n, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+			return n, nil
+		}
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return s, nil
+}
+
+// Formas de los números en el esquema core de YAML 1.2
+var (
+	yamlDecimal = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctal   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex     = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat   = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// splitFlow separa el contenido de [..] o {..} en sus elementos
+func splitFlow(s string, close byte) ([]string, error) {
+	if s[len(s)-1] != close {
+		return nil, fmt.Errorf("expected %q at the end of %s", close, s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return nil, nil
+	}
+
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(inner[start:i]))
+			start = i + 1
+		}
+	}
+	parts = append(parts, strings.TrimSpace(inner[start:]))
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty element in %s", s)
+		}
+	}
+	return parts, nil
+}
+
+func parseYAMLFlowSeq(s string) ([]any, error) {
+	parts, err := splitFlow(s, ']')
+	if err != nil {
+		return nil, err
+	}
+	items := []any{}
+	for _, part := range parts {
+		item, err := parseYAMLScalar(part)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func parseYAMLFlowMap(s string) (map[string]any, error) {
+	parts, err := splitFlow(s, '}')
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any)
+	for _, part := range parts {
+		key, rest, ok := splitYAMLKey(part)
+		if !ok {
+			return nil, fmt.Errorf("expected \"key: value\" in %s", s)
+		}
+		var value any
+		if rest != "" {
+			if value, err = parseYAMLScalar(rest); err != nil {
+				return nil, err
+			}
+		}
+		m[key] = value
+	}
+	return m, nil
+}
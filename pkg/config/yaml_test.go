@@ -0,0 +1,154 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[string]any
+	}{
+		{
+			name: "empty",
+			src:  "# solo un comentario\n---\n",
+			want: map[string]any{},
+		},
+		{
+			name: "integers",
+			src:  "a: 10\nb: -3\nc: +7\nd: 0\n",
+			want: map[string]any{"a": int64(10), "b": int64(-3), "c": int64(7), "d": int64(0)},
+		},
+		{
+			name: "leading zeros are decimal",
+			src:  "a: 010\nb: -007\n",
+			want: map[string]any{"a": int64(10), "b": int64(-7)},
+		},
+		{
+			name: "octal and hex",
+			src:  "oct: 0o17\nhex: 0x1F\n",
+			want: map[string]any{"oct": int64(15), "hex": int64(31)},
+		},
+		{
+			name: "floats",
+			src:  "a: 1.5\nb: -.25\nc: 5e3\nd: 2.\n",
+			want: map[string]any{"a": 1.5, "b": -0.25, "c": 5000.0, "d": 2.0},
+		},
+		{
+			name: "things that look like numbers are strings",
+			src:  "a: 1_000\nb: 0b101\nc: Inf\nd: 1.2.3\ne: 0o9\nf: 12abc\n",
+			want: map[string]any{"a": "1_000", "b": "0b101", "c": "Inf", "d": "1.2.3", "e": "0o9", "f": "12abc"},
+		},
+		{
+			name: "booleans and null",
+			src:  "a: true\nb: False\nc: null\nd: ~\ne:\n",
+			want: map[string]any{"a": true, "b": false, "c": nil, "d": nil, "e": nil},
+		},
+		{
+			name: "strings",
+			src:  "plain: hello world\ndouble: \"tab\\there: #1\"\nsingle: 'it''s'\nduration: 30s\nport: \":8080\"\n",
+			want: map[string]any{"plain": "hello world", "double": "tab\there: #1", "single": "it's", "duration": "30s", "port": ":8080"},
+		},
+		{
+			name: "comments",
+			src:  "# arriba\na: 1 # el uno\nb: \"x # y\"\nc: a#b\n",
+			want: map[string]any{"a": int64(1), "b": "x # y", "c": "a#b"},
+		},
+		{
+			name: "nested maps",
+			src:  "limits:\n  rate: 5\n  storage:\n    path: data\nlisten: \":8080\"\n",
+			want: map[string]any{
+				"limits": map[string]any{"rate": int64(5), "storage": map[string]any{"path": "data"}},
+				"listen": ":8080",
+			},
+		},
+		{
+			name: "quoted keys",
+			src:  "\"a key\": 1\n'other: key': 2\n",
+			want: map[string]any{"a key": int64(1), "other: key": int64(2)},
+		},
+		{
+			name: "lists",
+			src:  "origins:\n  - a\n  - \"b\"\nsame_level:\n- 1\n- 2\n",
+			want: map[string]any{"origins": []any{"a", "b"}, "same_level": []any{int64(1), int64(2)}},
+		},
+		{
+			name: "lists of maps",
+			src:  "rooms:\n  - name: a\n    max_users: 3\n  - name: b\n    owner: alice\n",
+			want: map[string]any{"rooms": []any{
+				map[string]any{"name": "a", "max_users": int64(3)},
+				map[string]any{"name": "b", "owner": "alice"},
+			}},
+		},
+		{
+			name: "nested lists",
+			src:  "a:\n  -\n    - 1\n    - 2\n  - [3, 4]\n",
+			want: map[string]any{"a": []any{[]any{int64(1), int64(2)}, []any{int64(3), int64(4)}}},
+		},
+		{
+			name: "flow collections",
+			src:  "list: [a, \"b, c\", 1]\nmap: {cert: c.pem, key: 'k.pem'}\nempty: []\nnested: {a: [1, 2], b: {c: d}}\n",
+			want: map[string]any{
+				"list":   []any{"a", "b, c", int64(1)},
+				"map":    map[string]any{"cert": "c.pem", "key": "k.pem"},
+				"empty":  []any{},
+				"nested": map[string]any{"a": []any{int64(1), int64(2)}, "b": map[string]any{"c": "d"}},
+			},
+		},
+		{
+			name: "windows line endings",
+			src:  "a: 1\r\nb:\r\n  c: x\r\n",
+			want: map[string]any{"a": int64(1), "b": map[string]any{"c": "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseYAML([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseYAML =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // parte del mensaje de error
+	}{
+		{"key defined twice", "a: 1\nb: 2\na: 3\n", `line 3: key "a" is defined twice`},
+		{"key defined twice in a nested map", "t:\n  a: 1\n  a: 2\n", `line 3: key "a" is defined twice`},
+		{"top level list", "- a\n- b\n", "the document must be a mapping"},
+		{"indented document", "  a: 1\n", "the document must be a mapping"},
+		{"tab indentation", "a:\n\tb: 1\n", "tabs can't be used"},
+		{"unexpected indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"missing colon", "a: 1\njust text\n", `line 2: expected "key: value"`},
+		{"block scalar", "motd: |\n  hi\n", "block scalars are not supported"},
+		{"anchor", "a: &x 1\n", "anchors, aliases and tags"},
+		{"bad double-quoted string", "a: \"\\q\"\n", "invalid string"},
+		{"unterminated single-quoted string", "a: 'oops\n", "unterminated string"},
+		{"unclosed flow list", "a: [1, 2\n", "expected ']'"},
+		{"empty flow element", "a: [1, , 2]\n", "empty element"},
+		{"bad flow map", "a: {b}\n", `expected "key: value"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseYAML([]byte(tt.src))
+			if err == nil {
+				t.Fatalf("ParseYAML(%q) succeeded, want an error with %q", tt.src, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseYAML(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package protocol
+
+// Códigos de error enviados en los mensajes de tipo error
+const (
+	CodeHelloRequired   = "hello_required"
+	CodeInvalidNickname = "invalid_nickname"
+	CodeNicknameTaken   = "nickname_taken"
+	CodeInvalidRoomName = "invalid_room_name"
+	CodeInvalidCapacity = "invalid_capacity"
+	CodeRoomExists      = "room_exists"
+	CodeRoomNotFound    = "room_not_found"
+	CodeRoomFull        = "room_full"
+	CodeInviteRequired  = "invite_required"
+	CodeInvalidInvite   = "invalid_invite"
+	CodeNotInRoom       = "not_in_room"
+	CodeRoomNotPrivate  = "room_not_private"
+	CodeUserOffline     = "user_offline"
+	CodeMessageNotFound = "message_not_found"
+	CodeInvalidMessage  = "invalid_message"
+	CodeForbidden       = "forbidden"
+	CodeInternal        = "internal_error"
+	CodeNotNegotiated   = "capability_not_negotiated"
+	CodeRateLimited     = "rate_limited"
+	CodeMuted           = "muted"
+	CodeKicked          = "kicked"
+	CodeBanned          = "banned"
+	CodeUserNotInRoom   = "user_not_in_room"
+	CodeNotBanned       = "not_banned"
+	CodeNotMuted        = "not_muted"
+	CodeSlowMode        = "slow_mode"
+	CodeReadOnly        = "read_only"
+)
+
+// Códigos de cierre del WebSocket propios de bubblenet (rango 4000-4999),
+// el motivo legible va en el texto del close frame
+const (
+	// El cliente habla una versión del protocolo que el servidor no soporta
+	CloseUnsupportedProtocol = 4001
+
+	// La sesión se retomó desde otra conexión
+	CloseSessionResumed = 4002
+
+	// El servidor desconectó al cliente por mandar mensajes demasiado rápido,
+	// el cliente no debe reconectarse solo
+	CloseRateLimited = 4003
+
+	// El cliente no leía sus mensajes tan rápido como le llegaban
+	CloseSlowConsumer = 4004
+)
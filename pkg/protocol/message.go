@@ -0,0 +1,166 @@
+// Package protocol define el contrato entre el servidor y los clientes de
+// bubblenet: los mensajes que viajan por el WebSocket, sus tipos, los
+// códigos de error, la versión del protocolo y las capacidades opcionales.
+package protocol
+
+import (
+	"slices"
+	"time"
+)
+
+// Tipos de mensaje que envía el cliente
+const (
+	TypeHello          = "hello"           // handshake, siempre el primer mensaje
+	TypeChat           = "chat"            // mensaje a la sala actual
+	TypeJoin           = "join"            // entrar a una sala
+	TypeLeave          = "leave"           // volver al lobby
+	TypeCreateRoom     = "create_room"     // crear una sala y entrar a ella
+	TypeHistoryRequest = "history_request" // pedir una página del historial
+	TypeTyping         = "typing"          // indicador de escritura (también lo reenvía el servidor)
+	TypeNick           = "nick"            // cambio de nickname (el servidor lo confirma)
+	TypeKick           = "kick"            // sacar a un usuario de la sala
+	TypeBan            = "ban"             // sacar a un usuario y no dejarlo volver
+	TypeUnban          = "unban"           // levantar un ban
+	TypeOp             = "op"              // nombrar moderador a un usuario
+	TypeDeop           = "deop"            // quitarle el rol de moderador
+	TypeMute           = "mute"            // dejar a un usuario sin poder escribir en la sala
+	TypeUnmute         = "unmute"          // levantar un mute
+	TypeSlowMode       = "slow_mode"       // fijar el slow mode de la sala (0 lo apaga)
+	TypeReadOnly       = "read_only"       // poner o sacar la sala en modo solo lectura
+)
+
+// Roles de los miembros de una sala. Los que no aparecen en Roles son
+// miembros comunes
+const (
+	RoleOwner     = "owner"     // quien creó la sala: modera y nombra moderadores
+	RoleModerator = "moderator" // puede echar y banear miembros comunes
+)
+
+// Tipos de mensaje que envían tanto el cliente como el servidor
+const (
+	TypeRoomList = "room_list" // pedido y respuesta del directorio de salas
+	TypeInvite   = "invite"    // pedido y respuesta de una invitación
+	TypeEdit     = "edit"      // edición de un mensaje propio
+	TypeDelete   = "delete"    // borrado de un mensaje propio
+	TypeDM       = "dm"        // mensaje directo entre usuarios
+)
+
+// Tipos de mensaje que envía el servidor
+const (
+	TypeWelcome     = "welcome"      // respuesta al hello
+	TypeSystem      = "system"       // avisos de la sala (entradas, salidas, etc.)
+	TypeError       = "error"        // rechazo de un pedido, con Code
+	TypeUserList    = "user_list"    // miembros de la sala
+	TypeRoomCreated = "room_created" // confirmación de create_room
+	TypeHistory     = "history"      // historial reciente al entrar a una sala
+	TypeHistoryPage = "history_page" // respuesta a history_request
+	TypeAck         = "ack"          // confirmación de un mensaje de chat
+	TypeRoomMode    = "room_mode"    // qué se puede escribir en la sala, para cada miembro
+)
+
+// clientTypes son los tipos que puede mandar un cliente
+var clientTypes = []string{
+	TypeHello, TypeChat, TypeJoin, TypeLeave, TypeCreateRoom, TypeHistoryRequest, TypeTyping, TypeNick,
+	TypeKick, TypeBan, TypeUnban, TypeOp, TypeDeop, TypeMute, TypeUnmute, TypeSlowMode, TypeReadOnly,
+	TypeRoomList, TypeInvite, TypeEdit, TypeDelete, TypeDM,
+}
+
+// FromClient indica si un cliente puede mandar mensajes de ese tipo. Los
+// tipos que solo envía el servidor (system, user_list, etc.) no, así nadie
+// se hace pasar por un aviso del servidor
+func FromClient(msgType string) bool {
+	return slices.Contains(clientTypes, msgType)
+}
+
+// serverTypes son los tipos que solo manda el servidor
+var serverTypes = []string{
+	TypeWelcome, TypeSystem, TypeError, TypeUserList, TypeRoomCreated,
+	TypeHistory, TypeHistoryPage, TypeAck, TypeRoomMode,
+}
+
+// Known indica si un tipo de mensaje es parte del protocolo, lo mande el
+// cliente o el servidor
+func Known(msgType string) bool {
+	return FromClient(msgType) || slices.Contains(serverTypes, msgType)
+}
+
+// Estados de los mensajes de tipo typing
+const (
+	StatusTyping        = "typing"
+	StatusStoppedTyping = "stopped_typing"
+)
+
+// MaxExpiresIn es el mayor expires_in que se acepta, en segundos (un año).
+// Un número más grande daría la vuelta al convertirlo en time.Duration
+const MaxExpiresIn = 365 * 24 * 60 * 60
+
+// Message es el mensaje que viaja por el WebSocket en ambas direcciones,
+// los campos que no aplican a un tipo se omiten del JSON
+type Message struct {
+	ID        string    `json:"id,omitempty"` // Asignado por el servidor
+	Type      string    `json:"type"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Room      string    `json:"room,omitempty"`
+	To        string    `json:"to,omitempty"`     // Destinatario de los mensajes directos (dm)
+	Target    string    `json:"target,omitempty"` // Usuario afectado por kick, ban, unban, op y deop
+	Status    string    `json:"status,omitempty"` // typing, stopped_typing
+	Code      string    `json:"code,omitempty"`   // Para mensajes de tipo error
+	Seq       int64     `json:"seq,omitempty"`    // Posición en el historial de la sala
+
+	// Para ediciones y borrados: ID del mensaje afectado y su estado
+	RefID   string `json:"ref_id,omitempty"`
+	Edited  bool   `json:"edited,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+
+	// ID que el cliente le pone a su mensaje de chat para reconocer la
+	// confirmación (ack) o el error del servidor y descartar reenvíos
+	ClientID string `json:"client_id,omitempty"`
+
+	// Para pedir páginas del historial (history_request / history_page)
+	Before  int64 `json:"before,omitempty"`
+	Limit   int   `json:"limit,omitempty"`
+	HasMore bool  `json:"has_more,omitempty"`
+
+	// Para crear salas y describirlas
+	MaxUsers int  `json:"max_users,omitempty"`
+	Private  bool `json:"private,omitempty"`
+
+	// Para invitaciones a salas privadas
+	InviteCode string     `json:"invite_code,omitempty"`
+	ExpiresIn  int        `json:"expires_in,omitempty"` // segundos, al pedir una invitación o en un ban o mute
+	MaxUses    int        `json:"max_uses,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // también el fin de un mute en room_mode
+
+	// Restricciones de la sala (slow_mode, read_only y room_mode). SlowMode
+	// son los segundos mínimos entre mensajes de un usuario; Muted indica
+	// si quien recibe el room_mode está silenciado
+	SlowMode int  `json:"slow_mode,omitempty"`
+	ReadOnly bool `json:"read_only,omitempty"`
+	Muted    bool `json:"muted,omitempty"`
+
+	// Para el handshake (hello / welcome). Un hello con SessionID retoma esa
+	// sesión y el servidor reenvía los mensajes posteriores a LastID
+	SessionID    string   `json:"session_id,omitempty"`
+	LastID       string   `json:"last_id,omitempty"`
+	Resumed      bool     `json:"resumed,omitempty"`
+	Protocol     int      `json:"protocol,omitempty"`     // Versión del protocolo
+	Version      string   `json:"version,omitempty"`      // Versión del cliente o del servidor
+	Capabilities []string `json:"capabilities,omitempty"` // Pedidas en el hello, acordadas en el welcome
+	MOTD         string   `json:"motd,omitempty"`
+
+	// Listas que envía el servidor
+	Users    []string          `json:"users,omitempty"`    // Para mensajes de tipo user_list
+	Roles    map[string]string `json:"roles,omitempty"`    // Dueño y moderadores de la sala, por username
+	Rooms    []RoomInfo        `json:"rooms,omitempty"`    // Para mensajes de tipo room_list
+	Messages []Message         `json:"messages,omitempty"` // Para mensajes de tipo history e history_page
+}
+
+// RoomInfo describe una sala en el directorio de salas
+type RoomInfo struct {
+	Name     string `json:"name"`
+	Users    int    `json:"users"`
+	MaxUsers int    `json:"max_users"`
+	Private  bool   `json:"private"`
+}
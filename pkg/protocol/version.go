@@ -0,0 +1,84 @@
+package protocol
+
+import "slices"
+
+const (
+	// Version es la versión del protocolo que hablan este servidor y este
+	// cliente. La 2 agrega la versión y las capacidades al hello
+	Version = 2
+
+	// MinVersion es la versión más vieja que todavía se acepta. Los clientes
+	// de la 1 no negocian capacidades y usan solo el protocolo base
+	MinVersion = 1
+)
+
+// Capacidades opcionales del protocolo. Un cliente solo recibe los mensajes
+// de las capacidades que acordó en el handshake; el chat, las salas y el
+// directorio son parte del protocolo base y no se negocian
+const (
+	CapHistory    = "history"    // historial al entrar y paginado (history, history_page)
+	CapEdit       = "edit"       // edición y borrado de mensajes
+	CapDM         = "dm"         // mensajes directos
+	CapTyping     = "typing"     // indicadores de escritura
+	CapNick       = "nick"       // cambio de nickname
+	CapAck        = "ack"        // confirmación de entrega de los mensajes propios
+	CapModeration = "moderation" // kick, ban, op, mute, slow mode, solo lectura y room_mode
+)
+
+// capabilities son todas las capacidades que define esta versión
+var capabilities = []string{CapHistory, CapEdit, CapDM, CapTyping, CapNick, CapAck, CapModeration}
+
+// Capabilities retorna todas las capacidades que define esta versión
+func Capabilities() []string {
+	return slices.Clone(capabilities)
+}
+
+// NegotiateVersion acuerda la versión a usar con la otra parte: la menor de
+// las dos, siempre que no sea más vieja que MinVersion
+func NegotiateVersion(peer int) (int, bool) {
+	version := min(peer, Version)
+	return version, version >= MinVersion
+}
+
+// Negotiate retorna las capacidades que piden ambas partes, en el orden de
+// offered. Las desconocidas se ignoran
+func Negotiate(offered, supported []string) []string {
+	agreed := []string{}
+	for _, c := range offered {
+		if slices.Contains(supported, c) && !slices.Contains(agreed, c) {
+			agreed = append(agreed, c)
+		}
+	}
+	return agreed
+}
+
+// NegotiateFor acuerda las capacidades con un cliente que habla version:
+// antes de la 2 no había capacidades y no se le acuerda ninguna
+func NegotiateFor(version int, offered []string) []string {
+	if version < 2 {
+		return []string{}
+	}
+	return Negotiate(offered, capabilities)
+}
+
+// CapabilityFor retorna la capacidad que hace falta para recibir un tipo
+// de mensaje, o "" si es parte del protocolo base
+func CapabilityFor(msgType string) string {
+	switch msgType {
+	case TypeHistory, TypeHistoryPage:
+		return CapHistory
+	case TypeEdit, TypeDelete:
+		return CapEdit
+	case TypeDM:
+		return CapDM
+	case TypeTyping:
+		return CapTyping
+	case TypeNick:
+		return CapNick
+	case TypeAck:
+		return CapAck
+	case TypeKick, TypeBan, TypeUnban, TypeOp, TypeDeop, TypeMute, TypeUnmute, TypeSlowMode, TypeReadOnly, TypeRoomMode:
+		return CapModeration
+	}
+	return ""
+}
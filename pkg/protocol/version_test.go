@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		peer, want int
+		ok         bool
+	}{
+		{Version, Version, true},
+		{Version + 5, Version, true}, // un cliente más nuevo habla la nuestra
+		{1, 1, true},
+		{MinVersion - 1, MinVersion - 1, false},
+	}
+	for _, tt := range tests {
+		if got, ok := NegotiateVersion(tt.peer); got != tt.want || ok != tt.ok {
+			t.Errorf("NegotiateVersion(%d) = %d, %t, want %d, %t", tt.peer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered []string
+		want    []string
+	}{
+		{"known in offered order", []string{CapTyping, CapHistory}, []string{CapTyping, CapHistory}},
+		{"unknown and repeated", []string{"video", CapDM, CapDM}, []string{CapDM}},
+		{"nothing offered", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Negotiate(tt.offered, capabilities)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("Negotiate(%v) = %#v, want %#v", tt.offered, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		offered []string
+		want    []string
+	}{
+		{"current protocol", Version, []string{CapTyping, "video", CapHistory}, []string{CapTyping, CapHistory}},
+		{"protocol 1 gets none", 1, []string{CapTyping, CapHistory}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateFor(tt.version, tt.offered); !slices.Equal(got, tt.want) {
+				t.Errorf("NegotiateFor(%d, %v) = %#v, want %#v", tt.version, tt.offered, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilityFor(t *testing.T) {
+	for _, msgType := range []string{TypeChat, TypeJoin, TypeSystem, TypeRoomList} {
+		if c := CapabilityFor(msgType); c != "" {
+			t.Errorf("CapabilityFor(%s) = %q, want the base protocol", msgType, c)
+		}
+	}
+	for msgType, want := range map[string]string{TypeHistoryPage: CapHistory, TypeDelete: CapEdit, TypeDM: CapDM, TypeNick: CapNick} {
+		if c := CapabilityFor(msgType); c != want {
+			t.Errorf("CapabilityFor(%s) = %q, want %q", msgType, c, want)
+		}
+	}
+
+}
+
+func TestMessageTypes(t *testing.T) {
+	for _, msgType := range []string{TypeHello, TypeChat, TypeKick, TypeDM} {
+		if !FromClient(msgType) || !Known(msgType) {
+			t.Errorf("%s should be accepted from clients", msgType)
+		}
+	}
+	for _, msgType := range []string{TypeWelcome, TypeSystem, TypeUserList, TypeError, TypeRoomMode} {
+		if FromClient(msgType) {
+			t.Errorf("%s is sent only by the server, clients can't send it", msgType)
+		}
+		if !Known(msgType) {
+			t.Errorf("%s should be a known type", msgType)
+		}
+	}
+	if FromClient("message") || Known("message") {
+		t.Error(`"message" is not a protocol type`)
+	}
+}